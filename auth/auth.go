@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Authentication types accepted by New
+const (
+	TypeBearer = "bearer"
+	TypeAPIKey = "api_key"
+	TypeBasic  = "basic"
+	TypeOAuth2 = "oauth2"
+)
+
+// DefaultAPIKeyHeader carries API keys when no header is configured
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// Authenticator adds credentials to requests
+type Authenticator interface {
+	// Name describes the authentication in logs, without credentials
+	Name() string
+	Authorize(req *http.Request) error
+}
+
+// Invalidator is implemented by authenticators holding credentials that
+// can expire before their time, e.g. OAuth2 tokens revoked by the server.
+// Invalidate is called when a request is rejected as unauthorized
+type Invalidator interface {
+	Invalidate()
+}
+
+// Config configures an Authenticator. Credentials are references read by
+// LoadSecret
+type Config struct {
+	Type string
+	// Token is the bearer token
+	Token string
+	// Header and Key are the API key and the header it is sent in
+	Header string
+	Key    string
+	// Username and Password are the HTTP basic credentials
+	Username string
+	Password string
+	// TokenURL, ClientID, ClientSecret and Scopes configure the OAuth2
+	// client credentials grant
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// New creates the authenticator configured by cfg, reading its credentials
+func New(cfg Config) (Authenticator, error) {
+	switch cfg.Type {
+	case TypeBearer:
+		token, err := LoadSecret(cfg.Token)
+		if err != nil {
+			return nil, fmt.Errorf("bearer token: %w", err)
+		}
+		return Bearer{Token: token}, nil
+	case TypeAPIKey:
+		key, err := LoadSecret(cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("api key: %w", err)
+		}
+		header := cfg.Header
+		if header == "" {
+			header = DefaultAPIKeyHeader
+		}
+		return APIKey{Header: header, Key: key}, nil
+	case TypeBasic:
+		username, err := LoadSecret(cfg.Username)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth username: %w", err)
+		}
+		password, err := LoadSecret(cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("basic auth password: %w", err)
+		}
+		return Basic{Username: username, Password: password}, nil
+	case TypeOAuth2:
+		if cfg.TokenURL == "" {
+			return nil, fmt.Errorf("oauth2 needs a token_url")
+		}
+		clientID, err := LoadSecret(cfg.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client id: %w", err)
+		}
+		clientSecret, err := LoadSecret(cfg.ClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client secret: %w", err)
+		}
+		return NewClientCredentials(cfg.TokenURL, clientID, clientSecret, cfg.Scopes), nil
+	}
+	return nil, fmt.Errorf("unknown auth type %q (want bearer, api_key, basic or oauth2)", cfg.Type)
+}
+
+// Bearer sends a static token in the Authorization header
+type Bearer struct {
+	Token Secret
+}
+
+// Name implements Authenticator
+func (b Bearer) Name() string { return "bearer token" }
+
+// Authorize implements Authenticator
+func (b Bearer) Authorize(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+b.Token.Reveal())
+	return nil
+}
+
+// APIKey sends a key in a header
+type APIKey struct {
+	Header string
+	Key    Secret
+}
+
+// Name implements Authenticator
+func (k APIKey) Name() string { return "API key in " + k.Header }
+
+// Authorize implements Authenticator
+func (k APIKey) Authorize(req *http.Request) error {
+	req.Header.Set(k.Header, k.Key.Reveal())
+	return nil
+}
+
+// Basic sends HTTP basic credentials
+type Basic struct {
+	Username Secret
+	Password Secret
+}
+
+// Name implements Authenticator
+func (b Basic) Name() string { return "basic auth" }
+
+// Authorize implements Authenticator
+func (b Basic) Authorize(req *http.Request) error {
+	req.SetBasicAuth(b.Username.Reveal(), b.Password.Reveal())
+	return nil
+}
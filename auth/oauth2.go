@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"my-incident-checker/httpclient"
+)
+
+const (
+	// tokenTimeout bounds a token request
+	tokenTimeout = 10 * time.Second
+	// expiryMargin renews tokens this long before they expire, so that a
+	// token doesn't expire on its way to the server
+	expiryMargin = 30 * time.Second
+)
+
+// tokenClient requests OAuth2 tokens
+var tokenClient = httpclient.New(tokenTimeout)
+
+// ClientCredentials authenticates with an access token of the OAuth2
+// client credentials grant. The token is cached until shortly before it
+// expires or until the server rejects it
+type ClientCredentials struct {
+	tokenURL     string
+	clientID     Secret
+	clientSecret Secret
+	scopes       []string
+	now          func() time.Time
+
+	mu     sync.Mutex
+	token  Secret
+	expiry time.Time // zero when the token doesn't expire
+}
+
+// NewClientCredentials creates a ClientCredentials authenticator
+func NewClientCredentials(tokenURL string, clientID, clientSecret Secret, scopes []string) *ClientCredentials {
+	return &ClientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		now:          time.Now,
+	}
+}
+
+// Name implements Authenticator
+func (c *ClientCredentials) Name() string { return "OAuth2 client credentials from " + c.tokenURL }
+
+// Authorize implements Authenticator, requesting a token when none is
+// cached or the cached one is about to expire
+func (c *ClientCredentials) Authorize(req *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.Empty() || (!c.expiry.IsZero() && !c.now().Add(expiryMargin).Before(c.expiry)) {
+		if err := c.refresh(); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token.Reveal())
+	return nil
+}
+
+// Invalidate implements Invalidator, dropping the cached token
+func (c *ClientCredentials) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = Secret{}
+	c.expiry = time.Time{}
+}
+
+// refresh requests a new token. The client authenticates with HTTP basic,
+// as RFC 6749 recommends
+func (c *ClientCredentials) refresh() error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("invalid token url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.clientID.Reveal()), url.QueryEscape(c.clientSecret.Reveal()))
+
+	requested := c.now()
+	resp, err := tokenClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request OAuth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	const maxResponseSize = 1 << 16
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		if body.Error != "" {
+			return fmt.Errorf("OAuth2 token request failed with status code %d: %s", resp.StatusCode, oauthError(body.Error, body.ErrorDescription))
+		}
+		return fmt.Errorf("OAuth2 token request failed with status code: %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed to parse OAuth2 token: %w", decodeErr)
+	}
+	if body.AccessToken == "" {
+		return fmt.Errorf("OAuth2 token response has no access_token")
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return fmt.Errorf("unsupported OAuth2 token type %q", body.TokenType)
+	}
+
+	c.token = NewSecret(body.AccessToken)
+	c.expiry = time.Time{}
+	if body.ExpiresIn > 0 {
+		c.expiry = requested.Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return nil
+}
+
+func oauthError(code, description string) string {
+	if description == "" {
+		return code
+	}
+	return code + " (" + description + ")"
+}
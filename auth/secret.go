@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// redacted replaces secrets wherever they are printed
+const redacted = "[REDACTED]"
+
+// Secret is a credential. It prints as [REDACTED] with every fmt verb, so
+// it can't leak into logs or error messages by accident
+type Secret struct {
+	value string
+}
+
+// NewSecret wraps a credential
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// Reveal returns the credential, to put it in a request
+func (s Secret) Reveal() string {
+	return s.value
+}
+
+// Empty reports whether the secret has no value
+func (s Secret) Empty() bool {
+	return s.value == ""
+}
+
+// String implements fmt.Stringer
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString implements fmt.GoStringer
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Format implements fmt.Formatter
+func (s Secret) Format(f fmt.State, verb rune) {
+	fmt.Fprint(f, redacted)
+}
+
+// LoadSecret reads a credential from a reference: "env:NAME" reads the
+// environment variable NAME and "file:/path" the file at /path, without
+// surrounding whitespace. Credentials can't be written into the
+// configuration itself
+func LoadSecret(ref string) (Secret, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" {
+			return Secret{}, fmt.Errorf("environment variable %s is not set", name)
+		}
+		return NewSecret(value), nil
+	case strings.HasPrefix(ref, "file:"):
+		path := strings.TrimPrefix(ref, "file:")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Secret{}, fmt.Errorf("failed to read credential: %w", err)
+		}
+		value := strings.TrimSpace(string(data))
+		if value == "" {
+			return Secret{}, fmt.Errorf("credential file %s is empty", path)
+		}
+		return NewSecret(value), nil
+	case ref == "":
+		return Secret{}, fmt.Errorf("missing credential")
+	}
+	return Secret{}, fmt.Errorf("credentials must be read from env:NAME or file:/path")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/input"
+	"my-incident-checker/poll"
+	"my-incident-checker/types"
+)
+
+// defaultSnooze is how long a long press snoozes alerts
+const defaultSnooze = 30 * time.Minute
+
+// startButtons opens the configured buttons and maps short presses to
+// acknowledging and long presses to snoozing the current alert. Buttons
+// that fail to open are logged and skipped
+func startButtons(buttons []config.ButtonConfig, acks *poll.Acknowledger, logger *types.Logger) func() {
+	var sources []input.Source
+	for _, button := range buttons {
+		source, err := openButtonSource(button)
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to open button %s: %s", button.Name, err.Error())
+			continue
+		}
+		longPress, err := durationOption(button.LongPress, input.DefaultLongPress)
+		if err != nil {
+			logger.ErrorLog.Printf("Button %s: invalid long_press: %s", button.Name, err.Error())
+			source.Close()
+			continue
+		}
+		snooze, err := durationOption(button.Snooze, defaultSnooze)
+		if err != nil {
+			logger.ErrorLog.Printf("Button %s: invalid snooze: %s", button.Name, err.Error())
+			source.Close()
+			continue
+		}
+		logger.InfoLog.Printf("Using button %s (%s)", button.Name, button.Driver)
+		sources = append(sources, source)
+
+		name := button.Name
+		b := input.NewButton(source, input.DefaultDebounce, longPress)
+		go func() {
+			err := b.Run(func(press input.Press) {
+				var err error
+				if press.Long {
+					logger.InfoLog.Printf("Button %s held for %s, snoozing alerts", name, press.Duration)
+					err = acks.Snooze(snooze)
+				} else {
+					logger.InfoLog.Printf("Button %s pressed, acknowledging alert", name)
+					err = acks.Acknowledge()
+				}
+				if err != nil {
+					logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+				}
+			})
+			if err != input.ErrClosed {
+				logger.ErrorLog.Printf("Button %s stopped: %s", name, err.Error())
+			}
+		}()
+	}
+
+	return func() {
+		for _, source := range sources {
+			source.Close()
+		}
+	}
+}
+
+// openButtonSource opens the input of a button
+func openButtonSource(button config.ButtonConfig) (input.Source, error) {
+	options := button.Options
+	switch button.Driver {
+	case "gpio":
+		line, err := strconv.Atoi(options["line"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %q: %w", options["line"], err)
+		}
+		activeLow := false
+		if value := options["active_low"]; value != "" {
+			if activeLow, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid active_low %q: %w", value, err)
+			}
+		}
+		return input.NewGPIOSource(options["chip"], line, activeLow)
+	case "evdev":
+		var key uint64
+		if value := options["key"]; value != "" {
+			var err error
+			if key, err = strconv.ParseUint(value, 10, 16); err != nil {
+				return nil, fmt.Errorf("invalid key %q: %w", value, err)
+			}
+		}
+		return input.NewEvdevSource(options["device"], uint16(key))
+	}
+	return nil, fmt.Errorf("unknown button driver %q", button.Driver)
+}
+
+// durationOption parses an optional duration
+func durationOption(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/control"
+	"my-incident-checker/lights"
+)
+
+const usage = `Usage: incident-checker [command]
+
+Without a command the checker polls for incidents.
+
+Commands:
+  list-lights   list the light drivers and the devices they detect
+  diagnose      report interfaces, default route, DNS and connection
+                timings to every endpoint the checker talks to
+  status        show what the running checker displays and why
+  override      force the light of the running checker to a state:
+                  override [-for 15m] [-reason text] <state>
+                  override clear
+                a state is a color (red), a blinking color (blink-red),
+                off, or lamp modes (red=on,yellow=blink,buzzer=on)
+`
+
+// runCommand runs a command given on the command line and returns the exit
+// code
+func runCommand(args []string, out io.Writer) int {
+	switch args[0] {
+	case "list-lights":
+		if err := listLights(out); err != nil {
+			fmt.Fprintf(os.Stderr, "list-lights: %s\n", err.Error())
+			return 1
+		}
+		return 0
+	case "diagnose":
+		if err := diagnose(out); err != nil {
+			fmt.Fprintf(os.Stderr, "diagnose: %s\n", err.Error())
+			return 1
+		}
+		return 0
+	case "status":
+		if err := printStatus(out, control.NewClient(control.Addr())); err != nil {
+			fmt.Fprintf(os.Stderr, "status: %s\n", err.Error())
+			return 1
+		}
+		return 0
+	case "override":
+		if err := override(args[1:], out, control.NewClient(control.Addr())); err != nil {
+			fmt.Fprintf(os.Stderr, "override: %s\n", err.Error())
+			return 1
+		}
+		return 0
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(out, usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+	return 2
+}
+
+// override sets or clears a manual override on the running checker
+func override(args []string, out io.Writer, client *control.Client) error {
+	flags := flag.NewFlagSet("override", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	duration := flags.Duration("for", 0, "how long the override lasts, until cleared when unset")
+	reason := flags.String("reason", "", "why the light is overridden, logged by the checker")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected a state or clear")
+	}
+
+	if flags.Arg(0) == "clear" {
+		if err := client.ClearOverride(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Override cleared")
+		return nil
+	}
+
+	req := control.OverrideRequest{State: flags.Arg(0), Reason: *reason}
+	if *duration > 0 {
+		req.Duration = duration.String()
+	}
+	set, err := client.SetOverride(req)
+	if err != nil {
+		return err
+	}
+	if set.Until != nil {
+		fmt.Fprintf(out, "Light overridden to %s until %s\n", set.State, set.Until.Local().Format(time.RFC3339))
+	} else {
+		fmt.Fprintf(out, "Light overridden to %s until cleared\n", set.State)
+	}
+	return nil
+}
+
+// printStatus prints the status of the running checker
+func printStatus(out io.Writer, client *control.Client) error {
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	source := status.Source
+	if source == "" {
+		source = "no claims"
+	}
+	fmt.Fprintf(w, "Light:\t%s\t(%s)\n", status.Shown, source)
+	if o := status.Override; o != nil {
+		until := "until cleared"
+		if o.Until != nil {
+			until = "until " + o.Until.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "Override:\t%s\t%s, since %s", o.State, until, o.Since.Local().Format(time.RFC3339))
+		if o.Reason != "" {
+			fmt.Fprintf(w, ", reason: %s", o.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "Claims:")
+	for _, claim := range status.Claims {
+		expires := ""
+		if claim.Expires != nil {
+			expires = "expires " + claim.Expires.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%s\t%s\n", claim.Source, claim.Priority, claim.State, expires)
+	}
+	return w.Flush()
+}
+
+// listLights prints the registered drivers with their options, what the
+// auto driver detects and the lights of the configuration file
+func listLights(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Drivers:")
+	for _, driver := range lights.Drivers() {
+		fmt.Fprintf(w, "  %s\t%s\n", driver.Name, driver.Description)
+		for _, option := range driver.Options {
+			required := ""
+			if option.Required {
+				required = " (required)"
+			}
+			fmt.Fprintf(w, "  \t  %s\t%s%s\n", option.Name, option.Description, required)
+		}
+	}
+
+	fmt.Fprintln(w, "\nDetected, in auto probing order:")
+	chosen := ""
+	for _, detection := range lights.ProbeDrivers(envLightOptions(lights.AutoDriver)) {
+		if detection.Err != nil {
+			fmt.Fprintf(w, "  %s\tnot found: %s\n", detection.Driver, detection.Err.Error())
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", detection.Driver, detection.Found)
+		if chosen == "" {
+			chosen = detection.Driver
+		}
+	}
+	if chosen == "" {
+		chosen = "none"
+	}
+	fmt.Fprintf(w, "  auto would use: %s\n", chosen)
+
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		w.Flush()
+		return err
+	}
+	if len(cfg.Lights) > 0 {
+		fmt.Fprintf(w, "\nConfigured in %s:\n", config.Path())
+		for _, device := range cfg.Lights {
+			status := "ok"
+			if driver, ok := lights.LookupDriver(device.Driver); !ok && device.Driver != lights.AutoDriver {
+				status = "unknown driver"
+			} else if ok {
+				if err := driver.Validate(device.Options); err != nil {
+					status = err.Error()
+				}
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", device.Name, device.Driver, formatOptions(device.Options), status)
+		}
+	}
+	return w.Flush()
+}
+
+// formatOptions renders options as key=value pairs, hiding secrets
+func formatOptions(options config.Options) string {
+	var pairs []string
+	for _, key := range sortedKeys(options) {
+		value := options[key]
+		if key == "token" || key == "username" {
+			value = "***"
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys(options config.Options) []string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
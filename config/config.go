@@ -0,0 +1,382 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const defaultConfigFile = "config.json"
+
+// Config is the optional JSON configuration file of the checker
+type Config struct {
+	// Lights lists the light devices driven together. When empty, a single
+	// light is detected automatically
+	Lights []LightConfig `json:"lights"`
+	// Zones bind lights to a subset of services. A light bound to a zone
+	// only shows that zone and is left out of the overall display
+	Zones []ZoneConfig `json:"zones"`
+	// Wall is an optional LED strip showing one pixel per service
+	Wall *WallConfig `json:"wall"`
+	// Buttons acknowledge (short press) or snooze (long press) alerts
+	Buttons []ButtonConfig `json:"buttons"`
+	// Display is an optional character display for incident details
+	Display *DisplayConfig `json:"display"`
+	// Heartbeat configures the monitoring services told that the checker
+	// is alive. Without it, the built-in Dead Man's Snitch is used
+	Heartbeat *HeartbeatConfig `json:"heartbeat"`
+	// Watchdog reacts when the poll loop gets stuck
+	Watchdog *WatchdogConfig `json:"watchdog"`
+	// Connectivity configures the background connectivity monitor
+	Connectivity *ConnectivityConfig `json:"connectivity"`
+	// HTTP configures the client of every outbound request to the internet
+	HTTP *HTTPConfig `json:"http"`
+	// IncidentAPI configures access to the incident API
+	IncidentAPI *IncidentAPIConfig `json:"incident_api"`
+}
+
+// IncidentAPIConfig configures access to the incident API
+type IncidentAPIConfig struct {
+	// Auth authenticates the fetches of the incidents. Without it they
+	// are anonymous
+	Auth *AuthConfig `json:"auth"`
+}
+
+// AuthConfig configures authentication. Credentials are never written
+// here but read from "env:NAME" or "file:/path"
+type AuthConfig struct {
+	// Type is "bearer", "api_key", "basic" or "oauth2"
+	Type string `json:"type"`
+	// Token is the bearer token
+	Token string `json:"token"`
+	// Header carries the API key, "X-API-Key" by default
+	Header string `json:"header"`
+	Key    string `json:"key"`
+	// Username and Password are the HTTP basic credentials
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TokenURL, ClientID, ClientSecret and Scopes configure the OAuth2
+	// client credentials grant
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// HTTPConfig configures outbound HTTP requests
+type HTTPConfig struct {
+	// Proxy is the URL of a proxy for every request. Without it, the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables are used
+	Proxy string `json:"proxy"`
+	// NoProxy lists the hosts reached directly despite Proxy, e.g. local
+	// lights: host names, IP addresses or domains like ".local"
+	NoProxy []string `json:"no_proxy"`
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system ones
+	CAFile string `json:"ca_file"`
+	// CertFile and KeyFile are a PEM client certificate and key for
+	// servers that require mutual TLS
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// UserAgent replaces the default "my-incident-checker/<version> (<node>)"
+	UserAgent string `json:"user_agent"`
+}
+
+// ConnectivityConfig configures the connectivity monitor
+type ConnectivityConfig struct {
+	// Interval is the time between probe rounds, e.g. "30s"
+	Interval string `json:"interval"`
+	// Timeout bounds each probe, e.g. "5s"
+	Timeout string `json:"timeout"`
+	// DownAfter and UpAfter are the numbers of consecutive failed or
+	// successful rounds that change the state
+	DownAfter int `json:"down_after"`
+	UpAfter   int `json:"up_after"`
+	// Quorum is the number of failed probes that fail a round, all of
+	// them when unset. The gateway probe only counts when it is the only
+	// kind of probe
+	Quorum int           `json:"quorum"`
+	Probes []ProbeConfig `json:"probes"`
+	// NetworkLamps are shown while the network is down
+	NetworkLamps string `json:"network_lamps"`
+	// APILamps are shown while the incident API fails with the network up
+	APILamps string `json:"api_lamps"`
+	// APIDownAfter is the number of consecutive failed fetches after which
+	// the incident API counts as down
+	APIDownAfter int `json:"api_down_after"`
+}
+
+// ProbeConfig configures one connectivity probe
+type ProbeConfig struct {
+	// Type is "dns", "tcp", "http" or "gateway"
+	Type string `json:"type"`
+	// Target is a host name for dns, a host:port for tcp, a URL for http
+	// and an optional port for gateway
+	Target string `json:"target"`
+}
+
+// WatchdogConfig configures the poll loop watchdog
+type WatchdogConfig struct {
+	// Timeout is how long the poll loop may go without completing a cycle,
+	// e.g. "2m"
+	Timeout string `json:"timeout"`
+	// StaleLamps are shown while the loop is stuck, e.g. "yellow=blink"
+	StaleLamps string `json:"stale_lamps"`
+	// Exit exits the checker when the loop is stuck, for the supervisor to
+	// restart it
+	Exit bool `json:"exit"`
+}
+
+// HeartbeatConfig configures the heartbeat
+type HeartbeatConfig struct {
+	// Interval is the time between heartbeats, e.g. "5m"
+	Interval string `json:"interval"`
+	// MaxPollAge is how long the checker counts as healthy without a
+	// successful poll, e.g. "2m"
+	MaxPollAge string `json:"max_poll_age"`
+	// FailureThreshold is the number of consecutive failed heartbeats
+	// after which a target counts as failing
+	FailureThreshold int `json:"failure_threshold"`
+	// FailureLamps are lit on top of the incident state while a target is
+	// failing, e.g. "yellow=blink"
+	FailureLamps string                  `json:"failure_lamps"`
+	Targets      []HeartbeatTargetConfig `json:"targets"`
+}
+
+// HeartbeatTargetConfig configures one monitoring service
+type HeartbeatTargetConfig struct {
+	Name string `json:"name"`
+	// Type is "deadmanssnitch", "healthchecks", "uptimekuma" or "webhook"
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Headers are sent with webhook requests
+	Headers map[string]string `json:"headers"`
+}
+
+// DisplayConfig configures the character display
+type DisplayConfig struct {
+	// Driver is "hd44780" (options bus, address) or "terminal" (option
+	// path, stdout when unset)
+	Driver  string  `json:"driver"`
+	Cols    int     `json:"cols"`
+	Rows    int     `json:"rows"`
+	Options Options `json:"options"`
+}
+
+// ButtonConfig configures an acknowledge button
+type ButtonConfig struct {
+	Name string `json:"name"`
+	// Driver is "gpio" (options chip, line, active_low) or "evdev"
+	// (options device, key)
+	Driver  string  `json:"driver"`
+	Options Options `json:"options"`
+	// LongPress is the duration from which a press snoozes, e.g. "2s"
+	LongPress string `json:"long_press"`
+	// Snooze is how long a long press snoozes alerts, e.g. "30m"
+	Snooze string `json:"snooze"`
+}
+
+// WallConfig configures the service wall strip
+type WallConfig struct {
+	// Driver is "wled", "spi" or "virtual"
+	Driver  string  `json:"driver"`
+	Pixels  int     `json:"pixels"`
+	Options Options `json:"options"`
+	// StaleAfter is a duration such as "12h" after which services missing
+	// from the feed are dimmed
+	StaleAfter string `json:"stale_after"`
+}
+
+// ZoneConfig binds a configured light to the services and components it
+// reflects
+type ZoneConfig struct {
+	Name string `json:"name"`
+	// Light is the name of a light in Lights
+	Light      string   `json:"light"`
+	Services   []string `json:"services"`
+	Components []string `json:"components"`
+}
+
+// LightConfig configures one light device. Driver options may be nested
+// under "options" or written next to the driver, e.g.
+// {"driver": "serial", "port": "/dev/ttyUSB0"}
+type LightConfig struct {
+	Name    string  `json:"name"`
+	Driver  string  `json:"driver"`
+	Options Options `json:"options"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *LightConfig) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var out LightConfig
+	for key, value := range raw {
+		var err error
+		switch key {
+		case "name":
+			err = json.Unmarshal(value, &out.Name)
+		case "driver":
+			err = json.Unmarshal(value, &out.Driver)
+		case "options":
+			var options Options
+			if err = json.Unmarshal(value, &options); err == nil {
+				out.Options = mergeOptions(out.Options, options)
+			}
+		default:
+			var options Options
+			inline, _ := json.Marshal(map[string]json.RawMessage{key: value})
+			if err = json.Unmarshal(inline, &options); err == nil {
+				out.Options = mergeOptions(out.Options, options)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("light %s: %w", key, err)
+		}
+	}
+	*l = out
+	return nil
+}
+
+func mergeOptions(dst, src Options) Options {
+	if dst == nil {
+		dst = make(Options, len(src))
+	}
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
+
+// Options holds driver settings. Values may be written in JSON as strings,
+// numbers or booleans and are kept as strings
+type Options map[string]string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *Options) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	out := make(Options, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			out[key] = v
+		case float64:
+			out[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			out[key] = strconv.FormatBool(v)
+		case nil:
+		default:
+			return fmt.Errorf("option %q must be a string, number or boolean", key)
+		}
+	}
+	*o = out
+	return nil
+}
+
+// Path returns the configuration file path from CONFIG_FILE, defaulting to
+// config.json in the working directory
+func Path() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return defaultConfigFile
+}
+
+// Load reads the configuration file. A missing file yields an empty
+// configuration so the checker runs with its defaults
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for i, light := range cfg.Lights {
+		if light.Driver == "" {
+			return nil, fmt.Errorf("config file %s: light %d has no driver", path, i+1)
+		}
+		if light.Name == "" {
+			cfg.Lights[i].Name = fmt.Sprintf("%s-%d", light.Driver, i+1)
+		}
+	}
+
+	if cfg.Wall != nil && (cfg.Wall.Driver == "" || cfg.Wall.Pixels <= 0) {
+		return nil, fmt.Errorf("config file %s: wall needs a driver and a pixel count", path)
+	}
+
+	if cfg.Display != nil && cfg.Display.Driver == "" {
+		return nil, fmt.Errorf("config file %s: display has no driver", path)
+	}
+
+	for i, button := range cfg.Buttons {
+		if button.Driver == "" {
+			return nil, fmt.Errorf("config file %s: button %d has no driver", path, i+1)
+		}
+		if button.Name == "" {
+			cfg.Buttons[i].Name = fmt.Sprintf("%s-%d", button.Driver, i+1)
+		}
+	}
+
+	if cfg.Heartbeat != nil {
+		for i, target := range cfg.Heartbeat.Targets {
+			if target.Type == "" || target.URL == "" {
+				return nil, fmt.Errorf("config file %s: heartbeat target %d needs a type and a url", path, i+1)
+			}
+			if target.Name == "" {
+				cfg.Heartbeat.Targets[i].Name = fmt.Sprintf("%s-%d", target.Type, i+1)
+			}
+		}
+	}
+
+	if cfg.HTTP != nil && (cfg.HTTP.CertFile == "") != (cfg.HTTP.KeyFile == "") {
+		return nil, fmt.Errorf("config file %s: http client certificate needs both a cert_file and a key_file", path)
+	}
+
+	if cfg.IncidentAPI != nil && cfg.IncidentAPI.Auth != nil && cfg.IncidentAPI.Auth.Type == "" {
+		return nil, fmt.Errorf("config file %s: incident_api auth has no type", path)
+	}
+
+	lightNames := make(map[string]bool, len(cfg.Lights))
+	for _, light := range cfg.Lights {
+		lightNames[light.Name] = true
+	}
+	zoned := make(map[string]bool, len(cfg.Zones))
+	for i, zone := range cfg.Zones {
+		if !lightNames[zone.Light] {
+			return nil, fmt.Errorf("config file %s: zone %d uses unknown light %q", path, i+1, zone.Light)
+		}
+		if zoned[zone.Light] {
+			return nil, fmt.Errorf("config file %s: light %q is bound to more than one zone", path, zone.Light)
+		}
+		zoned[zone.Light] = true
+		if zone.Name == "" {
+			cfg.Zones[i].Name = zone.Light
+		}
+	}
+	return &cfg, nil
+}
+
+// ZoneLight returns the zone a light is bound to
+func (c *Config) ZoneLight(name string) (ZoneConfig, bool) {
+	for _, zone := range c.Zones {
+		if zone.Light == name {
+			return zone, true
+		}
+	}
+	return ZoneConfig{}, false
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a configuration file and returns its path
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(cfg, &Config{}) {
+		t.Errorf("Load() = %+v, want an empty configuration", cfg)
+	}
+}
+
+func TestLightOptions(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `{
+		"lights": [
+			{"driver": "serial", "port": "/dev/ttyUSB0", "baud": 9600, "options": {"profile": "adafruit"}},
+			{"name": "desk", "driver": "gpio", "options": {"pins": "red=17", "active_low": true, "chip": null}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []LightConfig{
+		{Name: "serial-1", Driver: "serial", Options: Options{"port": "/dev/ttyUSB0", "baud": "9600", "profile": "adafruit"}},
+		{Name: "desk", Driver: "gpio", Options: Options{"pins": "red=17", "active_low": "true"}},
+	}
+	if !reflect.DeepEqual(cfg.Lights, want) {
+		t.Errorf("Lights = %+v, want %+v", cfg.Lights, want)
+	}
+}
+
+func TestDurations(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `{
+		"buttons": [{"driver": "gpio", "long_press": "1500ms", "snooze": "45m"}],
+		"wall": {"driver": "virtual", "pixels": 8, "stale_after": "12h"},
+		"heartbeat": {"interval": "1m", "max_poll_age": "3m"},
+		"watchdog": {"timeout": "90s"},
+		"connectivity": {"interval": "10s", "timeout": "2s"}
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// Durations are kept as written, for the checker to parse with its
+	// defaults
+	got := []string{
+		cfg.Buttons[0].LongPress, cfg.Buttons[0].Snooze, cfg.Wall.StaleAfter,
+		cfg.Heartbeat.Interval, cfg.Heartbeat.MaxPollAge, cfg.Watchdog.Timeout,
+		cfg.Connectivity.Interval, cfg.Connectivity.Timeout,
+	}
+	want := []string{"1500ms", "45m", "12h", "1m", "3m", "90s", "10s", "2s"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("durations = %q, want %q", got, want)
+	}
+	if cfg.Buttons[0].Name != "gpio-1" {
+		t.Errorf("button name = %q, want gpio-1", cfg.Buttons[0].Name)
+	}
+}
+
+func TestZones(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `{
+		"lights": [{"name": "main", "driver": "serial"}, {"name": "db", "driver": "wled"}],
+		"zones": [{"light": "db", "services": ["postgres"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	zone, ok := cfg.ZoneLight("db")
+	if !ok || zone.Name != "db" || !reflect.DeepEqual(zone.Services, []string{"postgres"}) {
+		t.Errorf("ZoneLight(db) = %+v, %v", zone, ok)
+	}
+	if _, ok := cfg.ZoneLight("main"); ok {
+		t.Error("ZoneLight(main) found a zone")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"invalid json", `{"lights": [`, "failed to parse"},
+		{"light without driver", `{"lights": [{"name": "x"}]}`, "light 1 has no driver"},
+		{"nested option", `{"lights": [{"driver": "serial", "port": {"path": "x"}}]}`, "must be a string, number or boolean"},
+		{"wall without pixels", `{"wall": {"driver": "virtual"}}`, "wall needs a driver and a pixel count"},
+		{"display without driver", `{"display": {"cols": 20}}`, "display has no driver"},
+		{"button without driver", `{"buttons": [{"name": "desk"}]}`, "button 1 has no driver"},
+		{"heartbeat target without url", `{"heartbeat": {"targets": [{"type": "webhook"}]}}`, "heartbeat target 1 needs a type and a url"},
+		{"client key without cert", `{"http": {"key_file": "key.pem"}}`, "needs both a cert_file and a key_file"},
+		{"auth without type", `{"incident_api": {"auth": {"token": "env:TOKEN"}}}`, "incident_api auth has no type"},
+		{"zone with unknown light", `{"lights": [{"name": "a", "driver": "serial"}], "zones": [{"light": "b"}]}`, `unknown light "b"`},
+		{"light in two zones", `{"lights": [{"name": "a", "driver": "serial"}], "zones": [{"light": "a"}, {"light": "a"}]}`, "more than one zone"},
+	}
+	for _, test := range tests {
+		_, err := Load(writeConfig(t, test.data))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: Load() error = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	if got := Path(); got != "config.json" {
+		t.Errorf("Path() = %q, want config.json", got)
+	}
+	t.Setenv("CONFIG_FILE", "/etc/incident-checker.json")
+	if got := Path(); got != "/etc/incident-checker.json" {
+		t.Errorf("Path() = %q with CONFIG_FILE set", got)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/lights"
+	"my-incident-checker/network"
+	"my-incident-checker/node"
+	"my-incident-checker/poll"
+	"my-incident-checker/types"
+)
+
+const (
+	// networkSource is the arbiter claim shown while our network is down
+	networkSource = "network"
+	// apiSource is the arbiter claim shown while the incident API fails
+	// with our network up
+	apiSource = "incident-api"
+	// defaultAPIDownAfter is the number of consecutive failed fetches
+	// after which the incident API counts as down
+	defaultAPIDownAfter = 3
+)
+
+var (
+	defaultNetworkLamps = lights.TowerState{Yellow: lights.ModeOn, Green: lights.ModeBlink}
+	defaultAPILamps     = lights.TowerState{Yellow: lights.ModeBlink, Green: lights.ModeBlink}
+)
+
+// connectivity tells an outage of our network apart from one of the
+// incident API, on the light and in notifications. Fetch failures while
+// the network monitor reports the network down are put down to the network
+type connectivity struct {
+	monitor      *network.Monitor
+	arbiter      *lights.Arbiter
+	logger       *types.Logger
+	nodeName     string
+	networkLamps lights.TowerState
+	apiLamps     lights.TowerState
+	apiDownAfter int
+	notify       func(message string)
+	// diagnose, when set, logs network diagnostics on outages
+	diagnose func()
+
+	mu               sync.Mutex
+	networkDownSince time.Time
+	networkReason    string
+	fetchFailures    int
+	apiDownSince     time.Time
+}
+
+// startConnectivity starts the connectivity monitor and watches the
+// fetches of poller, logging network diagnostics on outages. It must be
+// called before the poller runs
+func startConnectivity(checkerConfig *config.Config, poller *poll.Poller, arbiter *lights.Arbiter, logger *types.Logger) func() {
+	cfg := checkerConfig.Connectivity
+	if cfg == nil {
+		cfg = &config.ConnectivityConfig{}
+	}
+	interval, err := durationOption(cfg.Interval, network.DefaultMonitorInterval)
+	if err != nil {
+		logger.ErrorLog.Printf("Invalid connectivity interval: %s", err.Error())
+		interval = network.DefaultMonitorInterval
+	}
+	timeout, err := durationOption(cfg.Timeout, network.DefaultProbeTimeout)
+	if err != nil {
+		logger.ErrorLog.Printf("Invalid connectivity timeout: %s", err.Error())
+		timeout = network.DefaultProbeTimeout
+	}
+	endpoints := diagnosticEndpoints(checkerConfig, logger)
+
+	probes := connectivityProbes(cfg.Probes, logger)
+	c := &connectivity{
+		monitor:      network.NewMonitor(probes, interval, timeout, logger),
+		arbiter:      arbiter,
+		logger:       logger,
+		nodeName:     node.GetNodeName(),
+		networkLamps: lampsOption(cfg.NetworkLamps, defaultNetworkLamps, "connectivity network_lamps", logger),
+		apiLamps:     lampsOption(cfg.APILamps, defaultAPILamps, "connectivity api_lamps", logger),
+		apiDownAfter: defaultAPIDownAfter,
+		notify:       func(message string) { notifyWithin(message, logger) },
+		diagnose:     func() { logDiagnostics(endpoints, logger) },
+	}
+	if cfg.DownAfter > 0 {
+		c.monitor.DownAfter = cfg.DownAfter
+	}
+	if cfg.UpAfter > 0 {
+		c.monitor.UpAfter = cfg.UpAfter
+	}
+	c.monitor.Quorum = cfg.Quorum
+	if cfg.APIDownAfter > 0 {
+		c.apiDownAfter = cfg.APIDownAfter
+	}
+	c.monitor.OnChange = c.networkChanged
+	poller.OnFetch = c.fetched
+
+	logger.InfoLog.Printf("Monitoring connectivity with %d probes every %s", len(probes), interval)
+	stop := make(chan struct{})
+	go c.monitor.Run(stop)
+	return func() { close(stop) }
+}
+
+// connectivityProbes creates the configured probes, defaulting to
+// network.DefaultProbes. Invalid probes are logged and skipped
+func connectivityProbes(configs []config.ProbeConfig, logger *types.Logger) []network.Probe {
+	if len(configs) == 0 {
+		return network.DefaultProbes()
+	}
+	var probes []network.Probe
+	for _, probeConfig := range configs {
+		probe, err := network.NewProbe(probeConfig.Type, probeConfig.Target)
+		if err != nil {
+			logger.ErrorLog.Printf("Invalid connectivity probe: %s", err.Error())
+			continue
+		}
+		probes = append(probes, probe)
+	}
+	return probes
+}
+
+// lampsOption parses a configured lamp state, falling back to the default
+func lampsOption(value string, fallback lights.TowerState, name string, logger *types.Logger) lights.TowerState {
+	if value == "" {
+		return fallback
+	}
+	lamps, err := lights.ParseTowerState(value)
+	if err != nil {
+		logger.ErrorLog.Printf("Invalid %s: %s", name, err.Error())
+		return fallback
+	}
+	return lamps
+}
+
+// networkChanged shows and notifies outages of our network. While the
+// network is down, notifications can't get out; the recovery notification
+// tells how long it lasted and why
+func (c *connectivity) networkChanged(up bool, round network.Round) {
+	c.mu.Lock()
+	var message string
+	if up {
+		message = fmt.Sprintf("%s: network is back up after %s (%s)",
+			c.nodeName, round.Time.Sub(c.networkDownSince).Round(time.Second), c.networkReason)
+		c.networkDownSince = time.Time{}
+	} else {
+		c.networkDownSince = round.Time
+		c.networkReason = round.Reason()
+		message = fmt.Sprintf("%s: network is down: %s", c.nodeName, c.networkReason)
+		// Fetch failures are no longer the incident API's fault
+		c.apiDownSince = time.Time{}
+	}
+	c.mu.Unlock()
+
+	var err error
+	if up {
+		err = c.arbiter.Release(networkSource)
+	} else {
+		if err = c.arbiter.Claim(networkSource, lights.PriorityConnectivity, c.networkLamps, 0); err == nil {
+			err = c.arbiter.Release(apiSource)
+		}
+	}
+	if err != nil {
+		c.logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+	}
+	if !up && c.diagnose != nil {
+		c.diagnose()
+	}
+	c.notify(message)
+}
+
+// fetched tracks fetches of the incidents. Consecutive failures with the
+// network up mean the incident API is down
+func (c *connectivity) fetched(fetchErr error) {
+	c.mu.Lock()
+	var message string
+	var claim, release bool
+	if fetchErr == nil {
+		if !c.apiDownSince.IsZero() {
+			message = fmt.Sprintf("%s: incident API is reachable again after %s",
+				c.nodeName, time.Since(c.apiDownSince).Round(time.Second))
+			c.apiDownSince = time.Time{}
+			release = true
+		}
+		c.fetchFailures = 0
+	} else {
+		c.fetchFailures++
+		if c.apiDownSince.IsZero() && c.fetchFailures >= c.apiDownAfter && c.monitor.Up() {
+			c.apiDownSince = time.Now()
+			message = fmt.Sprintf("%s: incident API is down (%d failed fetches, network up): %s",
+				c.nodeName, c.fetchFailures, fetchErr.Error())
+			claim = true
+		}
+	}
+	c.mu.Unlock()
+
+	var err error
+	switch {
+	case claim:
+		c.logger.WarnLog.Printf("Incident API is down with the network up: %s", fetchErr.Error())
+		err = c.arbiter.Claim(apiSource, lights.PriorityConnectivity, c.apiLamps, 0)
+		if c.diagnose != nil {
+			go c.diagnose()
+		}
+	case release:
+		c.logger.InfoLog.Printf("Incident API is reachable again")
+		err = c.arbiter.Release(apiSource)
+	}
+	if err != nil {
+		c.logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+	}
+	if message != "" {
+		go c.notify(message)
+	}
+}
@@ -0,0 +1,91 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client talks to the control server of a running checker
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient creates a Client for the server at addr
+func NewClient(addr string) *Client {
+	baseURL := addr
+	if !strings.Contains(addr, "://") {
+		baseURL = "http://" + addr
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		// The control server listens on localhost, so the shared outbound
+		// client's proxy and certificates don't apply
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Status fetches the status
+func (c *Client) Status() (*Status, error) {
+	var status Status
+	if err := c.do(http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SetOverride forces the light to a state
+func (c *Client) SetOverride(req OverrideRequest) (*Override, error) {
+	var override Override
+	if err := c.do(http.MethodPost, "/override", req, &override); err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// ClearOverride returns control to the other claims
+func (c *Client) ClearOverride() error {
+	return c.do(http.MethodDelete, "/override", nil, nil)
+}
+
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("checker not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// Package control serves the status of the checker and accepts manual
+// light overrides over HTTP
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/types"
+)
+
+// DefaultAddr is where the control server listens unless CONTROL_ADDR is
+// set. It is only reachable from the machine itself
+const DefaultAddr = "127.0.0.1:8075"
+
+// OverrideSource is the arbiter claim posted by overrides
+const OverrideSource = "override"
+
+// Addr returns the control server address from CONTROL_ADDR
+func Addr() string {
+	if addr := os.Getenv("CONTROL_ADDR"); addr != "" {
+		return addr
+	}
+	return DefaultAddr
+}
+
+// OverrideRequest sets the light to State, for Duration when set
+type OverrideRequest struct {
+	State string `json:"state"`
+	// Duration is e.g. "15m"; empty keeps the override until cleared
+	Duration string `json:"duration,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Override is an active manual override
+type Override struct {
+	State  string     `json:"state"`
+	Reason string     `json:"reason,omitempty"`
+	Since  time.Time  `json:"since"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// ClaimStatus is one active claim on the light
+type ClaimStatus struct {
+	Source   string     `json:"source"`
+	Priority int        `json:"priority"`
+	State    string     `json:"state"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+// Status is what the checker currently shows and why
+type Status struct {
+	// Shown is the state of the winning claim with any overlays, "off"
+	// without claims
+	Shown    string        `json:"shown"`
+	Source   string        `json:"source,omitempty"`
+	Claims   []ClaimStatus `json:"claims"`
+	Override *Override     `json:"override,omitempty"`
+}
+
+// Server is the control HTTP server
+type Server struct {
+	arbiter *lights.Arbiter
+	logger  *types.Logger
+	server  *http.Server
+
+	mu       sync.Mutex
+	override *Override
+	now      func() time.Time
+}
+
+// NewServer creates a control server for the arbiter on addr
+func NewServer(addr string, arbiter *lights.Arbiter, logger *types.Logger) *Server {
+	s := &Server{arbiter: arbiter, logger: logger, now: time.Now}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/override", s.handleOverride)
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Start listens on the address and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to start control server: %w", err)
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.ErrorLog.Printf("Control server stopped: %s", err.Error())
+		}
+	}()
+	return nil
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// Handler returns the HTTP handler, for tests
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// SetOverride forces the light to a state
+func (s *Server) SetOverride(req OverrideRequest, requester string) (*Override, error) {
+	state, err := lights.ParseTowerState(req.State)
+	if err != nil {
+		return nil, err
+	}
+	var ttl time.Duration
+	if req.Duration != "" {
+		if ttl, err = time.ParseDuration(req.Duration); err != nil {
+			return nil, fmt.Errorf("invalid duration %q: %w", req.Duration, err)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("duration must be positive")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	override := &Override{State: state.String(), Reason: req.Reason, Since: now}
+	if ttl > 0 {
+		until := now.Add(ttl)
+		override.Until = &until
+	}
+	if err := s.arbiter.Claim(OverrideSource, lights.PriorityOverride, state, ttl); err != nil {
+		// The claim stands and is shown once the light works again
+		s.logger.ErrorLog.Printf("Failed to apply override: %s", err.Error())
+	}
+	s.override = override
+	if ttl > 0 {
+		time.AfterFunc(ttl, func() { s.expire(override) })
+	}
+
+	duration := "until cleared"
+	if ttl > 0 {
+		duration = "for " + ttl.String()
+	}
+	s.logger.WarnLog.Printf("Light overridden to %s %s by %s (reason: %q)", override.State, duration, requester, req.Reason)
+	return override, nil
+}
+
+// expire forgets an override once its claim lapsed, unless it was replaced
+func (s *Server) expire(override *Override) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.override != override {
+		return
+	}
+	s.override = nil
+	s.logger.InfoLog.Printf("Light override to %s expired, incident-driven control resumes", override.State)
+}
+
+// ClearOverride returns control to the other claims
+func (s *Server) ClearOverride(requester string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.override == nil {
+		return nil
+	}
+	s.override = nil
+	s.logger.WarnLog.Printf("Light override cleared by %s", requester)
+	return s.arbiter.Release(OverrideSource)
+}
+
+// Status returns the current status
+func (s *Server) Status() Status {
+	claims := s.arbiter.Claims()
+	status := Status{Shown: s.arbiter.Shown().String(), Claims: make([]ClaimStatus, 0, len(claims))}
+	for i, claim := range claims {
+		entry := ClaimStatus{
+			Source:   claim.Source,
+			Priority: int(claim.Priority),
+			State:    claim.Lamps().String(),
+		}
+		if !claim.Expires.IsZero() {
+			expires := claim.Expires
+			entry.Expires = &expires
+		}
+		if i == 0 {
+			status.Source = claim.Source
+		}
+		status.Claims = append(status.Claims, entry)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.override != nil {
+		override := *s.override
+		status.Override = &override
+	}
+	return status
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+func (s *Server) handleOverride(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		var req OverrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		override, err := s.SetOverride(req, r.RemoteAddr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, override)
+	case http.MethodDelete:
+		if err := s.ClearOverride(r.RemoteAddr); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,79 @@
+package control
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/types"
+)
+
+func TestOverride(t *testing.T) {
+	var logs bytes.Buffer
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(&logs, "INFO: ", 0),
+		WarnLog:  log.New(&logs, "WARN: ", 0),
+		ErrorLog: log.New(&logs, "ERROR: ", 0),
+	}
+	light := lights.NewRecorderLight(io.Discard)
+	arbiter := lights.NewArbiter(light)
+	defer arbiter.Close()
+	arbiter.Claim("incidents", lights.PriorityIncident, lights.GreenState{}, 0)
+
+	server := NewServer("", arbiter, logger)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	client := NewClient(ts.URL)
+
+	if _, err := client.SetOverride(OverrideRequest{State: "purple"}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("SetOverride(purple) error = %v, want bad request", err)
+	}
+
+	set, err := client.SetOverride(OverrideRequest{State: "red=blink,buzzer=on", Duration: "50ms", Reason: "drill"})
+	if err != nil {
+		t.Fatalf("SetOverride() error = %v", err)
+	}
+	if set.Until == nil || set.State != "red=blink,buzzer=on" {
+		t.Errorf("SetOverride() = %+v", set)
+	}
+	if light.State() != (lights.TowerState{Red: lights.ModeBlink, Buzzer: lights.ModeOn}) {
+		t.Errorf("light shows %s, want the override", light.State())
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Source != OverrideSource || status.Override == nil || status.Override.Reason != "drill" || len(status.Claims) != 2 {
+		t.Errorf("Status() = %+v, want the override on top of the incident claim", status)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	status, _ = client.Status()
+	if status.Source != "incidents" || status.Override != nil {
+		t.Errorf("Status() = %+v after expiry, want incidents", status)
+	}
+	if light.State() != (lights.TowerState{Green: lights.ModeOn}) {
+		t.Errorf("light shows %s after expiry, want green", light.State())
+	}
+
+	client.SetOverride(OverrideRequest{State: "yellow"})
+	if err := client.ClearOverride(); err != nil {
+		t.Fatalf("ClearOverride() error = %v", err)
+	}
+	if status, _ := client.Status(); status.Source != "incidents" {
+		t.Errorf("Status() = %+v after clear, want incidents", status)
+	}
+
+	for _, want := range []string{"WARN: Light overridden to red=blink,buzzer=on for 50ms", "override to red=blink,buzzer=on expired", "WARN: Light override cleared"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q:\n%s", want, logs.String())
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/display"
+	"my-incident-checker/lights"
+	"my-incident-checker/poll"
+	"my-incident-checker/types"
+	"my-incident-checker/wall"
+)
+
+// openConfiguredLights opens every configured light and combines them.
+// Devices that fail to open are logged and skipped so the others still work
+func openConfiguredLights(devices []config.LightConfig, logger *types.Logger) (*lights.CompositeLight, error) {
+	var members []lights.Member
+	for _, device := range devices {
+		light, closeLight, err := openDevice(device)
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to open light %s (%s): %s", device.Name, device.Driver, err.Error())
+			fmt.Printf("Failed to open light %s: %s\n", device.Name, err.Error())
+			continue
+		}
+		logger.InfoLog.Printf("Using light %s (%s)", device.Name, device.Driver)
+		fmt.Printf("Using light %s (%s)\n", device.Name, device.Driver)
+		members = append(members, lights.Member{Name: device.Name, Light: light, Close: closeLight})
+	}
+
+	if len(devices) > 0 && len(members) == 0 {
+		return nil, fmt.Errorf("none of the %d configured lights could be opened", len(devices))
+	}
+	return lights.NewCompositeLight(logger, members...), nil
+}
+
+// openZones opens the lights bound to zones. Each gets its own controller so
+// it only receives state changes, and its own arbiter for the claims on it.
+// Zones whose light fails to open are logged and skipped
+func openZones(cfg *config.Config, logger *types.Logger) ([]poll.Zone, func()) {
+	var zones []poll.Zone
+	var closers []func()
+	for _, device := range cfg.Lights {
+		zoneConfig, ok := cfg.ZoneLight(device.Name)
+		if !ok {
+			continue
+		}
+		light, closeLight, err := openDevice(device)
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to open light %s for zone %s: %s", device.Name, zoneConfig.Name, err.Error())
+			fmt.Printf("Failed to open light %s: %s\n", device.Name, err.Error())
+			continue
+		}
+		logger.InfoLog.Printf("Using light %s (%s) for zone %s", device.Name, device.Driver, zoneConfig.Name)
+
+		name := device.Name
+		controller := lights.NewController(light, lights.DefaultCoalesceWindow, lights.DefaultResyncInterval)
+		arbiter := lights.NewArbiter(controller)
+		if err := arbiter.Claim(poll.ClaimSource, lights.PriorityIncident, lights.GreenState{}, 0); err != nil {
+			logger.ErrorLog.Printf("Failed to set initial state of zone %s: %s", zoneConfig.Name, err.Error())
+		}
+		closers = append(closers, func() {
+			arbiter.Close()
+			if err := controller.Close(); err != nil {
+				logger.ErrorLog.Printf("Error flushing zone %s: %s", zoneConfig.Name, err.Error())
+			}
+			if closeLight != nil {
+				if err := closeLight(); err != nil {
+					logger.ErrorLog.Printf("Error closing light %s: %s", name, err.Error())
+				}
+			}
+		})
+		zones = append(zones, poll.Zone{
+			Name:       zoneConfig.Name,
+			Services:   zoneConfig.Services,
+			Components: zoneConfig.Components,
+			Light:      controller,
+			Arbiter:    arbiter,
+		})
+	}
+	return zones, func() {
+		for _, closeZone := range closers {
+			closeZone()
+		}
+	}
+}
+
+// unzonedLights returns the configured lights that show the overall state
+func unzonedLights(cfg *config.Config) []config.LightConfig {
+	var devices []config.LightConfig
+	for _, device := range cfg.Lights {
+		if _, ok := cfg.ZoneLight(device.Name); !ok {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// openDevice opens a single configured light with its driver
+func openDevice(device config.LightConfig) (lights.Light, func() error, error) {
+	opened, err := lights.OpenDevice(device.Driver, device.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return opened.Light, opened.Close, nil
+}
+
+// envLightOptions reads the light settings from the environment:
+// SERIAL_PORT, SERIAL_BAUD, SERIAL_PROFILE, SERIAL_VENDOR_ID,
+// SERIAL_PRODUCT_ID, GPIO_PINS, GPIO_CHIP, GPIO_ACTIVE_LOW and
+// LIGHT_STATE_LOG. Only the options of driver are kept, unless it is the
+// auto driver
+func envLightOptions(driver string) map[string]string {
+	env := map[string]string{
+		"port":       os.Getenv("SERIAL_PORT"),
+		"baud":       os.Getenv("SERIAL_BAUD"),
+		"profile":    os.Getenv("SERIAL_PROFILE"),
+		"vendor_id":  os.Getenv("SERIAL_VENDOR_ID"),
+		"product_id": os.Getenv("SERIAL_PRODUCT_ID"),
+		"pins":       os.Getenv("GPIO_PINS"),
+		"chip":       os.Getenv("GPIO_CHIP"),
+		"active_low": os.Getenv("GPIO_ACTIVE_LOW"),
+		"path":       os.Getenv("LIGHT_STATE_LOG"),
+	}
+
+	known := func(string) bool { return true }
+	if d, ok := lights.LookupDriver(driver); ok {
+		names := make(map[string]bool, len(d.Options))
+		for _, option := range d.Options {
+			names[option.Name] = true
+		}
+		known = func(name string) bool { return names[name] }
+	}
+
+	options := make(map[string]string)
+	for name, value := range env {
+		if value != "" && known(name) {
+			options[name] = value
+		}
+	}
+	return options
+}
+
+// lightDriver returns the driver chosen with LIGHT_DRIVER, auto when unset
+func lightDriver() string {
+	if driver := os.Getenv("LIGHT_DRIVER"); driver != "" {
+		return driver
+	}
+	return lights.AutoDriver
+}
+
+// openWall opens the service wall strip
+func openWall(wallConfig *config.WallConfig) (*wall.Wall, func() error, error) {
+	staleAfter := wall.DefaultStaleAfter
+	if value := wallConfig.StaleAfter; value != "" {
+		var err error
+		if staleAfter, err = time.ParseDuration(value); err != nil {
+			return nil, nil, fmt.Errorf("invalid stale_after %q: %w", value, err)
+		}
+	}
+
+	var strip lights.Strip
+	var err error
+	options := wallConfig.Options
+	switch wallConfig.Driver {
+	case "wled":
+		segment := -1
+		if value := options["segment"]; value != "" {
+			if segment, err = strconv.Atoi(value); err != nil {
+				return nil, nil, fmt.Errorf("invalid segment %q: %w", value, err)
+			}
+		}
+		strip, err = lights.NewWLEDStrip(lights.WLEDConfig{URL: options["url"], Segment: segment}, wallConfig.Pixels)
+	case "spi":
+		strip, err = lights.NewSPIStrip(options["device"], wallConfig.Pixels)
+	case "virtual":
+		strip = lights.NewVirtualStrip(wallConfig.Pixels)
+	default:
+		err = fmt.Errorf("unknown wall driver %q", wallConfig.Driver)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return wall.New(strip, staleAfter), strip.Close, nil
+}
+
+// openDisplay opens the character display, 16x2 unless configured. The
+// returned function closes what the display writes to, after the display
+// itself is closed
+func openDisplay(displayConfig *config.DisplayConfig) (display.Display, func() error, error) {
+	cols, rows := displayConfig.Cols, displayConfig.Rows
+	if cols == 0 {
+		cols = 16
+	}
+	if rows == 0 {
+		rows = 2
+	}
+
+	options := displayConfig.Options
+	switch displayConfig.Driver {
+	case "hd44780":
+		bus := options["bus"]
+		if bus == "" {
+			bus = "/dev/i2c-1"
+		}
+		addr := uint64(display.DefaultI2CAddress)
+		if value := options["address"]; value != "" {
+			var err error
+			if addr, err = strconv.ParseUint(value, 0, 16); err != nil {
+				return nil, nil, fmt.Errorf("invalid address %q: %w", value, err)
+			}
+		}
+		lcd, err := display.NewHD44780(bus, uint16(addr), cols, rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lcd, func() error { return nil }, nil
+	case "terminal":
+		path := options["path"]
+		if path == "" {
+			return display.NewTerminal(os.Stdout, cols, rows), func() error { return nil }, nil
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		return display.NewTerminal(f, cols, rows), f.Close, nil
+	}
+	return nil, nil, fmt.Errorf("unknown display driver %q", displayConfig.Driver)
+}
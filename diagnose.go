@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/heartbeat"
+	"my-incident-checker/network"
+	"my-incident-checker/notify"
+	"my-incident-checker/poll"
+	"my-incident-checker/types"
+)
+
+// diagnoseTimeout bounds each step of the network diagnostics
+const diagnoseTimeout = 5 * time.Second
+
+// diagnosticEndpoints lists every endpoint the checker talks to: the
+// incident API and its token endpoint, the notification server, the
+// heartbeat targets and the connectivity probes
+func diagnosticEndpoints(cfg *config.Config, logger *types.Logger) []network.Endpoint {
+	urls := []string{poll.IncidentsEndpoint, notify.Endpoint}
+	if cfg.IncidentAPI != nil && cfg.IncidentAPI.Auth != nil && cfg.IncidentAPI.Auth.TokenURL != "" {
+		urls = append(urls, cfg.IncidentAPI.Auth.TokenURL)
+	}
+	if cfg.Heartbeat != nil && len(cfg.Heartbeat.Targets) > 0 {
+		for _, target := range cfg.Heartbeat.Targets {
+			urls = append(urls, target.URL)
+		}
+	} else {
+		urls = append(urls, heartbeat.DefaultEndpoint)
+	}
+
+	var probes []network.Probe
+	if cfg.Connectivity != nil {
+		probes = connectivityProbes(cfg.Connectivity.Probes, logger)
+	} else {
+		probes = network.DefaultProbes()
+	}
+	for _, probe := range probes {
+		switch probe := probe.(type) {
+		case network.HTTPProbe:
+			urls = append(urls, probe.URL)
+		case network.TCPProbe:
+			urls = append(urls, probe.Address)
+		}
+	}
+
+	endpoints := make([]network.Endpoint, 0, len(urls))
+	for _, u := range urls {
+		endpoint, err := network.ParseEndpoint(u)
+		if err != nil {
+			logger.ErrorLog.Printf("Skipping diagnostics of %s: %s", u, err.Error())
+			continue
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// logDiagnostics writes a network diagnostics report to the log
+func logDiagnostics(endpoints []network.Endpoint, logger *types.Logger) {
+	report := network.Diagnose(endpoints, diagnoseTimeout)
+	for _, line := range report.Lines() {
+		logger.WarnLog.Printf("%s", line)
+	}
+}
+
+// diagnose prints a network diagnostics report and fails when any
+// endpoint can't be reached
+func diagnose(out io.Writer) error {
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		return err
+	}
+	// Connect through the configured proxy and with the configured
+	// certificates, as the checker does
+	if err := configureHTTP(cfg.HTTP); err != nil {
+		return err
+	}
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+		WarnLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(os.Stderr, "", 0),
+	}
+	report := network.Diagnose(diagnosticEndpoints(cfg, logger), diagnoseTimeout)
+	if _, err := report.WriteTo(out); err != nil {
+		return err
+	}
+	if !report.OK() {
+		return fmt.Errorf("some endpoints are unreachable")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Package display shows incident details on character displays
+package display
+
+// Display is a character display of a fixed size
+type Display interface {
+	// Size returns the number of columns and rows
+	Size() (cols, rows int)
+	// Show replaces the content, one string per row. Rows are cut or
+	// padded to the width; missing rows are blank
+	Show(lines []string) error
+	Close() error
+}
+
+// fit returns exactly rows lines of exactly cols characters
+func fit(lines []string, cols, rows int) []string {
+	out := make([]string, rows)
+	for i := range out {
+		var line []rune
+		if i < len(lines) {
+			line = []rune(lines[i])
+		}
+		if len(line) > cols {
+			line = line[:cols]
+		}
+		for len(line) < cols {
+			line = append(line, ' ')
+		}
+		out[i] = string(line)
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package display
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+// fakeI2C records the bytes written to a PCF8574
+type fakeI2C struct {
+	writes []byte
+	closed bool
+}
+
+func (d *fakeI2C) Write(data []byte) (int, error) {
+	d.writes = append(d.writes, data...)
+	return len(data), nil
+}
+
+func (d *fakeI2C) Close() error {
+	d.closed = true
+	return nil
+}
+
+// decodeLCD reassembles the bytes the controller received from the enable
+// pulses, marking characters with their RS level
+type lcdByte struct {
+	data bool
+	b    byte
+}
+
+func decodeLCD(writes []byte) []lcdByte {
+	var nibbles []byte
+	var rs []bool
+	for i := 0; i+1 < len(writes); i += 2 {
+		// Every nibble is written with enable high, then low
+		if writes[i]&pinEnable == 0 || writes[i+1]&pinEnable != 0 {
+			return nil
+		}
+		nibbles = append(nibbles, writes[i]>>4)
+		rs = append(rs, writes[i]&pinRS != 0)
+	}
+	// The first four nibbles switch to 4-bit mode
+	var out []lcdByte
+	for i := 4; i+1 < len(nibbles); i += 2 {
+		out = append(out, lcdByte{data: rs[i], b: nibbles[i]<<4 | nibbles[i+1]})
+	}
+	return out
+}
+
+func TestHD44780(t *testing.T) {
+	dev := &fakeI2C{}
+	var slept time.Duration
+	lcd, err := newHD44780(dev, 16, 2, func(d time.Duration) { slept += d })
+	if err != nil {
+		t.Fatalf("newHD44780() error = %v", err)
+	}
+	if init := dev.writes[:8]; !reflect.DeepEqual(init, []byte{0x3C, 0x38, 0x3C, 0x38, 0x3C, 0x38, 0x2C, 0x28}) {
+		t.Errorf("init nibbles = % X", init)
+	}
+	if slept < 50*time.Millisecond {
+		t.Errorf("slept %s during init, want the power-on delay", slept)
+	}
+	got := decodeLCD(dev.writes)
+	want := []lcdByte{{b: cmdFunctionSet}, {b: cmdDisplayOn}, {b: cmdClear}, {b: cmdEntryMode}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("init commands = %v, want %v", got, want)
+	}
+
+	dev.writes = nil
+	if err := lcd.Show([]string{"API OUTAGE", "Down é"}); err != nil {
+		t.Fatal(err)
+	}
+	// Prefix a fake mode switch so decodeLCD pairs up the nibbles
+	var text strings.Builder
+	var commands []byte
+	for _, b := range decodeLCD(append(bytes.Repeat([]byte{pinEnable, 0}, 4), dev.writes...)) {
+		if b.data {
+			text.WriteByte(b.b)
+		} else {
+			commands = append(commands, b.b)
+		}
+	}
+	if text.String() != "API OUTAGE      Down ?          " {
+		t.Errorf("characters = %q", text.String())
+	}
+	if !reflect.DeepEqual(commands, []byte{cmdSetDDRAM | 0x00, cmdSetDDRAM | 0x40}) {
+		t.Errorf("commands = % X, want both rows addressed", commands)
+	}
+
+	// Unchanged rows aren't rewritten
+	dev.writes = nil
+	lcd.Show([]string{"API OUTAGE", "Down 2"})
+	if n := len(decodeLCD(append(bytes.Repeat([]byte{pinEnable, 0}, 4), dev.writes...))); n != 17 {
+		t.Errorf("wrote %d bytes, want only the second row", n)
+	}
+
+	lcd.Close()
+	if !dev.closed {
+		t.Error("Close() didn't close the bus")
+	}
+}
+
+func TestPresenter(t *testing.T) {
+	var out bytes.Buffer
+	term := NewTerminal(&out, 16, 2)
+	p := NewPresenter(term)
+
+	p.Update([]types.Incident{{ID: 1, Service: "web", CurrentState: "operational"}})
+	if !strings.Contains(out.String(), "|All systems     |") {
+		t.Errorf("output = %q, want all clear", out.String())
+	}
+
+	incidents := []types.Incident{
+		{ID: 1, Service: "web", CurrentState: "degraded", CreatedAt: "2025-01-09T03:20:00",
+			Incident: types.IncidentDetails{Title: "Slow"}},
+		{ID: 2, Service: "api", CurrentState: "outage", CreatedAt: "2025-01-09T03:18:00",
+			Incident: types.IncidentDetails{Title: "API is not responding"}},
+	}
+	p.Update(incidents)
+	if want := []string{"API OUTAGE   1/2", "API is not respo"}; !reflect.DeepEqual(term.shown, want) {
+		t.Errorf("shown = %q, want the worst incident first %q", term.shown, want)
+	}
+
+	// The title holds, scrolls one character per step, then holds again
+	for i := 0; i < pauseSteps+5; i++ {
+		p.Step()
+	}
+	if term.shown[1] != "s not responding" {
+		t.Errorf("title = %q, want it scrolled to the end", term.shown[1])
+	}
+	for i := 0; i <= pauseSteps; i++ {
+		p.Step()
+	}
+	if want := []string{"WEB DEGRADED 2/2", "Slow            "}; !reflect.DeepEqual(term.shown, want) {
+		t.Errorf("shown = %q, want the next incident %q", term.shown, want)
+	}
+
+	// Updates keep the current incident
+	p.Update(incidents)
+	if !strings.HasPrefix(term.shown[0], "WEB") {
+		t.Errorf("shown = %q after update, want the same incident", term.shown)
+	}
+}
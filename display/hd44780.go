@@ -0,0 +1,153 @@
+package display
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultI2CAddress is the usual address of PCF8574 LCD backpacks
+const DefaultI2CAddress = 0x27
+
+// PCF8574 backpack wiring: P0 RS, P1 RW, P2 E, P3 backlight, P4-P7 D4-D7
+const (
+	pinRS        = 1 << 0
+	pinEnable    = 1 << 2
+	pinBacklight = 1 << 3
+)
+
+// HD44780 instructions
+const (
+	cmdClear       = 0x01
+	cmdEntryMode   = 0x06 // increment, no shift
+	cmdDisplayOn   = 0x0C // display on, cursor and blink off
+	cmdFunctionSet = 0x28 // 4-bit bus, two lines, 5x8 font
+	cmdSetDDRAM    = 0x80
+)
+
+// rowOffsets are the DDRAM addresses of the rows of 2 and 4 line displays
+var rowOffsets = []byte{0x00, 0x40, 0x14, 0x54}
+
+// i2cDevice is a device on an I2C bus
+type i2cDevice interface {
+	Write(data []byte) (int, error)
+	Close() error
+}
+
+// HD44780 drives an HD44780 LCD through a PCF8574 I2C backpack
+type HD44780 struct {
+	dev   i2cDevice
+	cols  int
+	rows  int
+	shown []string
+	sleep func(time.Duration)
+}
+
+// NewHD44780 opens the display at addr on an I2C bus such as /dev/i2c-1
+func NewHD44780(bus string, addr uint16, cols, rows int) (*HD44780, error) {
+	if cols <= 0 || rows <= 0 || rows > len(rowOffsets) {
+		return nil, fmt.Errorf("unsupported display size %dx%d", cols, rows)
+	}
+	f, err := os.OpenFile(bus, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open I2C bus: %w", err)
+	}
+	if err := selectI2CAddress(f, addr); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to select I2C address 0x%02x: %w", addr, err)
+	}
+	return newHD44780(f, cols, rows, time.Sleep)
+}
+
+func newHD44780(dev i2cDevice, cols, rows int, sleep func(time.Duration)) (*HD44780, error) {
+	d := &HD44780{dev: dev, cols: cols, rows: rows, sleep: sleep}
+	if err := d.init(); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// init switches the controller to 4-bit mode, following the power-on
+// sequence of the datasheet since its state is unknown
+func (d *HD44780) init() error {
+	d.sleep(50 * time.Millisecond)
+	for _, nibble := range []byte{0x03, 0x03, 0x03, 0x02} {
+		if err := d.writeNibble(nibble, 0); err != nil {
+			return fmt.Errorf("failed to initialize display: %w", err)
+		}
+		d.sleep(5 * time.Millisecond)
+	}
+	for _, cmd := range []byte{cmdFunctionSet, cmdDisplayOn, cmdClear, cmdEntryMode} {
+		if err := d.command(cmd); err != nil {
+			return fmt.Errorf("failed to initialize display: %w", err)
+		}
+	}
+	return nil
+}
+
+// Size returns the number of columns and rows
+func (d *HD44780) Size() (int, int) {
+	return d.cols, d.rows
+}
+
+// Show writes the rows that changed
+func (d *HD44780) Show(lines []string) error {
+	lines = fit(lines, d.cols, d.rows)
+	for row, line := range lines {
+		if d.shown != nil && d.shown[row] == line {
+			continue
+		}
+		if err := d.command(cmdSetDDRAM | rowOffsets[row]); err != nil {
+			return fmt.Errorf("failed to write to display: %w", err)
+		}
+		for _, r := range line {
+			if err := d.write(charCode(r), pinRS); err != nil {
+				d.shown = nil
+				return fmt.Errorf("failed to write to display: %w", err)
+			}
+		}
+	}
+	d.shown = lines
+	return nil
+}
+
+// Close clears the display, switches the backlight off and closes the bus
+func (d *HD44780) Close() error {
+	d.command(cmdClear)
+	d.dev.Write([]byte{0})
+	return d.dev.Close()
+}
+
+func (d *HD44780) command(cmd byte) error {
+	if err := d.write(cmd, 0); err != nil {
+		return err
+	}
+	if cmd == cmdClear {
+		d.sleep(2 * time.Millisecond)
+	}
+	return nil
+}
+
+// write sends a byte as two nibbles, high first
+func (d *HD44780) write(b byte, flags byte) error {
+	if err := d.writeNibble(b>>4, flags); err != nil {
+		return err
+	}
+	return d.writeNibble(b&0x0F, flags)
+}
+
+// writeNibble puts a nibble on D4-D7 and pulses enable
+func (d *HD44780) writeNibble(nibble byte, flags byte) error {
+	data := nibble<<4 | flags | pinBacklight
+	_, err := d.dev.Write([]byte{data | pinEnable, data})
+	return err
+}
+
+// charCode maps a rune to the character ROM, which covers ASCII
+func charCode(r rune) byte {
+	if r < 0x20 || r > 0x7D {
+		return '?'
+	}
+	return byte(r)
+}
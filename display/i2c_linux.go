@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package display
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// i2cSlaveIoctl is I2C_SLAVE from linux/i2c-dev.h
+const i2cSlaveIoctl = 0x0703
+
+// selectI2CAddress directs the writes to an I2C bus at the device at addr
+func selectI2CAddress(bus *os.File, addr uint16) error {
+	return unix.IoctlSetInt(int(bus.Fd()), i2cSlaveIoctl, int(addr))
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package display
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// selectI2CAddress fails since i2c-dev buses only exist on Linux
+func selectI2CAddress(bus *os.File, addr uint16) error {
+	return fmt.Errorf("i2c-dev not supported on %s", runtime.GOOS)
+}
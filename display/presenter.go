@@ -0,0 +1,191 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+// DefaultScrollInterval is how often long titles move by one character
+const DefaultScrollInterval = 400 * time.Millisecond
+
+// pauseSteps is how many steps a page holds before and after scrolling
+const pauseSteps = 5
+
+// Presenter shows the active incidents on a display, worst first. Each
+// incident is shown for a while with its title scrolling when it doesn't
+// fit, then the next one follows
+type Presenter struct {
+	display Display
+
+	mu        sync.Mutex
+	incidents []types.Incident
+	page      int
+	step      int
+	closed    bool
+}
+
+// NewPresenter creates a Presenter for a display
+func NewPresenter(display Display) *Presenter {
+	return &Presenter{display: display}
+}
+
+// Update replaces the incidents shown. The page stays on the same incident
+// when it is still active
+func (p *Presenter) Update(incidents []types.Incident) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	active := activeIncidents(incidents)
+	current := -1
+	if p.page < len(p.incidents) {
+		current = p.incidents[p.page].ID
+	}
+	p.incidents = active
+	p.page = 0
+	for i, incident := range active {
+		if incident.ID == current {
+			p.page = i
+			return p.showLocked()
+		}
+	}
+	p.step = 0
+	return p.showLocked()
+}
+
+// Step scrolls the title or moves to the next incident
+func (p *Presenter) Step() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.incidents) == 0 {
+		return nil
+	}
+	p.step++
+	cols, _ := p.display.Size()
+	if p.step > scrollSteps(p.incidents[p.page].Incident.Title, cols)+2*pauseSteps {
+		p.page = (p.page + 1) % len(p.incidents)
+		p.step = 0
+	}
+	return p.showLocked()
+}
+
+// Close closes the display; later updates are ignored
+func (p *Presenter) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return p.display.Close()
+}
+
+// Run steps the presenter every interval until stop is closed
+func (p *Presenter) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.Step()
+		}
+	}
+}
+
+func (p *Presenter) showLocked() error {
+	if p.closed {
+		return nil
+	}
+	cols, rows := p.display.Size()
+	if len(p.incidents) == 0 {
+		return p.display.Show([]string{"All systems", "operational"})
+	}
+
+	incident := p.incidents[p.page]
+	header := fmt.Sprintf("%s %s", strings.ToUpper(incident.Service), strings.ToUpper(incident.CurrentState))
+	if len(p.incidents) > 1 {
+		position := fmt.Sprintf(" %d/%d", p.page+1, len(p.incidents))
+		header = padRight(header, cols-len(position)) + position
+	}
+
+	offset := p.step - pauseSteps
+	if offset < 0 {
+		offset = 0
+	}
+	if last := scrollSteps(incident.Incident.Title, cols); offset > last {
+		offset = last
+	}
+	lines := []string{header, window(incident.Incident.Title, offset, cols)}
+	if rows > 2 {
+		lines = append(lines, strings.Join(incident.Incident.Components, ","))
+	}
+	if rows > 3 {
+		lines = append(lines, "since "+strings.Replace(strings.Split(incident.CreatedAt, ".")[0], "T", " ", 1))
+	}
+	return p.display.Show(lines)
+}
+
+// scrollSteps is how many characters a title scrolls to show its end
+func scrollSteps(title string, cols int) int {
+	if n := len([]rune(title)) - cols; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// window returns cols characters of s from offset
+func window(s string, offset, cols int) string {
+	r := []rune(s)
+	if offset > len(r) {
+		offset = len(r)
+	}
+	r = r[offset:]
+	if len(r) > cols {
+		r = r[:cols]
+	}
+	return string(r)
+}
+
+// padRight cuts or pads s to exactly n characters
+func padRight(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	return fit([]string{s}, n, 1)[0]
+}
+
+// activeIncidents returns the incidents in an alerting state, worst first
+// and newest first within the same severity
+func activeIncidents(incidents []types.Incident) []types.Incident {
+	var active []types.Incident
+	seen := make(map[int]bool)
+	for _, incident := range incidents {
+		if severity(incident.CurrentState) == 0 || seen[incident.ID] {
+			continue
+		}
+		seen[incident.ID] = true
+		active = append(active, incident)
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		si, sj := severity(active[i].CurrentState), severity(active[j].CurrentState)
+		if si != sj {
+			return si > sj
+		}
+		return active[i].CreatedAt > active[j].CreatedAt
+	})
+	return active
+}
+
+// severity ranks incident states, zero for states that don't alert
+func severity(state string) int {
+	switch strings.ToLower(state) {
+	case types.StateOutage, types.StateCritical, types.StateMajor:
+		return 2
+	case types.StateDegraded:
+		return 1
+	}
+	return 0
+}
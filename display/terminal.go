@@ -0,0 +1,62 @@
+package display
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Terminal draws the display as a framed box, redrawing it in place
+type Terminal struct {
+	mu    sync.Mutex
+	out   io.Writer
+	cols  int
+	rows  int
+	drawn bool
+	shown []string
+}
+
+// NewTerminal creates a Terminal of the given size writing to out, e.g. a
+// second terminal window
+func NewTerminal(out io.Writer, cols, rows int) *Terminal {
+	return &Terminal{out: out, cols: cols, rows: rows}
+}
+
+// Size returns the number of columns and rows
+func (t *Terminal) Size() (int, int) {
+	return t.cols, t.rows
+}
+
+// Show redraws the box when the content changed
+func (t *Terminal) Show(lines []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines = fit(lines, t.cols, t.rows)
+	if t.shown != nil && strings.Join(t.shown, "\n") == strings.Join(lines, "\n") {
+		return nil
+	}
+
+	var b strings.Builder
+	if t.drawn {
+		// Move back to the top of the previous box
+		fmt.Fprintf(&b, "\x1b[%dA", t.rows+2)
+	}
+	border := "+" + strings.Repeat("-", t.cols) + "+\n"
+	b.WriteString(border)
+	for _, line := range lines {
+		b.WriteString("|" + line + "|\n")
+	}
+	b.WriteString(border)
+	if _, err := io.WriteString(t.out, b.String()); err != nil {
+		return err
+	}
+	t.drawn = true
+	t.shown = lines
+	return nil
+}
+
+// Close does nothing
+func (t *Terminal) Close() error {
+	return nil
+}
@@ -6,5 +6,5 @@ require github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
 
 require (
 	github.com/todbot/blink1 v0.1.0
-	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/sys v0.29.0
 )
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultMaxPollAge is how long the checker stays healthy without a
+// successful poll
+const DefaultMaxPollAge = 2 * time.Minute
+
+// Check probes one part of the checker, returning why it is unhealthy
+type Check struct {
+	Name string
+	Run  func() error
+}
+
+// Failure is a check that failed
+type Failure struct {
+	Check string
+	Err   error
+}
+
+// Status is the outcome of running every check
+type Status struct {
+	Failures []Failure
+}
+
+// Healthy reports whether every check passed
+func (s Status) Healthy() bool {
+	return len(s.Failures) == 0
+}
+
+// Reason describes the failed checks, e.g. "poll: no successful poll for
+// 5m0s; light: write failed"
+func (s Status) Reason() string {
+	parts := make([]string, 0, len(s.Failures))
+	for _, failure := range s.Failures {
+		parts = append(parts, failure.Check+": "+failure.Err.Error())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Model is the set of checks that make up the health of the checker
+type Model struct {
+	checks []Check
+}
+
+// New creates a Model from checks
+func New(checks ...Check) *Model {
+	return &Model{checks: checks}
+}
+
+// Check runs every check
+func (m *Model) Check() Status {
+	var status Status
+	for _, check := range m.checks {
+		if err := check.Run(); err != nil {
+			status.Failures = append(status.Failures, Failure{Check: check.Name, Err: err})
+		}
+	}
+	return status
+}
+
+// Err runs every check and returns the reason as an error when unhealthy
+func (m *Model) Err() error {
+	status := m.Check()
+	if status.Healthy() {
+		return nil
+	}
+	return fmt.Errorf("%s", status.Reason())
+}
+
+// RecentPoll fails when no poll succeeded within maxAge. Before the first
+// successful poll the age is counted from started
+func RecentPoll(lastPoll func() time.Time, started time.Time, maxAge time.Duration) Check {
+	return recentPoll(lastPoll, started, maxAge, time.Now)
+}
+
+func recentPoll(lastPoll func() time.Time, started time.Time, maxAge time.Duration, now func() time.Time) Check {
+	return Check{
+		Name: "poll",
+		Run: func() error {
+			last := lastPoll()
+			if last.IsZero() {
+				if age := now().Sub(started); age > maxAge {
+					return fmt.Errorf("no successful poll since start %s ago", age.Round(time.Second))
+				}
+				return nil
+			}
+			if age := now().Sub(last); age > maxAge {
+				return fmt.Errorf("no successful poll for %s", age.Round(time.Second))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecentPoll(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := started
+	var lastPoll time.Time
+	check := recentPoll(func() time.Time { return lastPoll }, started, time.Minute, func() time.Time { return now })
+
+	now = started.Add(30 * time.Second)
+	if err := check.Run(); err != nil {
+		t.Errorf("before the first poll, within max age: %v", err)
+	}
+	now = started.Add(90 * time.Second)
+	if err := check.Run(); err == nil || err.Error() != "no successful poll since start 1m30s ago" {
+		t.Errorf("before the first poll, past max age: %v", err)
+	}
+
+	lastPoll = started.Add(80 * time.Second)
+	if err := check.Run(); err != nil {
+		t.Errorf("after a recent poll: %v", err)
+	}
+	now = lastPoll.Add(5 * time.Minute)
+	if err := check.Run(); err == nil || err.Error() != "no successful poll for 5m0s" {
+		t.Errorf("after a stale poll: %v", err)
+	}
+}
+
+func TestModel(t *testing.T) {
+	lightErr := errors.New("write /dev/ttyUSB0: input/output error")
+	var notifierErr error
+	model := New(
+		Check{Name: "light", Run: func() error { return lightErr }},
+		Check{Name: "notifier", Run: func() error { return notifierErr }},
+	)
+
+	status := model.Check()
+	if status.Healthy() || status.Reason() != "light: write /dev/ttyUSB0: input/output error" {
+		t.Errorf("Check() = %+v, reason %q", status, status.Reason())
+	}
+
+	notifierErr = errors.New("unreachable")
+	if err := model.Err(); err == nil || err.Error() != "light: write /dev/ttyUSB0: input/output error; notifier: unreachable" {
+		t.Errorf("Err() = %v", err)
+	}
+
+	lightErr, notifierErr = nil, nil
+	if err := model.Err(); err != nil {
+		t.Errorf("Err() = %v, want healthy", err)
+	}
+}
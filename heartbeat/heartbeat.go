@@ -1,47 +1,271 @@
 package heartbeat
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
-	"strings"
+	"sync"
 	"time"
+
+	"my-incident-checker/httpclient"
+	"my-incident-checker/types"
 )
 
 const (
-	heartbeatEndpoint = "https://nosnch.in/2b7bdbea9e"
-	heartbeatInterval = 5 * time.Minute
+	// DefaultEndpoint is the Dead Man's Snitch used when no target is
+	// configured
+	DefaultEndpoint = "https://nosnch.in/2b7bdbea9e"
+	// DefaultInterval is the time between heartbeats
+	DefaultInterval = 5 * time.Minute
+
+	requestTimeout = 10 * time.Second
 )
 
-// sendHeartbeat sends a heartbeat signal to the monitoring service
-func sendHeartbeat() error {
-	payload := strings.NewReader("m=just checking in")
-	resp, err := http.Post(heartbeatEndpoint, "application/x-www-form-urlencoded", payload)
-	if err != nil {
-		return fmt.Errorf("failed to send heartbeat:: %s", err.Error())
+// client sends the heartbeats of every target
+var client = httpclient.New(requestTimeout)
+
+// Payload is what a heartbeat reports about the checker
+type Payload struct {
+	Node    string
+	Version string
+	Uptime  time.Duration
+	// LastPoll is the time of the last successful incident poll, zero when
+	// none succeeded yet
+	LastPoll time.Time
+	// LightState is the state shown on the light, e.g. "red=blink"
+	LightState string
+}
+
+// MarshalJSON implements json.Marshaler
+func (p Payload) MarshalJSON() ([]byte, error) {
+	var lastPoll *time.Time
+	if !p.LastPoll.IsZero() {
+		t := p.LastPoll.UTC()
+		lastPoll = &t
+	}
+	return json.Marshal(struct {
+		Node          string     `json:"node"`
+		Version       string     `json:"version"`
+		UptimeSeconds int64      `json:"uptime_seconds"`
+		LastPoll      *time.Time `json:"last_successful_poll"`
+		LightState    string     `json:"light_state"`
+	}{
+		Node:          p.Node,
+		Version:       p.Version,
+		UptimeSeconds: int64(p.Uptime / time.Second),
+		LastPoll:      lastPoll,
+		LightState:    p.LightState,
+	})
+}
+
+// Summary renders the payload as one line for targets that only take a
+// message
+func (p Payload) Summary(now time.Time) string {
+	lastPoll := "never"
+	if !p.LastPoll.IsZero() {
+		lastPoll = now.Sub(p.LastPoll).Round(time.Second).String() + " ago"
 	}
-	defer resp.Body.Close()
+	return fmt.Sprintf("%s %s up %s, last poll %s, light %s",
+		p.Node, p.Version, p.Uptime.Round(time.Second), lastPoll, p.LightState)
+}
+
+// Target is a monitoring service receiving heartbeats
+type Target interface {
+	// Name identifies the target in logs
+	Name() string
+	// Ping reports that the checker is alive
+	Ping(payload Payload) error
+}
+
+// Starter is implemented by targets that are told when the checker starts,
+// so they can measure how long it takes to come up
+type Starter interface {
+	Start(payload Payload) error
+}
+
+// Failer is implemented by targets that accept an explicit failure signal
+type Failer interface {
+	Fail(payload Payload, reason string) error
+}
+
+// DefaultFailureThreshold is the number of consecutive failed heartbeats
+// after which a target counts as failing
+const DefaultFailureThreshold = 3
+
+// Stats are the heartbeat results of one target
+type Stats struct {
+	Target string
+	// Failures is the number of consecutive failed heartbeats
+	Failures      int
+	TotalFailures int
+	Total         int
+	LastSuccess   time.Time
+	LastErr       error
+	// Failing is set once Failures reached the threshold, until the next
+	// success
+	Failing bool
+}
+
+// Heartbeat pings its targets at a regular interval
+type Heartbeat struct {
+	targets  []Target
+	interval time.Duration
+	report   func() Payload
+	health   func() error
+	logger   *types.Logger
+
+	mu        sync.Mutex
+	stats     map[string]*Stats
+	threshold int
+	onChange  func(Stats)
+	now       func() time.Time
+}
+
+// New creates a Heartbeat. report is called before every heartbeat to
+// build its payload. health, when not nil, gates the heartbeats: while it
+// returns an error, targets that accept a failure signal are sent one with
+// the error as reason and the others are not pinged, so that they alert
+func New(targets []Target, interval time.Duration, report func() Payload, health func() error, logger *types.Logger) *Heartbeat {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	stats := make(map[string]*Stats, len(targets))
+	for _, target := range targets {
+		stats[target.Name()] = &Stats{Target: target.Name()}
+	}
+	return &Heartbeat{
+		targets:   targets,
+		interval:  interval,
+		report:    report,
+		health:    health,
+		logger:    logger,
+		stats:     stats,
+		threshold: DefaultFailureThreshold,
+		now:       time.Now,
+	}
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("heartbeat failed with status code: %d", resp.StatusCode)
+// OnChange calls fn when a target starts failing, after threshold
+// consecutive failures, and when it succeeds again. It must be called
+// before Run
+func (h *Heartbeat) OnChange(threshold int, fn func(Stats)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if threshold > 0 {
+		h.threshold = threshold
 	}
+	h.onChange = fn
+}
 
-	return nil
+// Stats returns the results of every target, in the order of the targets
+func (h *Heartbeat) Stats() []Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := make([]Stats, 0, len(h.targets))
+	for _, target := range h.targets {
+		stats = append(stats, *h.stats[target.Name()])
+	}
+	return stats
 }
 
-// Run continuously sends heartbeat signals at regular intervals
-func Run() {
-	fmt.Printf("In runHeartbeat\n")
-	ticker := time.NewTicker(heartbeatInterval)
+// Run signals the start to the targets that support it, then sends a
+// heartbeat to every target until stop is closed
+func (h *Heartbeat) Run(stop <-chan struct{}) {
+	h.logger.InfoLog.Printf("Sending heartbeats to %d targets every %s", len(h.targets), h.interval)
+	ticker := time.NewTicker(h.interval)
 	defer ticker.Stop()
 
+	h.Start()
 	for {
-		fmt.Printf("Sending heartbeat\n")
-		if err := sendHeartbeat(); err != nil {
-			fmt.Printf("Heartbeat error: %s\n", err.Error())
-			log.Printf("Heartbeat error:: %s", err.Error())
+		h.Beat()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			h.logger.InfoLog.Printf("Heartbeats stopped")
+			return
+		}
+	}
+}
+
+// Start tells the targets that support it that the checker is starting
+func (h *Heartbeat) Start() {
+	payload := h.report()
+	for _, target := range h.targets {
+		starter, ok := target.(Starter)
+		if !ok {
+			continue
+		}
+		h.record(target, "start", starter.Start(payload))
+	}
+}
+
+// Beat sends one heartbeat to every target, or a failure when unhealthy
+func (h *Heartbeat) Beat() {
+	payload := h.report()
+	if h.health != nil {
+		if unhealthy := h.health(); unhealthy != nil {
+			h.fail(payload, unhealthy.Error())
+			return
+		}
+	}
+	for _, target := range h.targets {
+		h.record(target, "ping", target.Ping(payload))
+	}
+}
+
+// fail signals the reason to the targets that accept failures and skips
+// the others
+func (h *Heartbeat) fail(payload Payload, reason string) {
+	h.logger.WarnLog.Printf("Unhealthy, not sending heartbeat: %s", reason)
+	for _, target := range h.targets {
+		failer, ok := target.(Failer)
+		if !ok {
+			continue
+		}
+		h.record(target, "failure signal", failer.Fail(payload, reason))
+	}
+}
+
+// record counts the result of sending to target, logging failures and
+// reporting targets that start or stop failing
+func (h *Heartbeat) record(target Target, what string, err error) {
+	h.mu.Lock()
+	stats := h.stats[target.Name()]
+	stats.Total++
+	stats.LastErr = err
+	changed := false
+	if err != nil {
+		stats.Failures++
+		stats.TotalFailures++
+		if !stats.Failing && stats.Failures >= h.threshold {
+			stats.Failing = true
+			changed = true
+		}
+	} else {
+		stats.LastSuccess = h.now()
+		if stats.Failing {
+			stats.Failing = false
+			changed = true
+		}
+		stats.Failures = 0
+	}
+	snapshot := *stats
+	onChange := h.onChange
+	h.mu.Unlock()
+
+	if err != nil {
+		h.logger.ErrorLog.Printf("Heartbeat %s to %s failed (%d in a row): %s",
+			what, target.Name(), snapshot.Failures, err.Error())
+	} else {
+		h.logger.DebugLog.Printf("Heartbeat %s to %s sent", what, target.Name())
+	}
+	if changed {
+		if snapshot.Failing {
+			h.logger.WarnLog.Printf("Heartbeats to %s are failing", target.Name())
+		} else {
+			h.logger.InfoLog.Printf("Heartbeats to %s recovered", target.Name())
+		}
+		if onChange != nil {
+			onChange(snapshot)
 		}
-		<-ticker.C
 	}
-	fmt.Printf("Exiting runHeartbeat\n")
 }
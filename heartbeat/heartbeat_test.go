@@ -0,0 +1,272 @@
+package heartbeat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+type request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+// recordServer records the requests it receives and answers with status
+type recordServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []request
+	status   int
+}
+
+func newRecordServer(t *testing.T) *recordServer {
+	s := &recordServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, request{r.Method, r.URL.Path, r.URL.RawQuery, r.Header, string(body)})
+		w.WriteHeader(s.status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *recordServer) Requests() []request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]request(nil), s.requests...)
+}
+
+var testPayload = Payload{
+	Node:       "pi-1",
+	Version:    "v1.2.3",
+	Uptime:     90 * time.Minute,
+	LastPoll:   time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	LightState: "red=blink",
+}
+
+func TestPayloadJSON(t *testing.T) {
+	data, err := json.Marshal(testPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"node":"pi-1","version":"v1.2.3","uptime_seconds":5400,"last_successful_poll":"2024-05-01T12:00:00Z","light_state":"red=blink"}`
+	if string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+
+	data, _ = json.Marshal(Payload{Node: "pi-1", LightState: "off"})
+	if !strings.Contains(string(data), `"last_successful_poll":null`) {
+		t.Errorf("json = %s, want a null last poll", data)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	server := newRecordServer(t)
+
+	snitch, _ := NewTarget(TypeDeadMansSnitch, "", server.URL+"/snitch", nil)
+	if err := snitch.Ping(testPayload); err != nil {
+		t.Fatalf("DeadMansSnitch.Ping() error = %v", err)
+	}
+	hc, _ := NewTarget(TypeHealthchecks, "hc", server.URL+"/hc/", nil)
+	if err := hc.(Starter).Start(testPayload); err != nil {
+		t.Fatalf("Healthchecks.Start() error = %v", err)
+	}
+	if err := hc.Ping(testPayload); err != nil {
+		t.Fatalf("Healthchecks.Ping() error = %v", err)
+	}
+	if err := hc.(Failer).Fail(testPayload, "no successful poll"); err != nil {
+		t.Fatalf("Healthchecks.Fail() error = %v", err)
+	}
+	kuma, _ := NewTarget(TypeUptimeKuma, "kuma", server.URL+"/api/push/token", nil)
+	if err := kuma.Ping(testPayload); err != nil {
+		t.Fatalf("UptimeKuma.Ping() error = %v", err)
+	}
+	hook, _ := NewTarget(TypeWebhook, "hook", server.URL+"/hook", map[string]string{"Authorization": "Bearer secret"})
+	if err := hook.(Failer).Fail(testPayload, "light unplugged"); err != nil {
+		t.Fatalf("Webhook.Fail() error = %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 6 {
+		t.Fatalf("got %d requests, want 6", len(requests))
+	}
+
+	if r := requests[0]; r.Path != "/snitch" || !strings.HasPrefix(r.Body, "m=pi-1+v1.2.3+up+1h30m0s") {
+		t.Errorf("snitch request = %+v", r)
+	}
+	if r := requests[1]; r.Path != "/hc/start" || !strings.Contains(r.Body, `"node":"pi-1"`) {
+		t.Errorf("healthchecks start request = %+v", r)
+	}
+	if r := requests[2]; r.Path != "/hc" || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("healthchecks ping request = %+v", r)
+	}
+	if r := requests[3]; r.Path != "/hc/fail" || !strings.HasPrefix(r.Body, "no successful poll\n{") {
+		t.Errorf("healthchecks fail request = %+v", r)
+	}
+	if r := requests[4]; r.Method != http.MethodGet || r.Path != "/api/push/token" ||
+		!strings.Contains(r.Query, "status=up") || !strings.Contains(r.Query, "msg=pi-1") {
+		t.Errorf("uptime kuma request = %+v", r)
+	}
+
+	r := requests[5]
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("webhook Authorization = %q", r.Header.Get("Authorization"))
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Body), &body); err != nil {
+		t.Fatalf("webhook body %q: %v", r.Body, err)
+	}
+	if body["status"] != "fail" || body["reason"] != "light unplugged" || body["light_state"] != "red=blink" {
+		t.Errorf("webhook body = %v", body)
+	}
+}
+
+func TestNewTargetErrors(t *testing.T) {
+	if _, err := NewTarget("pager", "", "https://example.com", nil); err == nil {
+		t.Error("NewTarget(pager) succeeded, want an unknown type error")
+	}
+	if _, err := NewTarget(TypeWebhook, "", "not a url", nil); err == nil {
+		t.Error("NewTarget(not a url) succeeded, want an invalid url error")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	server := newRecordServer(t)
+	server.status = http.StatusInternalServerError
+
+	var logs bytes.Buffer
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(&logs, "INFO: ", 0),
+		WarnLog:  log.New(&logs, "WARN: ", 0),
+		ErrorLog: log.New(&logs, "ERROR: ", 0),
+	}
+	hc, _ := NewTarget(TypeHealthchecks, "hc", server.URL, nil)
+	snitch, _ := NewTarget(TypeDeadMansSnitch, "snitch", server.URL+"/snitch", nil)
+	beat := New([]Target{hc, snitch}, time.Hour, func() Payload { return testPayload }, nil, logger)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		beat.Run(stop)
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(server.Requests()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	var paths []string
+	for _, r := range server.Requests() {
+		paths = append(paths, r.Path)
+	}
+	if strings.Join(paths, " ") != "/start / /snitch" {
+		t.Errorf("requests = %v, want start, then a ping to each target", paths)
+	}
+	if !strings.Contains(logs.String(), "Heartbeat ping to snitch failed (1 in a row): heartbeat failed with status code: 500") {
+		t.Errorf("logs = %q, want the failure logged", logs.String())
+	}
+}
+
+func TestHeartbeatUnhealthy(t *testing.T) {
+	server := newRecordServer(t)
+
+	var logs bytes.Buffer
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(&logs, "INFO: ", 0),
+		WarnLog:  log.New(&logs, "WARN: ", 0),
+		ErrorLog: log.New(&logs, "ERROR: ", 0),
+	}
+	hc, _ := NewTarget(TypeHealthchecks, "hc", server.URL, nil)
+	snitch, _ := NewTarget(TypeDeadMansSnitch, "snitch", server.URL+"/snitch", nil)
+	var unhealthy error
+	beat := New([]Target{hc, snitch}, time.Hour, func() Payload { return testPayload },
+		func() error { return unhealthy }, logger)
+
+	unhealthy = errors.New("poll: no successful poll for 10m0s")
+	beat.Beat()
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/fail" || !strings.HasPrefix(requests[0].Body, "poll: no successful poll for 10m0s\n") {
+		t.Errorf("requests = %+v, want only a failure to healthchecks", requests)
+	}
+	if !strings.Contains(logs.String(), "WARN: Unhealthy, not sending heartbeat: poll: no successful poll") {
+		t.Errorf("logs = %q, want the reason logged", logs.String())
+	}
+
+	unhealthy = nil
+	beat.Beat()
+	var paths []string
+	for _, r := range server.Requests()[1:] {
+		paths = append(paths, r.Path)
+	}
+	if strings.Join(paths, " ") != "/ /snitch" {
+		t.Errorf("requests = %v once healthy, want a ping to each target", paths)
+	}
+}
+
+func TestHeartbeatFailureTracking(t *testing.T) {
+	server := newRecordServer(t)
+	server.status = http.StatusBadGateway
+
+	var logs bytes.Buffer
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(&logs, "INFO: ", 0),
+		WarnLog:  log.New(&logs, "WARN: ", 0),
+		ErrorLog: log.New(&logs, "ERROR: ", 0),
+	}
+	snitch, _ := NewTarget(TypeDeadMansSnitch, "snitch", server.URL, nil)
+	beat := New([]Target{snitch}, time.Hour, func() Payload { return testPayload }, nil, logger)
+	var changes []Stats
+	beat.OnChange(2, func(stats Stats) { changes = append(changes, stats) })
+
+	beat.Beat()
+	if len(changes) != 0 {
+		t.Fatalf("changes = %+v after one failure, want none below the threshold", changes)
+	}
+	beat.Beat()
+	beat.Beat()
+	if len(changes) != 1 || !changes[0].Failing || changes[0].Failures != 2 || changes[0].LastErr == nil {
+		t.Fatalf("changes = %+v, want one failing change at the threshold", changes)
+	}
+
+	server.mu.Lock()
+	server.status = http.StatusOK
+	server.mu.Unlock()
+	beat.Beat()
+	if len(changes) != 2 || changes[1].Failing || changes[1].Failures != 0 || changes[1].LastSuccess.IsZero() {
+		t.Fatalf("changes = %+v, want a recovery", changes)
+	}
+
+	stats := beat.Stats()
+	if len(stats) != 1 || stats[0].Total != 4 || stats[0].TotalFailures != 3 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	for _, want := range []string{
+		"ERROR: Heartbeat ping to snitch failed (3 in a row): heartbeat failed with status code: 502",
+		"WARN: Heartbeats to snitch are failing",
+		"INFO: Heartbeats to snitch recovered",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs = %q, want %q", logs.String(), want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package heartbeat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Target types accepted by NewTarget
+const (
+	TypeDeadMansSnitch = "deadmanssnitch"
+	TypeHealthchecks   = "healthchecks"
+	TypeUptimeKuma     = "uptimekuma"
+	TypeWebhook        = "webhook"
+)
+
+// Types lists the target types accepted by NewTarget
+var Types = []string{TypeDeadMansSnitch, TypeHealthchecks, TypeUptimeKuma, TypeWebhook}
+
+// NewTarget creates a target of the given type. Options are only used by
+// webhooks, which send them as request headers
+func NewTarget(kind, name, rawURL string, options map[string]string) (Target, error) {
+	if _, err := url.ParseRequestURI(rawURL); err != nil {
+		return nil, fmt.Errorf("heartbeat target %s: invalid url: %w", name, err)
+	}
+	if name == "" {
+		name = kind
+	}
+	switch kind {
+	case TypeDeadMansSnitch:
+		return &DeadMansSnitch{name: name, URL: rawURL}, nil
+	case TypeHealthchecks:
+		return &Healthchecks{name: name, URL: strings.TrimSuffix(rawURL, "/")}, nil
+	case TypeUptimeKuma:
+		return &UptimeKuma{name: name, URL: rawURL}, nil
+	case TypeWebhook:
+		return &Webhook{name: name, URL: rawURL, Headers: options}, nil
+	}
+	return nil, fmt.Errorf("heartbeat target %s: unknown type %q (want one of %s)", name, kind, strings.Join(Types, ", "))
+}
+
+// DeadMansSnitch checks in with a Dead Man's Snitch, passing the payload
+// summary as the check-in message
+type DeadMansSnitch struct {
+	name string
+	URL  string
+}
+
+// Name implements Target
+func (d *DeadMansSnitch) Name() string { return d.name }
+
+// Ping implements Target
+func (d *DeadMansSnitch) Ping(payload Payload) error {
+	form := url.Values{"m": {payload.Summary(time.Now())}}
+	return post(d.URL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+// Healthchecks pings a Healthchecks.io check. The ping URL, e.g.
+// https://hc-ping.com/<uuid>, receives the payload as JSON and its /start
+// and /fail variants signal the start and failures
+type Healthchecks struct {
+	name string
+	URL  string
+}
+
+// Name implements Target
+func (h *Healthchecks) Name() string { return h.name }
+
+// Ping implements Target
+func (h *Healthchecks) Ping(payload Payload) error {
+	return postJSON(h.URL, payload)
+}
+
+// Start implements Starter
+func (h *Healthchecks) Start(payload Payload) error {
+	return postJSON(h.URL+"/start", payload)
+}
+
+// Fail implements Failer. The reason is the first line of the body, which
+// Healthchecks shows in its notifications
+func (h *Healthchecks) Fail(payload Payload, reason string) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	body := reason + "\n" + string(data)
+	return post(h.URL+"/fail", "text/plain", strings.NewReader(body))
+}
+
+// UptimeKuma calls an Uptime Kuma push monitor, e.g.
+// https://kuma.example.com/api/push/<token>
+type UptimeKuma struct {
+	name string
+	URL  string
+}
+
+// Name implements Target
+func (k *UptimeKuma) Name() string { return k.name }
+
+// Ping implements Target
+func (k *UptimeKuma) Ping(payload Payload) error {
+	return k.push("up", payload.Summary(time.Now()))
+}
+
+// Fail implements Failer
+func (k *UptimeKuma) Fail(payload Payload, reason string) error {
+	return k.push("down", reason+": "+payload.Summary(time.Now()))
+}
+
+func (k *UptimeKuma) push(status, message string) error {
+	u, err := url.Parse(k.URL)
+	if err != nil {
+		return fmt.Errorf("invalid push url: %w", err)
+	}
+	query := u.Query()
+	query.Set("status", status)
+	query.Set("msg", message)
+	query.Set("ping", "")
+	u.RawQuery = query.Encode()
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return checkResponse(resp)
+}
+
+// Webhook posts the payload as JSON to any URL, with a "status" field of
+// "up", "start" or "fail"
+type Webhook struct {
+	name string
+	URL  string
+	// Headers are added to every request, e.g. Authorization
+	Headers map[string]string
+}
+
+// Name implements Target
+func (w *Webhook) Name() string { return w.name }
+
+// Ping implements Target
+func (w *Webhook) Ping(payload Payload) error {
+	return w.send("up", payload, "")
+}
+
+// Start implements Starter
+func (w *Webhook) Start(payload Payload) error {
+	return w.send("start", payload, "")
+}
+
+// Fail implements Failer
+func (w *Webhook) Fail(payload Payload, reason string) error {
+	return w.send("fail", payload, reason)
+}
+
+func (w *Webhook) send(status string, payload Payload, reason string) error {
+	fields, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(fields, &body); err != nil {
+		return err
+	}
+	body["status"] = status
+	if reason != "" {
+		body["reason"] = reason
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create heartbeat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return checkResponse(resp)
+}
+
+func postJSON(url string, payload Payload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(url, "application/json", bytes.NewReader(data))
+}
+
+func post(url, contentType string, body io.Reader) error {
+	resp, err := client.Post(url, contentType, body)
+	if err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return checkResponse(resp)
+}
+
+func checkResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("heartbeat failed with status code: %d", resp.StatusCode)
+	}
+	return nil
+}
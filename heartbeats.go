@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/health"
+	"my-incident-checker/heartbeat"
+	"my-incident-checker/lights"
+	"my-incident-checker/node"
+	"my-incident-checker/notify"
+	"my-incident-checker/poll"
+	"my-incident-checker/types"
+)
+
+// heartbeatOverlay is the arbiter overlay shown while heartbeats fail
+const heartbeatOverlay = "heartbeat"
+
+// defaultFailureLamps are lit while heartbeats fail
+var defaultFailureLamps = lights.TowerState{Yellow: lights.ModeBlink}
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// heartbeatTargets creates the configured heartbeat targets, defaulting to
+// the built-in Dead Man's Snitch. Targets that fail to set up are logged
+// and skipped
+func heartbeatTargets(cfg *config.HeartbeatConfig, logger *types.Logger) []heartbeat.Target {
+	if cfg == nil || len(cfg.Targets) == 0 {
+		target, _ := heartbeat.NewTarget(heartbeat.TypeDeadMansSnitch, "", heartbeat.DefaultEndpoint, nil)
+		return []heartbeat.Target{target}
+	}
+
+	var targets []heartbeat.Target
+	for _, targetConfig := range cfg.Targets {
+		target, err := heartbeat.NewTarget(targetConfig.Type, targetConfig.Name, targetConfig.URL, targetConfig.Headers)
+		if err != nil {
+			logger.ErrorLog.Printf("%s", err.Error())
+			continue
+		}
+		logger.InfoLog.Printf("Sending heartbeats to %s (%s)", targetConfig.Name, targetConfig.Type)
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// heartbeatInterval returns the configured heartbeat interval
+func heartbeatInterval(cfg *config.HeartbeatConfig) (time.Duration, error) {
+	if cfg == nil {
+		return heartbeat.DefaultInterval, nil
+	}
+	return durationOption(cfg.Interval, heartbeat.DefaultInterval)
+}
+
+// heartbeatReport builds heartbeat payloads from the poll loop and the
+// state shown on the light
+func heartbeatReport(startTime time.Time, lastPoll func() time.Time, arbiter *lights.Arbiter) func() heartbeat.Payload {
+	nodeName := node.GetNodeName()
+	return func() heartbeat.Payload {
+		return heartbeat.Payload{
+			Node:       nodeName,
+			Version:    version,
+			Uptime:     time.Since(startTime),
+			LastPoll:   lastPoll(),
+			LightState: arbiter.Shown().String(),
+		}
+	}
+}
+
+// healthModel gates the heartbeats on a recent successful poll, a
+// writable light and a reachable notification server
+func healthModel(cfg *config.HeartbeatConfig, startTime time.Time, poller *poll.Poller, light *lights.Controller, logger *types.Logger) *health.Model {
+	maxPollAge := health.DefaultMaxPollAge
+	if cfg != nil {
+		age, err := durationOption(cfg.MaxPollAge, health.DefaultMaxPollAge)
+		if err != nil {
+			logger.ErrorLog.Printf("Invalid heartbeat max_poll_age: %s", err.Error())
+		} else {
+			maxPollAge = age
+		}
+	}
+	return health.New(
+		health.RecentPoll(poller.LastPoll, startTime, maxPollAge),
+		health.Check{Name: "light", Run: light.Err},
+		health.Check{Name: "notifier", Run: notify.Check},
+	)
+}
+
+// heartbeatAlerts returns the handler of heartbeat targets that start or
+// stop failing. While any target fails, the failure lamps are lit on top
+// of the incident state. Each change is notified
+func heartbeatAlerts(cfg *config.HeartbeatConfig, arbiter *lights.Arbiter, logger *types.Logger) func(heartbeat.Stats) {
+	lamps := defaultFailureLamps
+	if cfg != nil {
+		lamps = lampsOption(cfg.FailureLamps, defaultFailureLamps, "heartbeat failure_lamps", logger)
+	}
+
+	nodeName := node.GetNodeName()
+	failing := make(map[string]bool)
+	return func(stats heartbeat.Stats) {
+		var message string
+		if stats.Failing {
+			failing[stats.Target] = true
+			message = fmt.Sprintf("%s: heartbeats to %s are failing (%d in a row): %s",
+				nodeName, stats.Target, stats.Failures, stats.LastErr)
+		} else {
+			delete(failing, stats.Target)
+			message = fmt.Sprintf("%s: heartbeats to %s recovered", nodeName, stats.Target)
+		}
+
+		var err error
+		if len(failing) > 0 {
+			err = arbiter.Overlay(heartbeatOverlay, lights.PriorityConnectivity, lamps)
+		} else {
+			err = arbiter.ClearOverlay(heartbeatOverlay)
+		}
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+		}
+
+		if err := notify.Send(message); err != nil {
+			logger.ErrorLog.Printf("Failed to send heartbeat notification: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"my-incident-checker/auth"
+	"my-incident-checker/config"
+	"my-incident-checker/httpclient"
+	"my-incident-checker/node"
+)
+
+// configureHTTP applies the http section of the configuration to every
+// outbound request, identifying the checker version and node in the
+// User-Agent
+func configureHTTP(cfg *config.HTTPConfig) error {
+	clientConfig := httpclient.Config{UserAgent: httpclient.UserAgent(version, node.GetNodeName())}
+	if cfg != nil {
+		clientConfig.Proxy = cfg.Proxy
+		clientConfig.NoProxy = cfg.NoProxy
+		clientConfig.CAFile = cfg.CAFile
+		clientConfig.CertFile = cfg.CertFile
+		clientConfig.KeyFile = cfg.KeyFile
+		if cfg.UserAgent != "" {
+			clientConfig.UserAgent = cfg.UserAgent
+		}
+	}
+	return httpclient.Configure(clientConfig)
+}
+
+// incidentAuth creates the authenticator of the incident API, nil when
+// the fetches are anonymous
+func incidentAuth(cfg *config.IncidentAPIConfig) (auth.Authenticator, error) {
+	if cfg == nil || cfg.Auth == nil {
+		return nil, nil
+	}
+	authenticator, err := auth.New(auth.Config{
+		Type:         cfg.Auth.Type,
+		Token:        cfg.Auth.Token,
+		Header:       cfg.Auth.Header,
+		Key:          cfg.Auth.Key,
+		Username:     cfg.Auth.Username,
+		Password:     cfg.Auth.Password,
+		TokenURL:     cfg.Auth.TokenURL,
+		ClientID:     cfg.Auth.ClientID,
+		ClientSecret: cfg.Auth.ClientSecret,
+		Scopes:       cfg.Auth.Scopes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("incident API auth: %w", err)
+	}
+	return authenticator, nil
+}
@@ -0,0 +1,190 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUserAgent is sent until Configure sets another
+const DefaultUserAgent = "my-incident-checker"
+
+// Config applies to every client created by New
+type Config struct {
+	// Proxy is the URL of a proxy for every request. When empty,
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY are used
+	Proxy string
+	// NoProxy lists the hosts reached directly despite Proxy: host names,
+	// IP addresses, or domains with a leading dot, e.g. ".local".
+	// Loopback addresses and localhost are always reached directly
+	NoProxy []string
+	// CAFile is a PEM bundle of certificate authorities trusted in
+	// addition to the system ones
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key presented
+	// to servers that ask for one (mutual TLS)
+	CertFile string
+	KeyFile  string
+	// UserAgent defaults to DefaultUserAgent
+	UserAgent string
+}
+
+var (
+	mu        sync.RWMutex
+	transport http.RoundTripper = http.DefaultTransport
+	userAgent                   = DefaultUserAgent
+)
+
+// UserAgent returns the User-Agent identifying a checker version on a node
+func UserAgent(version, node string) string {
+	return fmt.Sprintf("%s/%s (%s)", DefaultUserAgent, version, node)
+}
+
+// Configure applies cfg to every client, including those created before
+func Configure(cfg Config) error {
+	t, err := NewTransport(cfg)
+	if err != nil {
+		return err
+	}
+	agent := cfg.UserAgent
+	if agent == "" {
+		agent = DefaultUserAgent
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	transport = t
+	userAgent = agent
+	return nil
+}
+
+// SetTransport replaces the transport of every client, e.g. with one that
+// sends requests to an httptest server. It returns a function restoring
+// the previous transport
+func SetTransport(rt http.RoundTripper) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	previous := transport
+	transport = rt
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		transport = previous
+	}
+}
+
+// NewTransport creates a transport with the proxy and TLS settings of cfg
+func NewTransport(cfg Config) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		noProxy := cfg.NoProxy
+		t.Proxy = func(req *http.Request) (*url.URL, error) {
+			if direct(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return proxy, nil
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA bundle %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate needs both a cert_file and a key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// ProxyFor returns the proxy the configured transport uses for u, nil when
+// u is reached directly or the transport was replaced by SetTransport
+func ProxyFor(u *url.URL) (*url.URL, error) {
+	mu.RLock()
+	t, ok := transport.(*http.Transport)
+	mu.RUnlock()
+	if !ok || t.Proxy == nil {
+		return nil, nil
+	}
+	return t.Proxy(&http.Request{Method: http.MethodGet, URL: u, Header: make(http.Header)})
+}
+
+// TLSConfig returns a copy of the TLS settings of the configured transport:
+// the trusted certificate authorities and the client certificate
+func TLSConfig() *tls.Config {
+	mu.RLock()
+	t, ok := transport.(*http.Transport)
+	mu.RUnlock()
+	if !ok || t.TLSClientConfig == nil {
+		return &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return t.TLSClientConfig.Clone()
+}
+
+// direct reports whether host is reached without the proxy
+func direct(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, entry := range noProxy {
+		entry = strings.ToLower(entry)
+		if host == entry || (strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates a client whose requests time out after timeout and use the
+// configured transport and User-Agent
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: sharedTransport{}}
+}
+
+// sharedTransport looks the transport up on every request so that clients
+// created at package initialization follow Configure
+type sharedTransport struct{}
+
+// RoundTrip implements http.RoundTripper
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mu.RLock()
+	rt, agent := transport, userAgent
+	mu.RUnlock()
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", agent)
+	}
+	return rt.RoundTrip(req)
+}
@@ -0,0 +1,254 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reset restores the defaults after a test configured the clients
+func reset(t *testing.T) {
+	t.Cleanup(func() {
+		if err := Configure(Config{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func writePEM(t *testing.T, name, kind string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUserAgent(t *testing.T) {
+	reset(t)
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.UserAgent())
+	}))
+	defer server.Close()
+
+	client := New(time.Second)
+	if err := Configure(Config{UserAgent: UserAgent("v1.2.3", "pi-1")}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", "custom")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	want := []string{"my-incident-checker/v1.2.3 (pi-1)", "custom"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("User-Agents = %q, want %q", got, want)
+	}
+}
+
+func TestCAFile(t *testing.T) {
+	reset(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := New(time.Second)
+
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("request to an untrusted server succeeded")
+	}
+
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	if err := Configure(Config{CAFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with the CA bundle failed: %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientCertificate(t *testing.T) {
+	reset(t)
+	var clientCerts int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts = len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	client := New(time.Second)
+	if err := Configure(Config{CAFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("request without a client certificate succeeded")
+	}
+
+	// The httptest certificate doubles as the client certificate
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Configure(Config{
+		CAFile:   caFile,
+		CertFile: writePEM(t, "cert.pem", "CERTIFICATE", cert.Certificate[0]),
+		KeyFile:  writePEM(t, "key.pem", "PRIVATE KEY", key),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with a client certificate failed: %s", err)
+	}
+	resp.Body.Close()
+	if clientCerts != 1 {
+		t.Errorf("server saw %d client certificates, want 1", clientCerts)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	reset(t)
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	if err := Configure(Config{Proxy: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := New(time.Second).Get("http://status.example.com/incidents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if proxied != "http://status.example.com/incidents" {
+		t.Errorf("proxy got %q", proxied)
+	}
+}
+
+func TestSetTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	restore := SetTransport(redirect{server.URL})
+	resp, err := New(time.Second).Get("https://status.example.com/")
+	restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+}
+
+// redirect sends every request to a test server
+type redirect struct {
+	url string
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := http.NewRequest(req.Method, r.url+req.URL.Path, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	target.Header = req.Header
+	return http.DefaultTransport.RoundTrip(target)
+}
+
+func TestConfigureErrors(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []Config{
+		{Proxy: "://"},
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{CAFile: caFile},
+		{CertFile: caFile},
+		{CertFile: caFile, KeyFile: caFile},
+	}
+	for _, cfg := range tests {
+		if _, err := NewTransport(cfg); err == nil {
+			t.Errorf("NewTransport(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestNoProxy(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.internal:3128")
+	transport, err := NewTransport(Config{Proxy: proxy.String(), NoProxy: []string{".local", "192.168.1.20"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"http://wled.local/json":      false,
+		"http://192.168.1.20/api":     false,
+		"http://localhost:8080/":      false,
+		"http://127.0.0.1/":           false,
+		"https://status.example.com/": true,
+		"http://192.168.1.21/api":     true,
+		"http://notlocal/":            true,
+	}
+	for rawURL, proxied := range tests {
+		req, _ := http.NewRequest(http.MethodGet, rawURL, nil)
+		got, err := transport.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != nil) != proxied {
+			t.Errorf("%s proxied = %v, want %v", rawURL, got != nil, proxied)
+		}
+	}
+}
+
+func TestProxyFor(t *testing.T) {
+	reset(t)
+	if err := Configure(Config{Proxy: "http://proxy.internal:3128", NoProxy: []string{".local"}}); err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse("https://status.example.com:443")
+	if proxy, err := ProxyFor(target); err != nil || proxy == nil || proxy.Host != "proxy.internal:3128" {
+		t.Errorf("ProxyFor(%s) = %v, %v", target, proxy, err)
+	}
+	local, _ := url.Parse("http://wled.local:80")
+	if proxy, err := ProxyFor(local); err != nil || proxy != nil {
+		t.Errorf("ProxyFor(%s) = %v, %v, want a direct connection", local, proxy, err)
+	}
+
+	// A replaced transport has no proxy the package knows of
+	restore := SetTransport(redirect{"http://127.0.0.1"})
+	defer restore()
+	if proxy, err := ProxyFor(target); err != nil || proxy != nil {
+		t.Errorf("ProxyFor(%s) = %v, %v with a replaced transport", target, proxy, err)
+	}
+	if config := TLSConfig(); config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("TLSConfig() = %+v with a replaced transport", config)
+	}
+}
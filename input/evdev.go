@@ -0,0 +1,85 @@
+package input
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Linux input event ABI (linux/input.h)
+const (
+	evKey = 0x01
+
+	keyReleased   = 0
+	keyPressed    = 1
+	keyAutorepeat = 2
+)
+
+// EvdevSource reads a key of a Linux input device such as a USB foot
+// pedal or a big red button (/dev/input/eventN)
+type EvdevSource struct {
+	r    io.ReadCloser
+	code uint16
+	buf  []byte
+}
+
+// NewEvdevSource opens an input device. code selects the key, e.g. 28 for
+// Enter; zero accepts any key. The device is grabbed so its key presses
+// don't also reach the console
+func NewEvdevSource(path string, code uint16) (*EvdevSource, error) {
+	if !evdevSupported {
+		return nil, fmt.Errorf("input devices not supported on %s", runtime.GOOS)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input device: %w", err)
+	}
+	// Not every device can be grabbed; the button works either way
+	grabEvdev(f)
+	return newEvdevSource(f, code), nil
+}
+
+func newEvdevSource(r io.ReadCloser, code uint16) *EvdevSource {
+	return &EvdevSource{r: r, code: code, buf: make([]byte, timevalSize+8)}
+}
+
+// Read returns the next press or release of the key
+func (s *EvdevSource) Read() (Event, error) {
+	for {
+		if _, err := io.ReadFull(s.r, s.buf); err != nil {
+			if err == io.EOF || errors.Is(err, os.ErrClosed) {
+				return Event{}, ErrClosed
+			}
+			return Event{}, fmt.Errorf("failed to read input event: %w", err)
+		}
+
+		var sec, usec int64
+		if timevalSize == 16 {
+			sec = int64(binary.LittleEndian.Uint64(s.buf[0:]))
+			usec = int64(binary.LittleEndian.Uint64(s.buf[8:]))
+		} else {
+			sec = int64(int32(binary.LittleEndian.Uint32(s.buf[0:])))
+			usec = int64(int32(binary.LittleEndian.Uint32(s.buf[4:])))
+		}
+		typ := binary.LittleEndian.Uint16(s.buf[timevalSize:])
+		code := binary.LittleEndian.Uint16(s.buf[timevalSize+2:])
+		value := int32(binary.LittleEndian.Uint32(s.buf[timevalSize+4:]))
+
+		if typ != evKey || (s.code != 0 && code != s.code) || value == keyAutorepeat {
+			continue
+		}
+		return Event{
+			Pressed: value == keyPressed,
+			Time:    time.Unix(sec, usec*int64(time.Microsecond)),
+		}, nil
+	}
+}
+
+// Close closes the device, ending a blocked Read
+func (s *EvdevSource) Close() error {
+	return s.r.Close()
+}
@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+package input
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// evdevGrabIoctl is EVIOCGRAB, _IOW('E', 0x90, int)
+const evdevGrabIoctl = 0x40044590
+
+// evdevSupported reports whether input devices can be opened
+const evdevSupported = true
+
+// timevalSize is the size of struct timeval in struct input_event, 16
+// bytes on 64-bit and 8 on 32-bit platforms
+const timevalSize = int(unsafe.Sizeof(unix.Timeval{}))
+
+// grabEvdev keeps the key presses of a device from reaching the console
+func grabEvdev(f *os.File) error {
+	return unix.IoctlSetInt(int(f.Fd()), evdevGrabIoctl, 1)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package input
+
+import "os"
+
+// evdevSupported is false since input devices only exist on Linux
+const evdevSupported = false
+
+// timevalSize only sizes the events of readers created in tests
+const timevalSize = 16
+
+func grabEvdev(f *os.File) error {
+	return nil
+}
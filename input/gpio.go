@@ -0,0 +1,70 @@
+package input
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultPollInterval is how often a GPIO button is sampled
+const defaultPollInterval = 5 * time.Millisecond
+
+// level reads whether a button is held
+type level interface {
+	Active() (bool, error)
+	Close() error
+}
+
+// GPIOSource samples a button wired to a GPIO line
+type GPIOSource struct {
+	line     level
+	interval time.Duration
+
+	last      bool
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewGPIOSource opens a button on a line of a chip. Buttons that pull the
+// line to ground need activeLow
+func NewGPIOSource(chip string, line int, activeLow bool) (*GPIOSource, error) {
+	in, err := openGPIOLine(chip, line, activeLow)
+	if err != nil {
+		return nil, err
+	}
+	return newGPIOSource(in, defaultPollInterval), nil
+}
+
+func newGPIOSource(line level, interval time.Duration) *GPIOSource {
+	return &GPIOSource{line: line, interval: interval, closed: make(chan struct{})}
+}
+
+// Read blocks until the level of the line changes
+func (s *GPIOSource) Read() (Event, error) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.closed:
+			return Event{}, ErrClosed
+		case <-ticker.C:
+		}
+		active, err := s.line.Active()
+		if err != nil {
+			return Event{}, err
+		}
+		if active != s.last {
+			s.last = active
+			return Event{Pressed: active, Time: time.Now()}, nil
+		}
+	}
+}
+
+// Close releases the line, ending a blocked Read
+func (s *GPIOSource) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		err = s.line.Close()
+	})
+	return err
+}
@@ -0,0 +1,83 @@
+package input
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"my-incident-checker/internal/gpio"
+)
+
+// gpioLine reads one GPIO line through the character device or, on older
+// kernels, the sysfs interface
+type gpioLine struct {
+	activeLow bool
+	// handle is the line handle of the character device, nil with sysfs
+	handle *gpio.Handle
+	// value is the sysfs value file of the line
+	value string
+}
+
+// openGPIOLine requests a line of a chip ("gpiochip0" when empty) as
+// input. With activeLow, a low level counts as active, as for buttons that
+// pull the line to ground
+func openGPIOLine(chip string, line int, activeLow bool) (*gpioLine, error) {
+	if chip == "" {
+		chip = "gpiochip0"
+	}
+	in := &gpioLine{activeLow: activeLow}
+
+	handle, chardevErr := gpio.RequestLines(chip, []int{line}, gpio.HandleRequestInput, gpio.HandleData{})
+	if chardevErr == nil {
+		in.handle = handle
+		return in, nil
+	}
+
+	value, err := openGPIOLineSysfs(chip, line)
+	if err != nil {
+		return nil, fmt.Errorf("%s; sysfs fallback: %w", chardevErr.Error(), err)
+	}
+	in.value = value
+	return in, nil
+}
+
+// openGPIOLineSysfs exports a line and configures it as input, returning
+// its value file
+func openGPIOLineSysfs(chip string, line int) (string, error) {
+	dir, err := gpio.SysfsExport(chip, line)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "direction"), []byte("in"), 0644); err != nil {
+		return "", fmt.Errorf("failed to configure %s: %w", filepath.Base(dir), err)
+	}
+	return filepath.Join(dir, "value"), nil
+}
+
+// Active reports whether the line is at its active level
+func (g *gpioLine) Active() (bool, error) {
+	var high bool
+	if g.handle != nil {
+		data, err := g.handle.Values()
+		if err != nil {
+			return false, fmt.Errorf("failed to read GPIO line: %w", err)
+		}
+		high = data.Values[0] != 0
+	} else {
+		data, err := os.ReadFile(g.value)
+		if err != nil {
+			return false, fmt.Errorf("failed to read GPIO line: %w", err)
+		}
+		high = strings.TrimSpace(string(data)) == "1"
+	}
+	return high != g.activeLow, nil
+}
+
+// Close releases the line
+func (g *gpioLine) Close() error {
+	if g.handle != nil {
+		return g.handle.Close()
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"my-incident-checker/internal/gpio"
+)
+
+// fakeGPIOChip stands in for the kernel side of /dev/gpiochipN, handing
+// out a temp file as the line handle and reporting level for reads
+type fakeGPIOChip struct {
+	t      *testing.T
+	flags  uint32
+	offset uint32
+	level  uint8
+}
+
+func newFakeGPIOChip(t *testing.T) *fakeGPIOChip {
+	chip := &fakeGPIOChip{t: t}
+	old := gpio.Ioctl
+	gpio.Ioctl = chip.ioctl
+	t.Cleanup(func() { gpio.Ioctl = old })
+	return chip
+}
+
+func (c *fakeGPIOChip) ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	switch req {
+	case gpio.GetLineHandleIoctl:
+		r := (*gpio.HandleRequest)(arg)
+		c.flags, c.offset = r.Flags, r.LineOffsets[0]
+		handle, err := os.Create(filepath.Join(c.t.TempDir(), "linehandle"))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		dup, err := syscall.Dup(int(handle.Fd()))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		handle.Close()
+		r.Fd = int32(dup)
+		return nil
+	case gpio.GetLineValuesIoctl:
+		(*gpio.HandleData)(arg).Values[0] = c.level
+		return nil
+	}
+	return syscall.ENOTTY
+}
+
+func TestGPIOLine(t *testing.T) {
+	chip := newFakeGPIOChip(t)
+	oldDev := gpio.DevRoot
+	gpio.DevRoot = t.TempDir()
+	defer func() { gpio.DevRoot = oldDev }()
+	if err := os.WriteFile(filepath.Join(gpio.DevRoot, "gpiochip0"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := openGPIOLine("", 4, true)
+	if err != nil {
+		t.Fatalf("openGPIOLine() error = %v", err)
+	}
+	defer in.Close()
+	if chip.flags != gpio.HandleRequestInput || chip.offset != 4 {
+		t.Errorf("requested flags %d line %d, want input line 4", chip.flags, chip.offset)
+	}
+
+	chip.level = 1
+	if active, err := in.Active(); err != nil || active {
+		t.Errorf("Active() = %v, %v at high level, want inactive for active-low", active, err)
+	}
+	chip.level = 0
+	if active, _ := in.Active(); !active {
+		t.Error("Active() = false at low level, want active for active-low")
+	}
+}
+
+func TestGPIOLineSysfs(t *testing.T) {
+	root := t.TempDir()
+	oldDev, oldSys := gpio.DevRoot, gpio.SysRoot
+	gpio.DevRoot, gpio.SysRoot = filepath.Join(root, "dev"), filepath.Join(root, "sys")
+	defer func() { gpio.DevRoot, gpio.SysRoot = oldDev, oldSys }()
+
+	// A chip numbered from 512 whose line 4 is already exported
+	sysfs := gpio.SysfsRoot()
+	device := filepath.Join(root, "devices", "gpiochip0")
+	line := filepath.Join(sysfs, "gpio516")
+	for _, dir := range []string{filepath.Join(sysfs, "gpiochip512"), device, line} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(device, filepath.Join(sysfs, "gpiochip512", "device")); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(sysfs, "gpiochip512", "base"): "512\n",
+		filepath.Join(line, "direction"):            "out\n",
+		filepath.Join(line, "value"):                "1\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in, err := openGPIOLine("", 4, false)
+	if err != nil {
+		t.Fatalf("openGPIOLine() error = %v", err)
+	}
+	if direction, _ := os.ReadFile(filepath.Join(line, "direction")); string(direction) != "in" {
+		t.Errorf("direction = %q, want in", direction)
+	}
+	if active, err := in.Active(); err != nil || !active {
+		t.Errorf("Active() = %v, %v at high level, want active", active, err)
+	}
+}
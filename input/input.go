@@ -0,0 +1,134 @@
+// Package input reads push buttons, debounces them and reports short and
+// long presses
+package input
+
+import (
+	"errors"
+	"time"
+)
+
+// Default press timing
+const (
+	DefaultDebounce  = 50 * time.Millisecond
+	DefaultLongPress = 2 * time.Second
+)
+
+// ErrClosed is returned by sources after Close
+var ErrClosed = errors.New("input closed")
+
+// Event is a raw edge of a button
+type Event struct {
+	Pressed bool
+	Time    time.Time
+}
+
+// Source delivers the raw edges of one button
+type Source interface {
+	// Read blocks until the next edge
+	Read() (Event, error)
+	Close() error
+}
+
+// Press is a debounced press of a button
+type Press struct {
+	// Time is when the button went down
+	Time     time.Time
+	Duration time.Duration
+	Long     bool
+}
+
+// Button turns the edges of a source into presses
+type Button struct {
+	source    Source
+	debounce  time.Duration
+	longPress time.Duration
+}
+
+// NewButton creates a Button. An edge only counts once the level held for
+// debounce; presses held for longPress or longer are long
+func NewButton(source Source, debounce, longPress time.Duration) *Button {
+	return &Button{source: source, debounce: debounce, longPress: longPress}
+}
+
+// Run calls handle for every press until the source fails or is closed
+func (b *Button) Run(handle func(Press)) error {
+	events := make(chan Event)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			ev, err := b.source.Read()
+			if err != nil {
+				failed <- err
+				return
+			}
+			events <- ev
+		}
+	}()
+
+	d := &debouncer{debounce: b.debounce, longPress: b.longPress}
+	var settle <-chan time.Time
+	for {
+		select {
+		case ev := <-events:
+			if press := d.feed(ev); press != nil {
+				handle(*press)
+			}
+			settle = nil
+			if d.pending != nil {
+				settle = time.After(b.debounce)
+			}
+		case <-settle:
+			settle = nil
+			if press := d.settle(); press != nil {
+				handle(*press)
+			}
+		case err := <-failed:
+			return err
+		}
+	}
+}
+
+// debouncer accepts an edge once no opposite edge follows within the
+// debounce time. Event timestamps decide, so it can be fed recorded streams
+type debouncer struct {
+	debounce  time.Duration
+	longPress time.Duration
+
+	pressed   bool // accepted level
+	pressedAt time.Time
+	pending   *Event
+}
+
+// feed processes an edge and returns a press completed by it
+func (d *debouncer) feed(ev Event) *Press {
+	var press *Press
+	if d.pending != nil && ev.Time.Sub(d.pending.Time) >= d.debounce {
+		press = d.settle()
+	}
+
+	switch {
+	case d.pending != nil && ev.Pressed == d.pressed:
+		// Bounced back before it held long enough
+		d.pending = nil
+	case d.pending == nil && ev.Pressed != d.pressed:
+		pending := ev
+		d.pending = &pending
+	}
+	return press
+}
+
+// settle accepts the pending edge and returns the press completed by it
+func (d *debouncer) settle() *Press {
+	if d.pending == nil {
+		return nil
+	}
+	ev := *d.pending
+	d.pending = nil
+	d.pressed = ev.Pressed
+	if ev.Pressed {
+		d.pressedAt = ev.Time
+		return nil
+	}
+	duration := ev.Time.Sub(d.pressedAt)
+	return &Press{Time: d.pressedAt, Duration: duration, Long: duration >= d.longPress}
+}
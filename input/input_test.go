@@ -0,0 +1,165 @@
+package input
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource replays events, then reports ErrClosed
+type fakeSource struct {
+	events chan Event
+}
+
+func (s *fakeSource) Read() (Event, error) {
+	ev, ok := <-s.events
+	if !ok {
+		return Event{}, ErrClosed
+	}
+	return ev, nil
+}
+
+func (s *fakeSource) Close() error { return nil }
+
+func TestDebouncer(t *testing.T) {
+	start := time.Date(2025, 1, 9, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	d := &debouncer{debounce: 20 * time.Millisecond, longPress: time.Second}
+
+	events := []Event{
+		// A bouncy short press
+		{true, at(0)}, {false, at(3)}, {true, at(6)},
+		{false, at(300)}, {true, at(305)}, {false, at(309)},
+		// A glitch shorter than the debounce time
+		{true, at(1000)}, {false, at(1005)},
+		// A long press
+		{true, at(2000)}, {false, at(3500)},
+	}
+	var presses []Press
+	for _, ev := range events {
+		if press := d.feed(ev); press != nil {
+			presses = append(presses, *press)
+		}
+	}
+	if press := d.settle(); press != nil {
+		presses = append(presses, *press)
+	}
+
+	want := []Press{
+		{Time: at(6), Duration: 303 * time.Millisecond},
+		{Time: at(2000), Duration: 1500 * time.Millisecond, Long: true},
+	}
+	if !reflect.DeepEqual(presses, want) {
+		t.Errorf("presses = %+v, want %+v", presses, want)
+	}
+}
+
+func TestButtonRun(t *testing.T) {
+	source := &fakeSource{events: make(chan Event)}
+	b := NewButton(source, 10*time.Millisecond, 100*time.Millisecond)
+
+	var mu sync.Mutex
+	var presses []Press
+	done := make(chan error)
+	go func() {
+		done <- b.Run(func(p Press) {
+			mu.Lock()
+			presses = append(presses, p)
+			mu.Unlock()
+		})
+	}()
+
+	now := time.Now()
+	source.events <- Event{Pressed: true, Time: now}
+	source.events <- Event{Pressed: false, Time: now.Add(150 * time.Millisecond)}
+	// The release is only accepted once it held, without a further event
+	time.Sleep(50 * time.Millisecond)
+	close(source.events)
+	if err := <-done; err != ErrClosed {
+		t.Errorf("Run() error = %v, want ErrClosed", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(presses) != 1 || !presses[0].Long {
+		t.Errorf("presses = %+v, want one long press", presses)
+	}
+}
+
+// inputEvent encodes a struct input_event for the running platform
+func inputEvent(sec int64, typ, code uint16, value int32) []byte {
+	var b bytes.Buffer
+	if timevalSize == 16 {
+		binary.Write(&b, binary.LittleEndian, [2]int64{sec, 250000})
+	} else {
+		binary.Write(&b, binary.LittleEndian, [2]int32{int32(sec), 250000})
+	}
+	binary.Write(&b, binary.LittleEndian, typ)
+	binary.Write(&b, binary.LittleEndian, code)
+	binary.Write(&b, binary.LittleEndian, value)
+	return b.Bytes()
+}
+
+func TestEvdevSource(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(inputEvent(100, 0x04, 4, 458792)) // EV_MSC scan code
+	stream.Write(inputEvent(100, evKey, 30, keyPressed))
+	stream.Write(inputEvent(100, evKey, 28, keyPressed))
+	stream.Write(inputEvent(100, 0x00, 0, 0)) // EV_SYN
+	stream.Write(inputEvent(101, evKey, 28, keyAutorepeat))
+	stream.Write(inputEvent(102, evKey, 28, keyReleased))
+
+	source := newEvdevSource(io.NopCloser(&stream), 28)
+	want := []Event{
+		{Pressed: true, Time: time.Unix(100, 250000000)},
+		{Pressed: false, Time: time.Unix(102, 250000000)},
+	}
+	for _, w := range want {
+		ev, err := source.Read()
+		if err != nil || ev.Pressed != w.Pressed || !ev.Time.Equal(w.Time) {
+			t.Errorf("Read() = %+v, %v, want %+v", ev, err, w)
+		}
+	}
+	if _, err := source.Read(); err != ErrClosed {
+		t.Errorf("Read() at end error = %v, want ErrClosed", err)
+	}
+}
+
+// fakeLevel is a button level set by the test
+type fakeLevel struct {
+	mu     sync.Mutex
+	active bool
+}
+
+func (l *fakeLevel) Active() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active, nil
+}
+
+func (l *fakeLevel) Close() error { return nil }
+
+func TestGPIOSource(t *testing.T) {
+	line := &fakeLevel{}
+	source := newGPIOSource(line, time.Millisecond)
+
+	line.mu.Lock()
+	line.active = true
+	line.mu.Unlock()
+	ev, err := source.Read()
+	if err != nil || !ev.Pressed {
+		t.Errorf("Read() = %+v, %v, want press", ev, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		source.Close()
+	}()
+	if _, err := source.Read(); err != ErrClosed {
+		t.Errorf("Read() after Close error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package gpio requests GPIO lines through the Linux GPIO character device
+// or, on older kernels, the legacy sysfs interface. It is shared by the
+// lamps of the lights package and the buttons of the input package
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+)
+
+// GPIO character device ABI (linux/gpio.h, v1 line handles)
+const (
+	HandlesMax          = 64
+	HandleRequestInput  = 1 << 0
+	HandleRequestOutput = 1 << 1
+
+	GetLineHandleIoctl = 0xC16CB403 // _IOWR(0xB4, 0x03, struct gpiohandle_request)
+	GetLineValuesIoctl = 0xC040B408 // _IOWR(0xB4, 0x08, struct gpiohandle_data)
+	SetLineValuesIoctl = 0xC040B409 // _IOWR(0xB4, 0x09, struct gpiohandle_data)
+)
+
+var (
+	// DevRoot and SysRoot are replaced in tests
+	DevRoot = "/dev"
+	SysRoot = "/sys"
+)
+
+// HandleRequest mirrors struct gpiohandle_request
+type HandleRequest struct {
+	LineOffsets   [HandlesMax]uint32
+	Flags         uint32
+	DefaultValues [HandlesMax]uint8
+	ConsumerLabel [32]byte
+	Lines         uint32
+	Fd            int32
+}
+
+// HandleData mirrors struct gpiohandle_data
+type HandleData struct {
+	Values [HandlesMax]uint8
+}
+
+// Ioctl issues an ioctl on a GPIO descriptor, replaced in tests by a fake
+// gpiochip
+var Ioctl = ioctl
+
+// ChipPath returns the device of a chip given by name or path
+func ChipPath(chip string) string {
+	if strings.ContainsRune(chip, '/') {
+		return chip
+	}
+	return filepath.Join(DevRoot, chip)
+}
+
+// Handle holds lines requested through the character device
+type Handle struct {
+	file *os.File
+}
+
+// RequestLines requests lines of a chip with HandleRequestInput or
+// HandleRequestOutput. Outputs start at the levels in defaults
+func RequestLines(chip string, offsets []int, flags uint32, defaults HandleData) (*Handle, error) {
+	if len(offsets) > HandlesMax {
+		return nil, fmt.Errorf("too many GPIO lines: %d", len(offsets))
+	}
+	path := ChipPath(chip)
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GPIO chip: %w", err)
+	}
+	defer f.Close()
+
+	req := HandleRequest{
+		Flags:         flags,
+		DefaultValues: defaults.Values,
+		Lines:         uint32(len(offsets)),
+	}
+	copy(req.ConsumerLabel[:], "my-incident-checker")
+	for i, offset := range offsets {
+		req.LineOffsets[i] = uint32(offset)
+	}
+	if err := Ioctl(f.Fd(), GetLineHandleIoctl, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("failed to request GPIO lines from %s: %w", path, err)
+	}
+	return &Handle{file: os.NewFile(uintptr(req.Fd), path+"-lines")}, nil
+}
+
+// Values reads the levels of the lines, in the order they were requested
+func (h *Handle) Values() (HandleData, error) {
+	var data HandleData
+	if err := Ioctl(h.file.Fd(), GetLineValuesIoctl, unsafe.Pointer(&data)); err != nil {
+		return HandleData{}, err
+	}
+	return data, nil
+}
+
+// SetValues sets the levels of all output lines at once
+func (h *Handle) SetValues(data HandleData) error {
+	return Ioctl(h.file.Fd(), SetLineValuesIoctl, unsafe.Pointer(&data))
+}
+
+// Close releases the lines
+func (h *Handle) Close() error {
+	return h.file.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// ioctl fails on platforms without the GPIO character device, leaving the
+// sysfs interface where it exists
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	return fmt.Errorf("GPIO character device not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,66 @@
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SysfsRoot returns the directory of the legacy sysfs interface
+func SysfsRoot() string {
+	return filepath.Join(SysRoot, "class", "gpio")
+}
+
+// SysfsChipBase finds the global number of a chip's first line by
+// matching the gpiochipN device behind each sysfs chip entry. The chip is
+// given by name or path
+func SysfsChipBase(chip string) (int, error) {
+	root := SysfsRoot()
+	chip = filepath.Base(chip)
+	entries, err := filepath.Glob(filepath.Join(root, "gpiochip*"))
+	if err != nil || len(entries) == 0 {
+		return 0, fmt.Errorf("GPIO sysfs interface not available under %s", root)
+	}
+	for _, entry := range entries {
+		device, err := filepath.EvalSymlinks(filepath.Join(entry, "device"))
+		if err != nil || filepath.Base(device) != chip {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(entry, "base"))
+		if err != nil {
+			return 0, fmt.Errorf("failed to read GPIO chip base: %w", err)
+		}
+		return strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	return 0, fmt.Errorf("GPIO chip %s not found under %s", chip, root)
+}
+
+// SysfsExport exports a line of a chip unless it already is, returning the
+// directory holding its direction and value files
+func SysfsExport(chip string, offset int) (string, error) {
+	base, err := SysfsChipBase(chip)
+	if err != nil {
+		return "", err
+	}
+	root := SysfsRoot()
+	number := strconv.Itoa(base + offset)
+	dir := filepath.Join(root, "gpio"+number)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	if err := os.WriteFile(filepath.Join(root, "export"), []byte(number), 0200); err != nil {
+		return "", fmt.Errorf("failed to export GPIO %s: %w", number, err)
+	}
+
+	// udev may take a moment to create the line directory
+	for i := 0; i < 20; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "direction")); err == nil {
+			return dir, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return "", fmt.Errorf("GPIO %s did not appear after export", number)
+}
@@ -0,0 +1,298 @@
+package lights
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultBlinkPeriod is the length of one on/off cycle of a software blink
+const DefaultBlinkPeriod = time.Second
+
+// ErrBlinkUnsupported is returned by lights that can only switch lamps on
+// and off. Wrap them in an Animator to blink in software
+var ErrBlinkUnsupported = errors.New("blink not supported by this light")
+
+// Step is one frame of an animation. State is applied and held for
+// Duration; a nil State turns the light off
+type Step struct {
+	State    State
+	Duration time.Duration
+}
+
+// Pattern is a sequence of steps played in a loop
+type Pattern []Step
+
+// BlinkPattern flashes a color on and off with equal timing
+func BlinkPattern(color StandardState, period time.Duration) Pattern {
+	return Pattern{
+		{State: TowerStateFor(color, ModeOn), Duration: period / 2},
+		{State: nil, Duration: period / 2},
+	}
+}
+
+// PulsePattern shows a color briefly and then stays dark, like a heartbeat
+func PulsePattern(color StandardState, on, off time.Duration) Pattern {
+	return Pattern{
+		{State: TowerStateFor(color, ModeOn), Duration: on},
+		{State: nil, Duration: off},
+	}
+}
+
+// Animator emulates blink, pulse and arbitrary patterns in software for
+// lights that only support on and off. Animations run on a goroutine and
+// stop on the next state change or when the Animator is closed
+type Animator struct {
+	light  Light
+	period time.Duration
+	// after times the animation steps, replaced in tests
+	after func(time.Duration) <-chan time.Time
+
+	mu    sync.Mutex
+	lamps TowerState // lamp modes requested through SetLamp
+	// lampsShown is set while the light shows lamps rather than a color
+	// or pattern set directly
+	lampsShown bool
+	stop       chan struct{}
+	done       chan struct{}
+	// blinker is set while the running goroutine toggles blinking lamps
+	// rather than playing a pattern
+	blinker bool
+}
+
+// NewAnimator wraps light so that blinking is done in software
+func NewAnimator(light Light, blinkPeriod time.Duration) *Animator {
+	return &Animator{
+		light:  light,
+		period: blinkPeriod,
+		after:  time.After,
+	}
+}
+
+// On stops any animation and shows a steady state
+func (a *Animator) On(cmd interface{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopLocked()
+	a.lamps = TowerState{}
+	a.lampsShown = false
+	return a.light.On(cmd)
+}
+
+// Clear stops any animation and turns the light off
+func (a *Animator) Clear() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopLocked()
+	a.lamps = TowerState{}
+	a.lampsShown = false
+	return a.light.Clear()
+}
+
+// Blink flashes a color until the next state change
+func (a *Animator) Blink(cmd interface{}) error {
+	color, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for Animator")
+	}
+	return a.Play(BlinkPattern(color, a.period))
+}
+
+// Pulse briefly flashes a color once per blink period
+func (a *Animator) Pulse(color StandardState) error {
+	return a.Play(PulsePattern(color, a.period/8, a.period-a.period/8))
+}
+
+// Play loops a pattern until the next state change. The first step is
+// applied before Play returns so errors surface to the caller
+func (a *Animator) Play(pattern Pattern) error {
+	if len(pattern) == 0 {
+		return fmt.Errorf("empty animation pattern")
+	}
+	for _, step := range pattern {
+		if step.Duration <= 0 {
+			return fmt.Errorf("animation step duration must be positive")
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopLocked()
+	a.lamps = TowerState{}
+	a.lampsShown = false
+	return a.playLocked(pattern)
+}
+
+// SetLamp sets a single lamp. Blinking lamps are toggled in software while
+// the other lamps stay as they are
+func (a *Animator) SetLamp(lamp Lamp, mode LampMode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Re-requesting a lamp while blinking mustn't restart the blink phase
+	unchanged := a.lampsShown && a.lamps.Mode(lamp) == mode && a.stop != nil
+	a.lampsShown = true
+
+	tower, ok := a.light.(TowerLight)
+	if !ok {
+		if unchanged {
+			return nil
+		}
+		// Single-color lights show the most severe lamp
+		a.stopLocked()
+		a.lamps = a.lamps.With(lamp, mode)
+		color, primary := a.lamps.Primary()
+		switch primary {
+		case ModeOn:
+			return a.light.On(color)
+		case ModeBlink:
+			return a.playLocked(BlinkPattern(color, a.period))
+		}
+		return a.light.Clear()
+	}
+
+	if unchanged && mode == ModeBlink {
+		return nil
+	}
+	if !a.blinker {
+		a.stopLocked()
+	}
+	a.lamps = a.lamps.With(lamp, mode)
+	hardwareMode := mode
+	if mode == ModeBlink {
+		hardwareMode = ModeOn
+	}
+	if err := tower.SetLamp(lamp, hardwareMode); err != nil {
+		return err
+	}
+
+	if !a.blinkingLocked() {
+		a.stopLocked()
+		return nil
+	}
+	if a.stop == nil {
+		a.blinker = true
+		a.startLocked(func(stop <-chan struct{}) {
+			// The blinking lamps were just switched on
+			on := true
+			for {
+				select {
+				case <-a.after(a.period / 2):
+				case <-stop:
+					return
+				}
+				on = !on
+				if !a.step(stop, func() error { return a.setBlinkingLamps(tower, on) }) {
+					return
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// Close stops any running animation
+func (a *Animator) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stopLocked()
+}
+
+// playLocked applies the first step of pattern and loops the rest on a
+// new goroutine
+func (a *Animator) playLocked(pattern Pattern) error {
+	if err := a.applyStep(pattern[0]); err != nil {
+		return err
+	}
+	a.startLocked(func(stop <-chan struct{}) {
+		for i := 0; ; i = (i + 1) % len(pattern) {
+			select {
+			case <-a.after(pattern[i].Duration):
+			case <-stop:
+				return
+			}
+			next := pattern[(i+1)%len(pattern)]
+			if !a.step(stop, func() error { return a.applyStep(next) }) {
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// step runs one animation step under the lock, unless the animation was
+// stopped while waiting for it. It reports whether the animation goes on
+func (a *Animator) step(stop <-chan struct{}, fn func() error) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-stop:
+		return false
+	default:
+	}
+	if err := fn(); err != nil {
+		log.Printf("Error animating light: %s", err.Error())
+	}
+	return true
+}
+
+// setBlinkingLamps switches every lamp in blink mode on or off
+func (a *Animator) setBlinkingLamps(tower TowerLight, on bool) error {
+	mode := ModeOff
+	if on {
+		mode = ModeOn
+	}
+	for _, lamp := range Lamps {
+		if a.lamps.Mode(lamp) != ModeBlink {
+			continue
+		}
+		if err := tower.SetLamp(lamp, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Animator) applyStep(step Step) error {
+	if step.State == nil {
+		return a.light.Clear()
+	}
+	return step.State.Apply(a.light)
+}
+
+func (a *Animator) blinkingLocked() bool {
+	for _, lamp := range Lamps {
+		if a.lamps.Mode(lamp) == ModeBlink {
+			return true
+		}
+	}
+	return false
+}
+
+// startLocked runs fn on a new goroutine until stopLocked is called
+func (a *Animator) startLocked(fn func(stop <-chan struct{})) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	a.stop, a.done = stop, done
+	go func() {
+		defer close(done)
+		fn(stop)
+	}()
+}
+
+// stopLocked stops the running animation and waits for it to exit. The
+// animation goroutine may be waiting for the lock, so it is released
+// while waiting
+func (a *Animator) stopLocked() {
+	for a.stop != nil {
+		stop, done := a.stop, a.done
+		a.stop, a.done = nil, nil
+		a.blinker = false
+		close(stop)
+		a.mu.Unlock()
+		<-done
+		a.mu.Lock()
+	}
+}
@@ -0,0 +1,128 @@
+package lights
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// manualClock times the steps of an Animator. tick returns once the
+// animation received it, after the steps before it completed
+type manualClock struct {
+	ticks chan time.Time
+}
+
+func newManualClock(a *Animator) *manualClock {
+	c := &manualClock{ticks: make(chan time.Time)}
+	a.after = func(time.Duration) <-chan time.Time { return c.ticks }
+	return c
+}
+
+func (c *manualClock) tick(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case c.ticks <- time.Time{}:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("animation did not wait for tick %d", i+1)
+		}
+	}
+}
+
+func TestAnimatorBlinkSingleColor(t *testing.T) {
+	light := &recordingLight{}
+	a := NewAnimator(light, time.Second)
+	clock := newManualClock(a)
+
+	if err := a.Blink(StateRed); err != nil {
+		t.Fatalf("Blink() error = %v", err)
+	}
+	// The fourth tick is received after the third step completed
+	clock.tick(t, 4)
+	a.Close()
+
+	// on, off, on, off... starting with the color
+	want := []string{"on:red", "clear", "on:red", "clear"}
+	if len(light.calls) < len(want) || !reflect.DeepEqual(light.calls[:len(want)], want) {
+		t.Fatalf("calls = %v, want them to start with %v", light.calls, want)
+	}
+
+	// Nothing runs once closed
+	n := len(light.calls)
+	select {
+	case clock.ticks <- time.Time{}:
+		t.Error("animation kept running after Close")
+	default:
+	}
+	if len(light.calls) != n {
+		t.Errorf("animation kept running after Close: %v", light.calls)
+	}
+}
+
+func TestAnimatorStopsOnStateChange(t *testing.T) {
+	light := &recordingLight{}
+	a := NewAnimator(light, time.Second)
+	clock := newManualClock(a)
+
+	a.Blink(StateYellow)
+	clock.tick(t, 2)
+	a.On(StateGreen)
+	n := len(light.calls)
+	select {
+	case clock.ticks <- time.Time{}:
+		t.Error("animation kept running after On()")
+	default:
+	}
+	a.Close()
+
+	if len(light.calls) != n || light.calls[n-1] != "on:green" {
+		t.Errorf("expected animation to stop at on:green, got %v", light.calls)
+	}
+}
+
+func TestAnimatorBlinksTowerLamps(t *testing.T) {
+	tower := &recordingTower{}
+	a := NewAnimator(tower, time.Second)
+	clock := newManualClock(a)
+
+	if err := (TowerState{Red: ModeOn, Yellow: ModeBlink}).Apply(a); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	clock.tick(t, 3)
+	a.Close()
+
+	// Only the blinking lamp toggles
+	want := []string{
+		"red=on", "yellow=on", "green=off", "buzzer=off",
+		"yellow=off", "yellow=on",
+	}
+	if len(tower.calls) < len(want) || !reflect.DeepEqual(tower.calls[:len(want)], want) {
+		t.Errorf("calls = %v, want them to start with %v", tower.calls, want)
+	}
+	for _, call := range tower.calls[len(want):] {
+		if call != "yellow=off" {
+			t.Errorf("unexpected call %q after the blink steps", call)
+		}
+	}
+}
+
+func TestAnimatorKeepsBlinkPhase(t *testing.T) {
+	light := &recordingLight{}
+	a := NewAnimator(light, time.Second)
+	clock := newManualClock(a)
+
+	// Setting the same lamps again, as every poll does, doesn't restart
+	// the blinking color
+	blinking := TowerState{Red: ModeBlink}
+	blinking.Apply(a)
+	blinking.Apply(a)
+	// The second tick is received after the first step completed
+	clock.tick(t, 2)
+	blinking.Apply(a)
+	a.Close()
+
+	want := []string{"on:red", "clear", "on:red"}
+	if len(light.calls) < 2 || len(light.calls) > len(want) || !reflect.DeepEqual(light.calls, want[:len(light.calls)]) {
+		t.Errorf("calls = %v, want %v", light.calls, want)
+	}
+}
@@ -0,0 +1,297 @@
+package lights
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Priority orders claims on a light; the highest active claim is shown
+type Priority int
+
+// Priorities of the subsystems that claim the light. Gaps leave room for
+// claims in between
+const (
+	PriorityIncident     Priority = 100
+	PriorityAcknowledged Priority = 200
+	PriorityConnectivity Priority = 300
+	PriorityStale        Priority = 350
+	PriorityMaintenance  Priority = 400
+	PrioritySelfTest     Priority = 500
+	PriorityOverride     Priority = 600
+)
+
+// Claim is a request by one subsystem to show a state
+type Claim struct {
+	Source   string
+	Priority Priority
+	State    State
+	// Expires is when the claim lapses, zero for never
+	Expires time.Time
+}
+
+// Lamps returns the lamps the claim shows
+func (c Claim) Lamps() TowerState {
+	return lampsOf(c.State)
+}
+
+// overlay is a set of lamps lit on top of the winning claim
+type overlay struct {
+	priority Priority
+	lamps    TowerState
+}
+
+// Arbiter sits in front of a light and shows the highest priority active
+// claim. Claims of equal priority are decided by the latest. Without any
+// claim the light is cleared. Overlays light extra lamps on top of claims
+// they are not outranked by
+type Arbiter struct {
+	light Light
+
+	mu       sync.Mutex
+	claims   map[string]Claim
+	overlays map[string]overlay
+	seq      map[string]int // order of claims, to break priority ties
+	next     int
+	shown    *TowerState
+	timer    *time.Timer
+	closed   bool
+	now      func() time.Time
+}
+
+// NewArbiter creates an Arbiter for light
+func NewArbiter(light Light) *Arbiter {
+	return &Arbiter{
+		light:    light,
+		claims:   make(map[string]Claim),
+		overlays: make(map[string]overlay),
+		seq:      make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+// Claim posts or replaces the claim of source. A ttl of zero never expires
+func (a *Arbiter) Claim(source string, priority Priority, state State, ttl time.Duration) error {
+	if state == nil {
+		return fmt.Errorf("claim %s has no state", source)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	claim := Claim{Source: source, Priority: priority, State: state}
+	if ttl > 0 {
+		claim.Expires = a.now().Add(ttl)
+	}
+	if existing, ok := a.claims[source]; !ok || existing.Priority != priority {
+		a.next++
+		a.seq[source] = a.next
+	}
+	a.claims[source] = claim
+	return a.updateLocked()
+}
+
+// Release withdraws the claim of source
+func (a *Arbiter) Release(source string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.claims[source]; !ok {
+		return nil
+	}
+	delete(a.claims, source)
+	delete(a.seq, source)
+	return a.updateLocked()
+}
+
+// Overlay lights the lit lamps of lamps on top of any claim of at most
+// priority, e.g. a blinking yellow lamp next to the incident state
+func (a *Arbiter) Overlay(source string, priority Priority, lamps TowerState) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.overlays[source] = overlay{priority: priority, lamps: lamps}
+	return a.updateLocked()
+}
+
+// ClearOverlay withdraws the overlay of source
+func (a *Arbiter) ClearOverlay(source string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.overlays[source]; !ok {
+		return nil
+	}
+	delete(a.overlays, source)
+	return a.updateLocked()
+}
+
+// Shown returns the lamps shown: the winning claim with the overlays
+// applied
+func (a *Arbiter) Shown() TowerState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.lampsLocked(a.activeLocked())
+}
+
+// Active returns the claim being shown
+func (a *Arbiter) Active() (Claim, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	claims := a.activeLocked()
+	if len(claims) == 0 {
+		return Claim{}, false
+	}
+	return claims[0], true
+}
+
+// Claims returns the active claims, highest priority first
+func (a *Arbiter) Claims() []Claim {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.activeLocked()
+}
+
+// Close stops expiring claims. The light is left as it is
+func (a *Arbiter) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+}
+
+// activeLocked drops expired claims and sorts the rest by priority
+func (a *Arbiter) activeLocked() []Claim {
+	now := a.now()
+	claims := make([]Claim, 0, len(a.claims))
+	for source, claim := range a.claims {
+		if !claim.Expires.IsZero() && !now.Before(claim.Expires) {
+			delete(a.claims, source)
+			delete(a.seq, source)
+			continue
+		}
+		claims = append(claims, claim)
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].Priority != claims[j].Priority {
+			return claims[i].Priority > claims[j].Priority
+		}
+		return a.seq[claims[i].Source] > a.seq[claims[j].Source]
+	})
+	return claims
+}
+
+// updateLocked shows the winning claim when it changed and schedules the
+// next expiry
+func (a *Arbiter) updateLocked() error {
+	claims := a.activeLocked()
+	a.scheduleLocked(claims)
+
+	lamps := a.lampsLocked(claims)
+	if a.shown != nil && *a.shown == lamps {
+		return nil
+	}
+	if err := lamps.Apply(a.light); err != nil {
+		a.shown = nil
+		return err
+	}
+	a.shown = &lamps
+	return nil
+}
+
+// lampsLocked returns the lamps of the winning claim with the overlays
+// that are not outranked by it
+func (a *Arbiter) lampsLocked(claims []Claim) TowerState {
+	var lamps TowerState
+	var priority Priority
+	if len(claims) > 0 {
+		lamps = claims[0].Lamps()
+		priority = claims[0].Priority
+	}
+
+	sources := make([]string, 0, len(a.overlays))
+	for source := range a.overlays {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		overlay := a.overlays[source]
+		if overlay.priority < priority {
+			continue
+		}
+		for _, lamp := range Lamps {
+			if mode := overlay.lamps.Mode(lamp); mode != ModeOff {
+				lamps = lamps.With(lamp, mode)
+			}
+		}
+	}
+	return lamps
+}
+
+func (a *Arbiter) scheduleLocked(claims []Claim) {
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+	if a.closed {
+		return
+	}
+	var next time.Time
+	for _, claim := range claims {
+		if !claim.Expires.IsZero() && (next.IsZero() || claim.Expires.Before(next)) {
+			next = claim.Expires
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	a.timer = time.AfterFunc(next.Sub(a.now()), func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if !a.closed {
+			a.updateLocked()
+		}
+	})
+}
+
+// lampsOf returns the lamps a state shows when applied to a dark light
+func lampsOf(state State) TowerState {
+	if lamps, ok := state.(TowerState); ok {
+		return lamps
+	}
+	capture := &captureLight{}
+	state.Apply(capture)
+	return capture.lamps
+}
+
+// captureLight records the lamps a state sets
+type captureLight struct {
+	lamps TowerState
+}
+
+func (l *captureLight) On(cmd interface{}) error {
+	switch state := cmd.(type) {
+	case StandardState:
+		l.lamps = TowerStateFor(state, ModeOn)
+	case TowerState:
+		l.lamps = state
+	}
+	return nil
+}
+
+func (l *captureLight) Blink(cmd interface{}) error {
+	if state, ok := cmd.(StandardState); ok {
+		if lamp, err := lampForState(state); err == nil {
+			l.lamps = l.lamps.With(lamp, ModeBlink)
+		}
+	}
+	return nil
+}
+
+func (l *captureLight) Clear() error {
+	l.lamps = TowerState{}
+	return nil
+}
+
+func (l *captureLight) SetLamp(lamp Lamp, mode LampMode) error {
+	l.lamps = l.lamps.With(lamp, mode)
+	return nil
+}
@@ -0,0 +1,78 @@
+package lights
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArbiterPriority(t *testing.T) {
+	light := &recordingLight{}
+	a := NewArbiter(light)
+	defer a.Close()
+
+	a.Claim("incidents", PriorityIncident, RedState{}, 0)
+	a.Claim("maintenance", PriorityMaintenance, GreenState{}, 0)
+	// Lower priority updates don't reach the light while outranked
+	a.Claim("incidents", PriorityIncident, YellowState{}, 0)
+	a.Claim("self-test", PrioritySelfTest, BlinkingYellowState{}, 0)
+	a.Release("self-test")
+	a.Release("maintenance")
+	a.Release("incidents")
+
+	want := []string{"on:red", "on:green", "blink:yellow", "on:green", "on:yellow", "clear"}
+	if !reflect.DeepEqual(light.calls, want) {
+		t.Errorf("calls = %v, want %v", light.calls, want)
+	}
+}
+
+func TestArbiterTiesAndExpiry(t *testing.T) {
+	light := &recordingLight{}
+	a := NewArbiter(light)
+	defer a.Close()
+
+	a.Claim("incidents", PriorityIncident, GreenState{}, 0)
+	a.Claim("override-a", PriorityOverride, RedState{}, 0)
+	a.Claim("override-b", PriorityOverride, YellowState{}, 30*time.Millisecond)
+	if active, _ := a.Active(); active.Source != "override-b" {
+		t.Errorf("Active() = %s, want the latest claim of equal priority", active.Source)
+	}
+	a.Release("override-a")
+
+	time.Sleep(80 * time.Millisecond)
+	active, ok := a.Active()
+	if !ok || active.Source != "incidents" {
+		t.Errorf("Active() = %+v after expiry, want incidents", active)
+	}
+	want := []string{"on:green", "on:red", "on:yellow", "on:green"}
+	if !reflect.DeepEqual(light.calls, want) {
+		t.Errorf("calls = %v, want %v", light.calls, want)
+	}
+	if claims := a.Claims(); len(claims) != 1 {
+		t.Errorf("Claims() = %+v, want only the incident claim", claims)
+	}
+}
+
+func TestArbiterOverlay(t *testing.T) {
+	light := &recordingLight{}
+	a := NewArbiter(light)
+	defer a.Close()
+
+	a.Claim("incidents", PriorityIncident, TowerState{Red: ModeOn}, 0)
+	a.Overlay("heartbeat", PriorityConnectivity, TowerState{Yellow: ModeBlink})
+	if shown := a.Shown(); shown != (TowerState{Red: ModeOn, Yellow: ModeBlink}) {
+		t.Errorf("Shown() = %s, want the overlay next to the incident", shown)
+	}
+
+	// Outranking claims hide the overlay
+	a.Claim("override", PriorityOverride, TowerState{Green: ModeOn}, 0)
+	if shown := a.Shown(); shown != (TowerState{Green: ModeOn}) {
+		t.Errorf("Shown() = %s, want the override alone", shown)
+	}
+	a.Release("override")
+
+	a.ClearOverlay("heartbeat")
+	if shown := a.Shown(); shown != (TowerState{Red: ModeOn}) {
+		t.Errorf("Shown() = %s after clearing, want the incident", shown)
+	}
+}
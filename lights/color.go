@@ -0,0 +1,79 @@
+package lights
+
+import "fmt"
+
+// RGB is a 24-bit color as used by LED strips and smart bulbs
+type RGB struct {
+	R, G, B uint8
+}
+
+// Colors shown by lights that can display any color
+var stateColors = map[StandardState]RGB{
+	StateRed:    {R: 255, G: 0, B: 0},
+	StateYellow: {R: 255, G: 160, B: 0},
+	StateGreen:  {R: 0, G: 255, B: 0},
+	StateOff:    {R: 0, G: 0, B: 0},
+}
+
+// ColorFor returns the RGB color for a standard state
+func ColorFor(state StandardState) (RGB, error) {
+	color, ok := stateColors[state]
+	if !ok {
+		return RGB{}, fmt.Errorf("unsupported state: %s", state)
+	}
+	return color, nil
+}
+
+// Scale returns the color dimmed to the given fraction of its brightness
+func (c RGB) Scale(fraction float64) RGB {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	return RGB{
+		R: uint8(float64(c.R) * fraction),
+		G: uint8(float64(c.G) * fraction),
+		B: uint8(float64(c.B) * fraction),
+	}
+}
+
+// Hex renders the color as e.g. "FF0000"
+func (c RGB) Hex() string {
+	return fmt.Sprintf("%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// HueSat converts the color to the hue (0-65535) and saturation (0-254)
+// ranges used by Philips Hue
+func (c RGB) HueSat() (uint16, uint8) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	max, min := r, r
+	for _, v := range []float64{g, b} {
+		if v > max {
+			max = v
+		}
+		if v < min {
+			min = v
+		}
+	}
+	if max == 0 {
+		return 0, 0
+	}
+
+	delta := max - min
+	var hue float64
+	switch {
+	case delta == 0:
+		hue = 0
+	case max == r:
+		hue = (g - b) / delta
+		if hue < 0 {
+			hue += 6
+		}
+	case max == g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	return uint16(hue / 6 * 65535), uint8(delta / max * 254)
+}
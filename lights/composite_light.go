@@ -0,0 +1,198 @@
+package lights
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"my-incident-checker/types"
+)
+
+// Member is one device driven by a CompositeLight
+type Member struct {
+	Name  string
+	Light Light
+	// Close releases the device, may be nil
+	Close func() error
+}
+
+// DeviceHealth reports how the last updates of one device went
+type DeviceHealth struct {
+	Name      string
+	Healthy   bool
+	Failures  int // consecutive failed updates
+	LastError error
+}
+
+// CompositeLight drives several devices at once. Every update is sent to
+// every device, so one failing device doesn't stop the others, and health
+// changes of each device are logged
+type CompositeLight struct {
+	logger  *types.Logger
+	members []*member
+
+	mu    sync.Mutex
+	lamps TowerState
+}
+
+type member struct {
+	Member
+	health DeviceHealth
+	// shown is what a single-color device last displayed successfully,
+	// so it isn't resent for changes to lamps it can't show
+	shown *TowerState
+}
+
+// NewCompositeLight creates a CompositeLight over the given devices
+func NewCompositeLight(logger *types.Logger, members ...Member) *CompositeLight {
+	c := &CompositeLight{logger: logger}
+	for _, m := range members {
+		c.members = append(c.members, &member{
+			Member: m,
+			health: DeviceHealth{Name: m.Name, Healthy: true},
+		})
+	}
+	return c
+}
+
+// On shows a steady state on every device
+func (c *CompositeLight) On(cmd interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, ok := cmd.(StandardState); ok {
+		c.lamps = TowerStateFor(state, ModeOn)
+	}
+	return c.each(func(m *member) error {
+		m.shown = nil
+		return m.Light.On(cmd)
+	})
+}
+
+// Blink shows a blinking state on every device
+func (c *CompositeLight) Blink(cmd interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state, ok := cmd.(StandardState); ok {
+		c.lamps = TowerStateFor(state, ModeBlink)
+	}
+	return c.each(func(m *member) error {
+		m.shown = nil
+		return m.Light.Blink(cmd)
+	})
+}
+
+// Clear turns every device off
+func (c *CompositeLight) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lamps = TowerState{}
+	return c.each(func(m *member) error {
+		m.shown = nil
+		return m.Light.Clear()
+	})
+}
+
+// SetLamp sets a lamp on tower devices. Single-color devices show the most
+// severe lit lamp and are only updated when that changes
+func (c *CompositeLight) SetLamp(lamp Lamp, mode LampMode) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lamps = c.lamps.With(lamp, mode)
+	lamps := c.lamps
+
+	return c.each(func(m *member) error {
+		if tower, ok := m.Light.(TowerLight); ok {
+			return tower.SetLamp(lamp, mode)
+		}
+
+		color, primary := lamps.Primary()
+		view := TowerStateFor(color, primary)
+		if m.shown != nil && *m.shown == view {
+			return nil
+		}
+		if err := view.Apply(m.Light); err != nil {
+			m.shown = nil
+			return err
+		}
+		m.shown = &view
+		return nil
+	})
+}
+
+// Health returns the health of every device
+func (c *CompositeLight) Health() []DeviceHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	health := make([]DeviceHealth, len(c.members))
+	for i, m := range c.members {
+		health[i] = m.health
+	}
+	return health
+}
+
+// Close releases every device
+func (c *CompositeLight) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var failed []string
+	for _, m := range c.members {
+		if m.Close == nil {
+			continue
+		}
+		if err := m.Close(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", m.Name, err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to close lights: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// each applies fn to every device concurrently, so that a slow device
+// doesn't delay the others, and records their health. It only fails when
+// every device failed, since a partly working display is still useful
+func (c *CompositeLight) each(fn func(m *member) error) error {
+	errs := make([]error, len(c.members))
+	var wg sync.WaitGroup
+	for i, m := range c.members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			errs[i] = fn(m)
+		}(i, m)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, m := range c.members {
+		err := errs[i]
+		c.record(m, err)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", m.Name, err.Error()))
+		}
+	}
+	if len(c.members) > 0 && len(failed) == len(c.members) {
+		return fmt.Errorf("all lights failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (c *CompositeLight) record(m *member, err error) {
+	if err == nil {
+		if !m.health.Healthy {
+			c.logger.InfoLog.Printf("Light %s recovered after %d failed updates", m.Name, m.health.Failures)
+		}
+		m.health = DeviceHealth{Name: m.Name, Healthy: true}
+		return
+	}
+
+	m.health.Failures++
+	m.health.LastError = err
+	if m.health.Healthy {
+		c.logger.ErrorLog.Printf("Light %s failed, other lights continue: %s", m.Name, err.Error())
+		m.health.Healthy = false
+	} else {
+		c.logger.DebugLog.Printf("Light %s still failing (%d updates): %s", m.Name, m.health.Failures, err.Error())
+	}
+}
@@ -0,0 +1,152 @@
+package lights
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+// failingLight fails every call while broken is set
+type failingLight struct {
+	recordingLight
+	broken bool
+}
+
+func (l *failingLight) On(cmd interface{}) error {
+	if l.broken {
+		return errors.New("device unplugged")
+	}
+	return l.recordingLight.On(cmd)
+}
+
+func (l *failingLight) Blink(cmd interface{}) error {
+	if l.broken {
+		return errors.New("device unplugged")
+	}
+	return l.recordingLight.Blink(cmd)
+}
+
+func (l *failingLight) Clear() error {
+	if l.broken {
+		return errors.New("device unplugged")
+	}
+	return l.recordingLight.Clear()
+}
+
+func TestCompositeLightIsolatesFailures(t *testing.T) {
+	var logs bytes.Buffer
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(&logs, "INFO: ", 0),
+		WarnLog:  log.New(&logs, "WARN: ", 0),
+		ErrorLog: log.New(&logs, "ERROR: ", 0),
+	}
+
+	tower := &recordingTower{}
+	desk := &failingLight{broken: true}
+	c := NewCompositeLight(logger,
+		Member{Name: "tower", Light: tower},
+		Member{Name: "desk", Light: desk},
+	)
+
+	if err := (TowerState{Red: ModeOn, Yellow: ModeBlink}).Apply(c); err != nil {
+		t.Fatalf("Apply() error = %v, want nil while one light works", err)
+	}
+	if want := []string{"red=on", "yellow=blink", "green=off", "buzzer=off"}; !reflect.DeepEqual(tower.calls, want) {
+		t.Errorf("tower calls = %v, want %v", tower.calls, want)
+	}
+	if health := c.Health(); health[1].Healthy || health[1].Failures != 4 {
+		t.Errorf("desk health = %+v, want 4 consecutive failures", health[1])
+	}
+	if strings.Count(logs.String(), "ERROR: Light desk failed") != 1 {
+		t.Errorf("expected one failure log, got:\n%s", logs.String())
+	}
+
+	// Once the device is back it gets the most severe lamp, once
+	desk.broken = false
+	c.SetLamp(LampGreen, ModeOn)
+	c.SetLamp(LampBuzzer, ModeOff)
+	if want := []string{"on:red"}; !reflect.DeepEqual(desk.calls, want) {
+		t.Errorf("desk calls = %v, want %v", desk.calls, want)
+	}
+	if !strings.Contains(logs.String(), "INFO: Light desk recovered after 4 failed updates") {
+		t.Errorf("expected recovery log, got:\n%s", logs.String())
+	}
+
+	tower.calls, desk.calls = nil, nil
+	desk.broken = true
+	failingTower := &failingLight{broken: true}
+	c = NewCompositeLight(logger, Member{Name: "a", Light: desk}, Member{Name: "b", Light: failingTower})
+	if err := c.On(StateGreen); err == nil {
+		t.Error("On() expected error when every light fails")
+	}
+}
+
+// gateLight reports each update on called and holds it until released
+type gateLight struct {
+	recordingLight
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (l *gateLight) On(cmd interface{}) error {
+	l.called <- struct{}{}
+	<-l.release
+	return l.recordingLight.On(cmd)
+}
+
+func TestCompositeLightWritesConcurrently(t *testing.T) {
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+		WarnLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	called, release := make(chan struct{}), make(chan struct{})
+	c := NewCompositeLight(logger,
+		Member{Name: "a", Light: &gateLight{called: called, release: release}},
+		Member{Name: "b", Light: &gateLight{called: called, release: release}},
+	)
+
+	done := make(chan error)
+	go func() { done <- c.On(StateGreen) }()
+	// Both devices are written while neither has finished
+	for i := 0; i < 2; i++ {
+		select {
+		case <-called:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("device %d was not written while the other was busy", i+1)
+		}
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("On() error = %v", err)
+	}
+}
+
+func TestCompositeLightBlinkReplacesState(t *testing.T) {
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+		WarnLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	desk := &recordingLight{}
+	c := NewCompositeLight(logger, Member{Name: "desk", Light: desk})
+
+	// Like On, Blink replaces the state, so the red lamp of On no longer
+	// outranks green when a single lamp changes afterwards
+	c.On(StateRed)
+	c.Blink(StateGreen)
+	c.SetLamp(LampBuzzer, ModeOn)
+	if want := []string{"on:red", "blink:green", "blink:green"}; !reflect.DeepEqual(desk.calls, want) {
+		t.Errorf("desk calls = %v, want %v", desk.calls, want)
+	}
+}
@@ -0,0 +1,225 @@
+package lights
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCoalesceWindow is how long the controller waits for further
+	// changes before writing to the device
+	DefaultCoalesceWindow = 250 * time.Millisecond
+	// DefaultResyncInterval is how often the full state is rewritten in case
+	// the device was power-cycled and lost it
+	DefaultResyncInterval = time.Minute
+	// retryInterval is how soon a failed write is retried, giving drivers
+	// that reconnect a chance to recover before the next resync
+	retryInterval = 2 * time.Second
+)
+
+// Controller sits in front of a Light and makes updates idempotent. It
+// tracks the device state it last applied, writes only the lamps that
+// changed, coalesces rapid changes into one write and periodically resyncs
+// the whole state to the hardware.
+type Controller struct {
+	light    Light
+	coalesce time.Duration
+	resync   time.Duration
+	// after times the coalesce window, resyncs and retries, replaced in
+	// tests
+	after func(time.Duration) <-chan time.Time
+
+	mu      sync.Mutex
+	desired TowerState
+	applied TowerState
+	synced  bool // applied is known to match the device
+	lastErr error
+
+	kick      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewController creates a Controller for light and starts its update loop
+func NewController(light Light, coalesce, resync time.Duration) *Controller {
+	return newController(light, coalesce, resync, time.After)
+}
+
+func newController(light Light, coalesce, resync time.Duration, after func(time.Duration) <-chan time.Time) *Controller {
+	c := &Controller{
+		light:    light,
+		coalesce: coalesce,
+		resync:   resync,
+		after:    after,
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// On requests a steady color or a full TowerState
+func (c *Controller) On(cmd interface{}) error {
+	switch state := cmd.(type) {
+	case StandardState:
+		return c.Set(TowerStateFor(state, ModeOn))
+	case TowerState:
+		return c.Set(state)
+	}
+	return fmt.Errorf("invalid command type for Controller")
+}
+
+// Blink requests a blinking color
+func (c *Controller) Blink(cmd interface{}) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for Controller")
+	}
+	return c.Set(TowerStateFor(state, ModeBlink))
+}
+
+// Clear requests all lamps and the buzzer off
+func (c *Controller) Clear() error {
+	return c.Set(TowerState{})
+}
+
+// SetLamp requests a single lamp change, leaving the others as they are
+func (c *Controller) SetLamp(lamp Lamp, mode LampMode) error {
+	c.mu.Lock()
+	state := c.desired.With(lamp, mode)
+	c.mu.Unlock()
+	return c.Set(state)
+}
+
+// Set records the desired state and schedules a write. It returns the
+// error of the most recent device write, if any
+func (c *Controller) Set(state TowerState) error {
+	c.mu.Lock()
+	c.desired = state
+	err := c.lastErr
+	c.mu.Unlock()
+
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// State returns the state most recently requested
+func (c *Controller) State() TowerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.desired
+}
+
+// Err returns the error of the most recent device write, nil once a write
+// succeeded again
+func (c *Controller) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// Close writes any pending change and stops the update loop. Closing
+// again only returns the error of the last write
+func (c *Controller) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		<-c.done
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+func (c *Controller) run() {
+	defer close(c.done)
+
+	resync := c.after(c.resync)
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-c.kick:
+			// Wait out the coalesce window so a burst of changes becomes
+			// a single write of the final state
+			select {
+			case <-c.after(c.coalesce):
+			case <-c.stop:
+				c.flush(false)
+				return
+			}
+			retry = c.flushWithRetry(false)
+		case <-retry:
+			retry = c.flushWithRetry(true)
+		case <-resync:
+			retry = c.flushWithRetry(true)
+			resync = c.after(c.resync)
+		case <-c.stop:
+			c.flush(false)
+			return
+		}
+	}
+}
+
+// flushWithRetry flushes and returns a timer channel for the next attempt
+// if the write failed, or nil if it succeeded
+func (c *Controller) flushWithRetry(full bool) <-chan time.Time {
+	if err := c.flush(full); err != nil {
+		return c.after(retryInterval)
+	}
+	return nil
+}
+
+// flush writes the desired state to the device. Only changed lamps are
+// written unless full is set or the device state is unknown
+func (c *Controller) flush(full bool) error {
+	c.mu.Lock()
+	desired := c.desired
+	applied := c.applied
+	if !c.synced {
+		full = true
+	}
+	c.mu.Unlock()
+
+	applied, err := c.write(desired, applied, full)
+
+	c.mu.Lock()
+	c.applied = applied
+	c.synced = err == nil
+	c.lastErr = err
+	c.mu.Unlock()
+	return err
+}
+
+// write updates the device lamp by lamp, or with the whole state at once
+// for lights that aren't tower lights or that implement StateLight
+func (c *Controller) write(desired, applied TowerState, full bool) (TowerState, error) {
+	_, whole := c.light.(StateLight)
+	tower, ok := c.light.(TowerLight)
+	if !ok || whole {
+		if !full && desired == applied {
+			return applied, nil
+		}
+		if err := desired.Apply(c.light); err != nil {
+			return applied, err
+		}
+		return desired, nil
+	}
+
+	for _, lamp := range Lamps {
+		mode := desired.Mode(lamp)
+		if !full && applied.Mode(lamp) == mode {
+			continue
+		}
+		if err := tower.SetLamp(lamp, mode); err != nil {
+			return applied, fmt.Errorf("failed to set %s lamp: %w", lamp, err)
+		}
+		applied = applied.With(lamp, mode)
+	}
+	return applied, nil
+}
@@ -0,0 +1,116 @@
+package lights
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Controller timings in tests, distinct so that each has its own timer
+const (
+	testCoalesce = time.Millisecond
+	testResync   = time.Hour
+)
+
+// timerClock times a Controller with one timer per duration, each firing
+// only when the test says so. Arming a timer is reported on its armed
+// channel, which shows the update loop finished the writes before it
+type timerClock struct {
+	armed  map[time.Duration]chan struct{}
+	timers map[time.Duration]chan time.Time
+}
+
+func newTimerClock(durations ...time.Duration) *timerClock {
+	c := &timerClock{
+		armed:  make(map[time.Duration]chan struct{}),
+		timers: make(map[time.Duration]chan time.Time),
+	}
+	for _, d := range durations {
+		c.armed[d] = make(chan struct{}, 100)
+		c.timers[d] = make(chan time.Time)
+	}
+	return c
+}
+
+func (c *timerClock) after(d time.Duration) <-chan time.Time {
+	c.armed[d] <- struct{}{}
+	return c.timers[d]
+}
+
+// waitArmed returns once the timer of d was armed again
+func (c *timerClock) waitArmed(t *testing.T, d time.Duration) {
+	t.Helper()
+	select {
+	case <-c.armed[d]:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timer of %s was not armed", d)
+	}
+}
+
+// fire waits for the timer of d to be armed and fires it
+func (c *timerClock) fire(t *testing.T, d time.Duration) {
+	t.Helper()
+	c.waitArmed(t, d)
+	select {
+	case c.timers[d] <- time.Time{}:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("controller did not wait for the timer of %s", d)
+	}
+}
+
+func TestControllerWritesOnlyDeltas(t *testing.T) {
+	tower := &recordingTower{}
+	clock := newTimerClock(testCoalesce, testResync, retryInterval)
+	c := newController(tower, testCoalesce, testResync, clock.after)
+
+	// The first write is always a full sync
+	c.On(StateGreen)
+	clock.fire(t, testCoalesce)
+
+	// Re-applying the same state writes nothing
+	GreenState{}.Apply(c)
+	GreenState{}.Apply(c)
+	clock.fire(t, testCoalesce)
+
+	// A burst of changes while the coalesce window is open is written as
+	// the final state
+	GreenState{}.Apply(c)
+	clock.waitArmed(t, testCoalesce)
+	c.On(StateYellow)
+	c.Blink(StateRed)
+	c.SetLamp(LampYellow, ModeBlink)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+
+	want := []string{
+		"red=off", "yellow=off", "green=on", "buzzer=off",
+		"red=blink", "yellow=blink", "green=off",
+	}
+	if !reflect.DeepEqual(tower.calls, want) {
+		t.Errorf("calls = %v, want %v", tower.calls, want)
+	}
+}
+
+func TestControllerResync(t *testing.T) {
+	tower := &recordingTower{}
+	clock := newTimerClock(testCoalesce, testResync, retryInterval)
+	c := newController(tower, testCoalesce, testResync, clock.after)
+	c.On(StateRed)
+	clock.fire(t, testCoalesce)
+	clock.fire(t, testResync)
+	clock.fire(t, testResync)
+	// Once the resync timer is armed again, the last resync completed
+	clock.waitArmed(t, testResync)
+	c.Close()
+
+	// Every resync rewrites all four lamps
+	full := []string{"red=on", "yellow=off", "green=off", "buzzer=off"}
+	want := append(append(append([]string(nil), full...), full...), full...)
+	if !reflect.DeepEqual(tower.calls, want) {
+		t.Errorf("calls = %v, want three full writes %v", tower.calls, want)
+	}
+}
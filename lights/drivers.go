@@ -0,0 +1,339 @@
+package lights
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"my-incident-checker/internal/gpio"
+)
+
+// Drivers probed by the auto driver, in this order. GPIO lines are only
+// probed when pins are configured, since any board has a gpiochip. The
+// terminal comes last so development machines without hardware still run
+const (
+	autoOrderGPIO = iota + 1
+	autoOrderBlink1
+	autoOrderSerial
+	autoOrderTerminal
+)
+
+func init() {
+	RegisterDriver(Driver{
+		Name:        "serial",
+		Description: "USB serial tower light",
+		Options: []DriverOption{
+			{Name: "port", Description: "serial port, discovered over USB when unset"},
+			{Name: "baud", Description: "baud rate, from the profile when unset"},
+			{Name: "profile", Description: "built-in profile name or path of a JSON profile"},
+			{Name: "vendor_id", Description: "USB vendor ID to discover, the stock tower light's 1a86 when both IDs are unset"},
+			{Name: "product_id", Description: "USB product ID to discover, 7523 when both IDs are unset"},
+		},
+		AutoOrder: autoOrderSerial,
+		Probe:     probeSerial,
+		Open:      openSerialDriver,
+	})
+	RegisterDriver(Driver{
+		Name:        "gpio",
+		Description: "lamps or relays on GPIO lines, blinking in software",
+		Options: []DriverOption{
+			{Name: "pins", Description: "lamp lines, e.g. red=17,yellow=27,green=22", Required: true},
+			{Name: "chip", Description: "GPIO chip, gpiochip0 when unset"},
+			{Name: "active_low", Description: "true for relay boards that switch on a low level"},
+		},
+		AutoOrder: autoOrderGPIO,
+		Probe:     probeGPIO,
+		Open:      openGPIO,
+	})
+	RegisterDriver(Driver{
+		Name:        "blink1",
+		Description: "blink(1) mk3 USB light, blinking in software",
+		AutoOrder:   autoOrderBlink1,
+		Probe: func(map[string]string) (string, error) {
+			return findBlink1Device()
+		},
+		Open: func(map[string]string) (Light, func() error, error) {
+			blink1Light, err := NewBlink1Light()
+			if err != nil {
+				return nil, nil, err
+			}
+			animator := NewAnimator(blink1Light, DefaultBlinkPeriod)
+			return animator, func() error {
+				animator.Close()
+				blink1Light.Close()
+				return nil
+			}, nil
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "wled",
+		Description: "WLED controller over the JSON API",
+		Options: []DriverOption{
+			{Name: "url", Description: "controller URL, e.g. http://wled.local", Required: true},
+			{Name: "segment", Description: "segment to drive, the whole strip when unset"},
+			{Name: "pixels", Description: "pixel range of the segment as start-stop, stop exclusive"},
+		},
+		Open: func(options map[string]string) (Light, func() error, error) {
+			segment, err := optionalInt(options, "segment", -1)
+			if err != nil {
+				return nil, nil, err
+			}
+			start, stop, err := pixelRange(options["pixels"])
+			if err != nil {
+				return nil, nil, err
+			}
+			light, err := NewWLEDLight(WLEDConfig{
+				URL:     options["url"],
+				Segment: segment,
+				Start:   start,
+				Stop:    stop,
+			})
+			return light, nil, err
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "hue",
+		Description: "Philips Hue light or group",
+		Options: []DriverOption{
+			{Name: "bridge_url", Description: "bridge URL", Required: true},
+			{Name: "username", Description: "bridge API username", Required: true},
+			{Name: "light", Description: "light ID"},
+			{Name: "group", Description: "group ID, instead of a light"},
+		},
+		Open: func(options map[string]string) (Light, func() error, error) {
+			light, err := NewHueLight(HueConfig{
+				BridgeURL: options["bridge_url"],
+				Username:  options["username"],
+				LightID:   options["light"],
+				Group:     options["group"],
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			return light, light.Close, nil
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "homeassistant",
+		Description: "Home Assistant light entity",
+		Options: []DriverOption{
+			{Name: "url", Description: "Home Assistant URL", Required: true},
+			{Name: "token", Description: "long-lived access token", Required: true},
+			{Name: "entity_id", Description: "light entity, e.g. light.office", Required: true},
+		},
+		Open: func(options map[string]string) (Light, func() error, error) {
+			light, err := NewHomeAssistantLight(HomeAssistantConfig{
+				URL:      options["url"],
+				Token:    options["token"],
+				EntityID: options["entity_id"],
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			return light, light.Close, nil
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "terminal",
+		Description: "tower drawn in the terminal with ANSI colors",
+		AutoOrder:   autoOrderTerminal,
+		Probe: func(map[string]string) (string, error) {
+			info, err := os.Stdout.Stat()
+			if err != nil {
+				return "", err
+			}
+			if info.Mode()&os.ModeCharDevice == 0 {
+				return "", fmt.Errorf("stdout is not a terminal")
+			}
+			return "stdout", nil
+		},
+		Open: func(map[string]string) (Light, func() error, error) {
+			light := NewTerminalLight(os.Stdout, DefaultBlinkPeriod)
+			return light, light.Close, nil
+		},
+	})
+	RegisterDriver(Driver{
+		Name:        "recorder",
+		Description: "timestamped log of light states, for running headless",
+		Options: []DriverOption{
+			{Name: "path", Description: "file the states are appended to, stdout when unset or -"},
+		},
+		Open: func(options map[string]string) (Light, func() error, error) {
+			path := options["path"]
+			if path == "" || path == "-" {
+				return NewRecorderLight(os.Stdout), nil, nil
+			}
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open state log: %w", err)
+			}
+			return NewRecorderLight(f), f.Close, nil
+		},
+	})
+}
+
+// serialProfile loads the profile option and applies the baud option
+func serialProfile(options map[string]string) (Profile, error) {
+	profile := DefaultProfile()
+	if name := options["profile"]; name != "" {
+		loaded, err := LoadProfile(name)
+		if err != nil {
+			return Profile{}, err
+		}
+		profile = loaded
+	}
+
+	if value := options["baud"]; value != "" {
+		baud, err := strconv.Atoi(value)
+		if err != nil {
+			return Profile{}, fmt.Errorf("invalid baud rate %q: %w", value, err)
+		}
+		profile.Baud = baud
+	}
+	return profile, nil
+}
+
+// openSerialDriver opens a serial light. Devices whose profile has no
+// blink commands, such as relay boards, blink in software
+func openSerialDriver(options map[string]string) (Light, func() error, error) {
+	profile, err := serialProfile(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	light, err := openSerial(options, profile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if profile.CanBlink() {
+		return light, light.Close, nil
+	}
+	animator := NewAnimator(light, DefaultBlinkPeriod)
+	return animator, func() error {
+		animator.Close()
+		return light.Close()
+	}, nil
+}
+
+// openSerial opens the tower light on the configured port when set,
+// otherwise on the first USB serial port matching vendor_id and product_id
+// (the stock tower light when those are unset)
+func openSerial(options map[string]string, profile Profile) (*SerialLight, error) {
+	if port := options["port"]; port != "" {
+		return NewSerialLightWithProfile(port, profile)
+	}
+	return DiscoverSerialLight(serialMatch(options), profile)
+}
+
+func probeSerial(options map[string]string) (string, error) {
+	if port := options["port"]; port != "" {
+		if _, err := os.Stat(port); err != nil {
+			return "", err
+		}
+		return port, nil
+	}
+	return FindSerialPort(serialMatch(options))
+}
+
+// serialMatch returns the USB IDs to discover the light by, defaulting to
+// the stock tower light rather than any serial adapter
+func serialMatch(options map[string]string) SerialMatch {
+	match := SerialMatch{
+		VendorID:  options["vendor_id"],
+		ProductID: options["product_id"],
+	}
+	if match.Empty() {
+		return DefaultSerialMatch
+	}
+	return match
+}
+
+// gpioConfig parses the GPIO options
+func gpioConfig(options map[string]string) (GPIOConfig, error) {
+	pins, err := ParseLampPins(options["pins"])
+	if err != nil {
+		return GPIOConfig{}, err
+	}
+	activeLow := false
+	if value := options["active_low"]; value != "" {
+		activeLow, err = strconv.ParseBool(value)
+		if err != nil {
+			return GPIOConfig{}, fmt.Errorf("invalid active_low %q: %w", value, err)
+		}
+	}
+	return GPIOConfig{Chip: options["chip"], Pins: pins, ActiveLow: activeLow}, nil
+}
+
+// openGPIO opens lamps wired to GPIO lines. Plain GPIO lines can't blink on
+// their own, so blinking is done in software
+func openGPIO(options map[string]string) (Light, func() error, error) {
+	config, err := gpioConfig(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	gpioLight, err := NewGPIOLight(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	animator := NewAnimator(gpioLight, DefaultBlinkPeriod)
+	return animator, func() error {
+		animator.Close()
+		return gpioLight.Close()
+	}, nil
+}
+
+func probeGPIO(options map[string]string) (string, error) {
+	if options["pins"] == "" {
+		return "", fmt.Errorf("no pins configured")
+	}
+	config, err := gpioConfig(options)
+	if err != nil {
+		return "", err
+	}
+	chip := config.Chip
+	if chip == "" {
+		chip = "gpiochip0"
+	}
+	path := gpio.ChipPath(chip)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if _, err := gpio.SysfsChipBase(chip); err != nil {
+		return "", err
+	}
+	return gpio.SysfsRoot(), nil
+}
+
+// optionalInt parses an integer option, returning fallback when unset
+func optionalInt(options map[string]string, name string, fallback int) (int, error) {
+	value := options[name]
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	return n, nil
+}
+
+// pixelRange parses a pixel range written as "start-stop", stop being
+// exclusive. An empty range means the whole segment
+func pixelRange(value string) (int, int, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid pixel range %q, want start-stop", value)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid pixel range %q: %w", value, err)
+	}
+	stop, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid pixel range %q: %w", value, err)
+	}
+	return start, stop, nil
+}
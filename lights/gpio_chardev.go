@@ -0,0 +1,53 @@
+package lights
+
+import (
+	"fmt"
+
+	"my-incident-checker/internal/gpio"
+)
+
+// gpioChardev drives lines through a line handle on /dev/gpiochipN. All
+// lines are set together, so it keeps the current level of each
+type gpioChardev struct {
+	handle  *gpio.Handle
+	offsets []int
+	values  gpio.HandleData
+}
+
+// openGPIOChardev requests the lines of a chip as outputs
+func openGPIOChardev(chip string, offsets []int, initial bool) (*gpioChardev, error) {
+	g := &gpioChardev{offsets: offsets}
+	if initial {
+		for i := range offsets {
+			g.values.Values[i] = 1
+		}
+	}
+	handle, err := gpio.RequestLines(chip, offsets, gpio.HandleRequestOutput, g.values)
+	if err != nil {
+		return nil, err
+	}
+	g.handle = handle
+	return g, nil
+}
+
+func (g *gpioChardev) Set(offset int, high bool) error {
+	for i, o := range g.offsets {
+		if o != offset {
+			continue
+		}
+		var value uint8
+		if high {
+			value = 1
+		}
+		g.values.Values[i] = value
+		if err := g.handle.SetValues(g.values); err != nil {
+			return fmt.Errorf("failed to set GPIO line %d: %w", offset, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("GPIO line %d was not requested", offset)
+}
+
+func (g *gpioChardev) Close() error {
+	return g.handle.Close()
+}
@@ -0,0 +1,210 @@
+package lights
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"my-incident-checker/internal/gpio"
+)
+
+// GPIOConfig describes lamps wired directly to GPIO lines
+type GPIOConfig struct {
+	// Chip is the GPIO chip, e.g. "gpiochip0" or "/dev/gpiochip0"
+	Chip string
+	// Pins maps each wired lamp to its line offset on the chip
+	Pins map[Lamp]int
+	// ActiveLow drives a line low to switch its lamp on, as most relay
+	// boards expect
+	ActiveLow bool
+}
+
+// gpioOutputs drives a set of output lines
+type gpioOutputs interface {
+	Set(offset int, high bool) error
+	Close() error
+}
+
+// GPIOLight implements Light interface for LEDs and relays on GPIO lines.
+// It only switches lamps on and off; wrap it in an Animator to blink
+type GPIOLight struct {
+	config  GPIOConfig
+	outputs gpioOutputs
+}
+
+// NewGPIOLight requests the configured lines through the GPIO character
+// device, falling back to the legacy sysfs interface on kernels without it
+func NewGPIOLight(config GPIOConfig) (*GPIOLight, error) {
+	if len(config.Pins) == 0 {
+		return nil, fmt.Errorf("no GPIO pins configured")
+	}
+	if config.Chip == "" {
+		config.Chip = "gpiochip0"
+	}
+
+	offsets := make([]int, 0, len(config.Pins))
+	for _, lamp := range Lamps {
+		if offset, ok := config.Pins[lamp]; ok {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	// Start with every lamp off
+	initial := config.ActiveLow
+
+	var outputs gpioOutputs
+	chardev, err := openGPIOChardev(config.Chip, offsets, initial)
+	if err == nil {
+		outputs = chardev
+	} else {
+		sysfs, sysfsErr := openGPIOSysfs(config.Chip, offsets, initial)
+		if sysfsErr != nil {
+			return nil, fmt.Errorf("failed to open GPIO lines: %s; sysfs fallback: %w", err.Error(), sysfsErr)
+		}
+		outputs = sysfs
+	}
+
+	return &GPIOLight{
+		config:  config,
+		outputs: outputs,
+	}, nil
+}
+
+// On turns on a single color, switching the other lamps off
+func (l *GPIOLight) On(cmd interface{}) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for GPIOLight")
+	}
+	lamp, err := lampForState(state)
+	if err != nil {
+		return err
+	}
+	if _, ok := l.config.Pins[lamp]; !ok {
+		return fmt.Errorf("no GPIO pin configured for the %s lamp", lamp)
+	}
+	return l.setAll(TowerState{}.With(lamp, ModeOn))
+}
+
+// Blink is not supported by plain GPIO lines
+func (l *GPIOLight) Blink(cmd interface{}) error {
+	return ErrBlinkUnsupported
+}
+
+// Clear switches every lamp off
+func (l *GPIOLight) Clear() error {
+	return l.setAll(TowerState{})
+}
+
+// SetLamp switches a single lamp. Turning off a lamp that isn't wired is
+// a no-op
+func (l *GPIOLight) SetLamp(lamp Lamp, mode LampMode) error {
+	offset, ok := l.config.Pins[lamp]
+	if !ok {
+		if mode == ModeOff {
+			return nil
+		}
+		return fmt.Errorf("no GPIO pin configured for the %s lamp", lamp)
+	}
+	if mode == ModeBlink {
+		return ErrBlinkUnsupported
+	}
+	return l.outputs.Set(offset, l.level(mode == ModeOn))
+}
+
+// Close switches every lamp off and releases the lines
+func (l *GPIOLight) Close() error {
+	clearErr := l.Clear()
+	if err := l.outputs.Close(); err != nil {
+		return err
+	}
+	return clearErr
+}
+
+func (l *GPIOLight) setAll(state TowerState) error {
+	for _, lamp := range Lamps {
+		if err := l.SetLamp(lamp, state.Mode(lamp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// level returns the line level that switches a lamp on or off
+func (l *GPIOLight) level(on bool) bool {
+	return on != l.config.ActiveLow
+}
+
+// ParseLampPins parses a pin mapping such as "red=17,yellow=27,green=22"
+func ParseLampPins(spec string) (map[Lamp]int, error) {
+	pins := make(map[Lamp]int)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid pin mapping %q, expected lamp=pin", entry)
+		}
+		lamp, ok := lampByName(strings.TrimSpace(parts[0]))
+		if !ok {
+			return nil, fmt.Errorf("unknown lamp %q", parts[0])
+		}
+		pin, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || pin < 0 {
+			return nil, fmt.Errorf("invalid pin %q for the %s lamp", parts[1], lamp)
+		}
+		pins[lamp] = pin
+	}
+	return pins, nil
+}
+
+// gpioSysfs drives lines through /sys/class/gpio
+type gpioSysfs struct {
+	// lines maps offsets to their sysfs directories
+	lines map[int]string
+}
+
+// openGPIOSysfs exports the lines of a chip and configures them as outputs
+func openGPIOSysfs(chip string, offsets []int, initial bool) (*gpioSysfs, error) {
+	g := &gpioSysfs{lines: make(map[int]string, len(offsets))}
+	for _, offset := range offsets {
+		dir, err := gpio.SysfsExport(chip, offset)
+		if err != nil {
+			return nil, err
+		}
+		direction := "low"
+		if initial {
+			direction = "high"
+		}
+		// "high" and "low" set the direction and initial level in one go
+		if err := os.WriteFile(filepath.Join(dir, "direction"), []byte(direction), 0644); err != nil {
+			return nil, fmt.Errorf("failed to configure %s: %w", filepath.Base(dir), err)
+		}
+		g.lines[offset] = dir
+	}
+	return g, nil
+}
+
+func (g *gpioSysfs) Set(offset int, high bool) error {
+	dir, ok := g.lines[offset]
+	if !ok {
+		return fmt.Errorf("GPIO line %d was not requested", offset)
+	}
+	value := "0"
+	if high {
+		value = "1"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "value"), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to set %s: %w", filepath.Base(dir), err)
+	}
+	return nil
+}
+
+// Close leaves the lines exported so other tools can still inspect them
+func (g *gpioSysfs) Close() error {
+	return nil
+}
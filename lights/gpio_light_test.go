@@ -0,0 +1,165 @@
+//go:build linux
+// +build linux
+
+package lights
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"my-incident-checker/internal/gpio"
+)
+
+// fakeGPIOChip stands in for the kernel side of /dev/gpiochipN. It hands
+// out a temp file as the line handle and records every set of line values
+type fakeGPIOChip struct {
+	t       *testing.T
+	offsets []uint32
+	flags   uint32
+	label   string
+	values  [][]uint8
+}
+
+func newFakeGPIOChip(t *testing.T) *fakeGPIOChip {
+	chip := &fakeGPIOChip{t: t}
+	old := gpio.Ioctl
+	gpio.Ioctl = chip.ioctl
+	t.Cleanup(func() { gpio.Ioctl = old })
+	return chip
+}
+
+func (c *fakeGPIOChip) ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	switch req {
+	case gpio.GetLineHandleIoctl:
+		r := (*gpio.HandleRequest)(arg)
+		c.offsets = append([]uint32(nil), r.LineOffsets[:r.Lines]...)
+		c.flags = r.Flags
+		c.label = strings.TrimRight(string(r.ConsumerLabel[:]), "\x00")
+		c.values = append(c.values, append([]uint8(nil), r.DefaultValues[:r.Lines]...))
+		handle, err := os.Create(filepath.Join(c.t.TempDir(), "linehandle"))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		dup, err := syscall.Dup(int(handle.Fd()))
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		handle.Close()
+		r.Fd = int32(dup)
+		return nil
+	case gpio.SetLineValuesIoctl:
+		d := (*gpio.HandleData)(arg)
+		c.values = append(c.values, append([]uint8(nil), d.Values[:len(c.offsets)]...))
+		return nil
+	}
+	return syscall.ENOTTY
+}
+
+func TestGPIOLightChardev(t *testing.T) {
+	chip := newFakeGPIOChip(t)
+	oldDev := gpio.DevRoot
+	gpio.DevRoot = t.TempDir()
+	defer func() { gpio.DevRoot = oldDev }()
+	if err := os.WriteFile(filepath.Join(gpio.DevRoot, "gpiochip0"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	light, err := NewGPIOLight(GPIOConfig{
+		Pins:      map[Lamp]int{LampRed: 17, LampGreen: 22, LampBuzzer: 5},
+		ActiveLow: true,
+	})
+	if err != nil {
+		t.Fatalf("NewGPIOLight() error = %v", err)
+	}
+
+	if err := light.On(StateRed); err != nil {
+		t.Fatalf("On() error = %v", err)
+	}
+	if err := light.SetLamp(LampYellow, ModeOn); err == nil {
+		t.Error("SetLamp() expected error for unwired lamp")
+	}
+	if err := light.Blink(StateGreen); err != ErrBlinkUnsupported {
+		t.Errorf("Blink() error = %v, want ErrBlinkUnsupported", err)
+	}
+	if err := light.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if want := []uint32{17, 22, 5}; !reflect.DeepEqual(chip.offsets, want) {
+		t.Errorf("requested offsets = %v, want %v", chip.offsets, want)
+	}
+	if chip.flags != gpio.HandleRequestOutput || chip.label != "my-incident-checker" {
+		t.Errorf("request flags = %d, label = %q", chip.flags, chip.label)
+	}
+	// Active-low: all lines start high (off), red goes low, then all high
+	want := [][]uint8{
+		{1, 1, 1},
+		{0, 1, 1}, {0, 1, 1}, {0, 1, 1},
+		{1, 1, 1}, {1, 1, 1}, {1, 1, 1},
+	}
+	if !reflect.DeepEqual(chip.values, want) {
+		t.Errorf("line values = %v, want %v", chip.values, want)
+	}
+}
+
+func TestGPIOLightSysfsFallback(t *testing.T) {
+	root := t.TempDir()
+	oldDev, oldSys := gpio.DevRoot, gpio.SysRoot
+	gpio.DevRoot, gpio.SysRoot = filepath.Join(root, "dev"), filepath.Join(root, "sys")
+	defer func() { gpio.DevRoot, gpio.SysRoot = oldDev, oldSys }()
+
+	// No /dev/gpiochip0, only the legacy sysfs interface with the chip
+	// based at 512 as on recent Raspberry Pi kernels
+	sysfs := gpio.SysfsRoot()
+	for _, dir := range []string{"gpiochip512", "gpio529", "gpio539", "devices/gpiochip0"} {
+		if err := os.MkdirAll(filepath.Join(sysfs, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(sysfs, "devices/gpiochip0"), filepath.Join(sysfs, "gpiochip512/device")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(sysfs, "gpiochip512/base"), []byte("512\n"), 0644)
+
+	light, err := NewGPIOLight(GPIOConfig{
+		Chip: "gpiochip0",
+		Pins: map[Lamp]int{LampRed: 17, LampYellow: 27},
+	})
+	if err != nil {
+		t.Fatalf("NewGPIOLight() error = %v", err)
+	}
+
+	read := func(path string) string {
+		data, _ := os.ReadFile(filepath.Join(sysfs, path))
+		return string(data)
+	}
+	if got := read("gpio529/direction"); got != "low" {
+		t.Errorf("direction = %q, want low", got)
+	}
+
+	if err := (TowerState{Yellow: ModeOn}).Apply(light); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := read("gpio529/value") + read("gpio539/value"); got != "01" {
+		t.Errorf("values red,yellow = %q, want 01", got)
+	}
+}
+
+func TestParseLampPins(t *testing.T) {
+	pins, err := ParseLampPins("red=17, yellow=27,buzzer=5")
+	if err != nil {
+		t.Fatalf("ParseLampPins() error = %v", err)
+	}
+	want := map[Lamp]int{LampRed: 17, LampYellow: 27, LampBuzzer: 5}
+	if !reflect.DeepEqual(pins, want) {
+		t.Errorf("ParseLampPins() = %v, want %v", pins, want)
+	}
+	if _, err := ParseLampPins("blue=3"); err == nil {
+		t.Error("ParseLampPins() expected error for unknown lamp")
+	}
+}
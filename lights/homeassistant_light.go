@@ -0,0 +1,113 @@
+package lights
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HomeAssistantConfig describes a light entity in Home Assistant
+type HomeAssistantConfig struct {
+	// URL is the base URL of Home Assistant, e.g. http://homeassistant:8123
+	URL string
+	// Token is a long-lived access token
+	Token string
+	// EntityID is the light entity, e.g. light.office_lamp
+	EntityID string
+	// Client is used for requests; a client with a timeout when nil
+	Client *http.Client
+}
+
+// HomeAssistantLight implements Light interface over the Home Assistant
+// light.turn_on and light.turn_off services
+type HomeAssistantLight struct {
+	url      string
+	token    string
+	entityID string
+	client   *http.Client
+
+	mu     sync.Mutex
+	alerts alertRepeater
+}
+
+// NewHomeAssistantLight creates a HomeAssistantLight. No request is made
+// until the first state change
+func NewHomeAssistantLight(config HomeAssistantConfig) (*HomeAssistantLight, error) {
+	if config.URL == "" || config.Token == "" || config.EntityID == "" {
+		return nil, fmt.Errorf("Home Assistant URL, token and entity ID are required")
+	}
+	return &HomeAssistantLight{
+		url:      strings.TrimRight(config.URL, "/") + "/api/services/light/",
+		token:    config.Token,
+		entityID: config.EntityID,
+		client:   defaultHTTPClient(config.Client),
+		alerts:   alertRepeater{interval: alertRepeatInterval},
+	}, nil
+}
+
+// On shows a steady color
+func (l *HomeAssistantLight) On(cmd interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	return l.show(cmd, "")
+}
+
+// Blink uses the long flash of light.turn_on. The flash stops on its own,
+// so it is sent again until the next state change
+func (l *HomeAssistantLight) Blink(cmd interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	if err := l.show(cmd, "long"); err != nil {
+		return err
+	}
+	l.alerts.start(func() error { return l.show(cmd, "long") })
+	return nil
+}
+
+// Clear switches the light off
+func (l *HomeAssistantLight) Clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	return l.call("turn_off", map[string]interface{}{"entity_id": l.entityID})
+}
+
+// Close stops repeating the flash
+func (l *HomeAssistantLight) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	return nil
+}
+
+func (l *HomeAssistantLight) show(cmd interface{}, flash string) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for HomeAssistantLight")
+	}
+	color, err := ColorFor(state)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"entity_id":  l.entityID,
+		"rgb_color":  []int{int(color.R), int(color.G), int(color.B)},
+		"brightness": 255,
+	}
+	if flash != "" {
+		data["flash"] = flash
+	}
+	return l.call("turn_on", data)
+}
+
+func (l *HomeAssistantLight) call(service string, data map[string]interface{}) error {
+	headers := map[string]string{"Authorization": "Bearer " + l.token}
+	if _, err := sendJSON(l.client, http.MethodPost, l.url+service, headers, data); err != nil {
+		return fmt.Errorf("Home Assistant light.%s failed: %w", service, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package lights
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"my-incident-checker/httpclient"
+)
+
+const (
+	// httpLightTimeout bounds each request to a network-attached light
+	httpLightTimeout = 5 * time.Second
+	// alertRepeatInterval is how often a blinking network light is sent
+	// its alert again. Alert effects stop on their own, the Hue "lselect"
+	// alert after about 15 seconds
+	alertRepeatInterval = 10 * time.Second
+)
+
+// defaultHTTPClient returns client, or the shared outbound client with a
+// timeout when nil
+func defaultHTTPClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return httpclient.New(httpLightTimeout)
+}
+
+// sendJSON sends body as JSON and returns the response body. Non-2xx
+// responses are returned as errors
+func sendJSON(client *http.Client, method, url string, headers map[string]string, body interface{}) ([]byte, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	const maxResponseSize = 1 << 20 // 1 MB
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return respBody, nil
+}
+
+// alertRepeater sends the alert effect of a network light again before it
+// runs out, until the next state change
+type alertRepeater struct {
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// start calls send every interval on a new goroutine
+func (r *alertRepeater) start(send func() error) {
+	r.stopRepeating()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.stop, r.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			if err := send(); err != nil {
+				log.Printf("Error repeating light alert: %s", err.Error())
+			}
+		}
+	}()
+}
+
+// stopRepeating stops the running repeat and waits for it to exit
+func (r *alertRepeater) stopRepeating() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.stop, r.done = nil, nil
+}
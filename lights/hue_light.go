@@ -0,0 +1,138 @@
+package lights
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HueConfig describes a light or group on a Philips Hue bridge
+type HueConfig struct {
+	// BridgeURL is the base URL of the bridge, e.g. http://192.168.1.2
+	BridgeURL string
+	// Username is the API key issued by the bridge
+	Username string
+	// LightID selects a single light; Group selects a room or zone instead
+	LightID string
+	Group   string
+	// Client is used for requests; a client with a timeout when nil
+	Client *http.Client
+}
+
+// HueLight implements Light interface over the Hue bridge local REST API
+type HueLight struct {
+	url    string
+	client *http.Client
+
+	mu     sync.Mutex
+	alerts alertRepeater
+}
+
+// NewHueLight creates a HueLight. No request is made until the first
+// state change
+func NewHueLight(config HueConfig) (*HueLight, error) {
+	if config.BridgeURL == "" || config.Username == "" {
+		return nil, fmt.Errorf("Hue bridge URL and username are required")
+	}
+
+	base := strings.TrimRight(config.BridgeURL, "/") + "/api/" + config.Username
+	var url string
+	switch {
+	case config.LightID != "":
+		url = base + "/lights/" + config.LightID + "/state"
+	case config.Group != "":
+		url = base + "/groups/" + config.Group + "/action"
+	default:
+		return nil, fmt.Errorf("Hue light ID or group is required")
+	}
+
+	return &HueLight{
+		url:    url,
+		client: defaultHTTPClient(config.Client),
+		alerts: alertRepeater{interval: alertRepeatInterval},
+	}, nil
+}
+
+// On shows a steady color
+func (l *HueLight) On(cmd interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	return l.show(cmd, "none")
+}
+
+// Blink uses the Hue "lselect" alert. The bridge runs it for about 15
+// seconds, so it is sent again until the next state change
+func (l *HueLight) Blink(cmd interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	if err := l.show(cmd, "lselect"); err != nil {
+		return err
+	}
+	l.alerts.start(func() error { return l.show(cmd, "lselect") })
+	return nil
+}
+
+// Clear switches the light off
+func (l *HueLight) Clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	return l.send(map[string]interface{}{"on": false, "alert": "none"})
+}
+
+// Close stops repeating the alert
+func (l *HueLight) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.alerts.stopRepeating()
+	return nil
+}
+
+func (l *HueLight) show(cmd interface{}, alert string) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for HueLight")
+	}
+	color, err := ColorFor(state)
+	if err != nil {
+		return err
+	}
+
+	hue, sat := color.HueSat()
+	return l.send(map[string]interface{}{
+		"on":    true,
+		"bri":   254,
+		"hue":   hue,
+		"sat":   sat,
+		"alert": alert,
+	})
+}
+
+// send issues the state change. The bridge answers 200 even for failed
+// changes and reports them as error entries in the response body
+func (l *HueLight) send(state map[string]interface{}) error {
+	body, err := sendJSON(l.client, http.MethodPut, l.url, nil, state)
+	if err != nil {
+		return fmt.Errorf("Hue request failed: %w", err)
+	}
+
+	var results []struct {
+		Error *struct {
+			Type        int    `json:"type"`
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return fmt.Errorf("failed to parse Hue response: %w", err)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			return fmt.Errorf("Hue bridge error %d: %s", result.Error.Type, result.Error.Description)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package lights
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordedRequest is a request received by a stand-in server
+type recordedRequest struct {
+	Method string
+	Path   string
+	Auth   string
+	Body   map[string]interface{}
+}
+
+// newStandIn starts a server that records JSON requests and answers with
+// the given body
+func newStandIn(t *testing.T, response string) (*httptest.Server, *[]recordedRequest) {
+	t.Helper()
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("request body is not a JSON object: %s", data)
+		}
+		requests = append(requests, recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Auth:   r.Header.Get("Authorization"),
+			Body:   body,
+		})
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// decode round-trips v through JSON so it compares equal to a decoded body
+func decode(t *testing.T, v interface{}) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+func TestWLEDLight(t *testing.T) {
+	server, requests := newStandIn(t, `{"success":true}`)
+	light, err := NewWLEDLight(WLEDConfig{URL: server.URL + "/", Segment: 2, Client: server.Client()})
+	if err != nil {
+		t.Fatalf("NewWLEDLight() error = %v", err)
+	}
+
+	if err := light.Blink(StateRed); err != nil {
+		t.Fatalf("Blink() error = %v", err)
+	}
+	if err := light.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+
+	want := []recordedRequest{
+		{Method: "POST", Path: "/json/state", Body: decode(t, map[string]interface{}{
+			"on": true, "bri": 255,
+			"seg": []interface{}{map[string]interface{}{
+				"id": 2, "on": true, "bri": 255, "col": [][]int{{255, 0, 0}}, "fx": 1, "sx": 128,
+			}},
+		})},
+		{Method: "POST", Path: "/json/state", Body: decode(t, map[string]interface{}{
+			"seg": []interface{}{map[string]interface{}{"id": 2, "on": false}},
+		})},
+	}
+	if !reflect.DeepEqual(*requests, want) {
+		t.Errorf("requests = %+v, want %+v", *requests, want)
+	}
+}
+
+func TestHueLight(t *testing.T) {
+	server, requests := newStandIn(t, `[{"success":{"/lights/3/state/on":true}}]`)
+	light, err := NewHueLight(HueConfig{BridgeURL: server.URL, Username: "key", LightID: "3", Client: server.Client()})
+	if err != nil {
+		t.Fatalf("NewHueLight() error = %v", err)
+	}
+
+	if err := light.On(StateGreen); err != nil {
+		t.Fatalf("On() error = %v", err)
+	}
+	want := recordedRequest{Method: "PUT", Path: "/api/key/lights/3/state", Body: decode(t, map[string]interface{}{
+		"on": true, "bri": 254, "hue": 21845, "sat": 254, "alert": "none",
+	})}
+	if len(*requests) != 1 || !reflect.DeepEqual((*requests)[0], want) {
+		t.Errorf("requests = %+v, want %+v", *requests, want)
+	}
+}
+
+func TestHueLightBridgeError(t *testing.T) {
+	server, _ := newStandIn(t, `[{"error":{"type":1,"description":"unauthorized user"}}]`)
+	light, _ := NewHueLight(HueConfig{BridgeURL: server.URL, Username: "bad", Group: "1", Client: server.Client()})
+
+	if err := light.Blink(StateYellow); err == nil {
+		t.Error("Blink() expected error from bridge")
+	}
+}
+
+func TestHomeAssistantLight(t *testing.T) {
+	server, requests := newStandIn(t, `[]`)
+	light, err := NewHomeAssistantLight(HomeAssistantConfig{
+		URL: server.URL, Token: "secret", EntityID: "light.office", Client: server.Client(),
+	})
+	if err != nil {
+		t.Fatalf("NewHomeAssistantLight() error = %v", err)
+	}
+
+	if err := light.Blink(StateYellow); err != nil {
+		t.Fatalf("Blink() error = %v", err)
+	}
+	if err := light.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+
+	want := []recordedRequest{
+		{Method: "POST", Path: "/api/services/light/turn_on", Auth: "Bearer secret", Body: decode(t, map[string]interface{}{
+			"entity_id": "light.office", "rgb_color": []int{255, 160, 0}, "brightness": 255, "flash": "long",
+		})},
+		{Method: "POST", Path: "/api/services/light/turn_off", Auth: "Bearer secret", Body: decode(t, map[string]interface{}{
+			"entity_id": "light.office",
+		})},
+	}
+	if !reflect.DeepEqual(*requests, want) {
+		t.Errorf("requests = %+v, want %+v", *requests, want)
+	}
+}
+
+func TestNetworkLightStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	light, _ := NewHomeAssistantLight(HomeAssistantConfig{
+		URL: server.URL, Token: "expired", EntityID: "light.office", Client: server.Client(),
+	})
+	if err := light.On(StateRed); err == nil {
+		t.Error("On() expected error for 401 response")
+	}
+}
+
+func TestHueLightRepeatsAlert(t *testing.T) {
+	var alerts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		json.Unmarshal(data, &body)
+		if body["alert"] == "lselect" {
+			atomic.AddInt32(&alerts, 1)
+		}
+		io.WriteString(w, `[]`)
+	}))
+	defer server.Close()
+	light, _ := NewHueLight(HueConfig{BridgeURL: server.URL, Username: "key", LightID: "3", Client: server.Client()})
+	light.alerts.interval = 5 * time.Millisecond
+
+	if err := light.Blink(StateRed); err != nil {
+		t.Fatalf("Blink() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&alerts) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := light.On(StateGreen); err != nil {
+		t.Fatalf("On() error = %v", err)
+	}
+	sent := atomic.LoadInt32(&alerts)
+	if sent < 3 {
+		t.Fatalf("alert sent %d times, want it repeated", sent)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt32(&alerts); after != sent {
+		t.Errorf("alert sent %d more times after On()", after-sent)
+	}
+}
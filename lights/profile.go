@@ -0,0 +1,255 @@
+package lights
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Checksum algorithms supported by serial profiles. The checksum covers
+// the prefix and the command bytes and is written before the suffix
+const (
+	ChecksumNone = ""
+	ChecksumSum8 = "sum8" // low byte of the sum of all bytes
+	ChecksumXOR8 = "xor8" // XOR of all bytes
+)
+
+// Profile describes the serial protocol of a tower light or relay board:
+// line settings, the byte sequences per lamp and mode, the framing around
+// each command and an init sequence sent whenever the port is opened
+type Profile struct {
+	Name     string `json:"name"`
+	Baud     int    `json:"baud"`
+	DataBits int    `json:"data_bits"` // defaults to 8
+	Parity   string `json:"parity"`    // "none", "odd" or "even"
+	StopBits int    `json:"stop_bits"` // 1 or 2, defaults to 1
+
+	Init     HexBytes `json:"init"`
+	Prefix   HexBytes `json:"prefix"`
+	Suffix   HexBytes `json:"suffix"`
+	Checksum string   `json:"checksum"`
+
+	// Lamps maps lamp names ("red", "yellow", "green", "buzzer") to their
+	// commands. Lamps the device doesn't have are simply left out
+	Lamps map[string]LampCommands `json:"lamps"`
+}
+
+// LampCommands holds the command bytes of one lamp. An empty Blink means
+// the device can't blink that lamp in hardware
+type LampCommands struct {
+	On    HexBytes `json:"on"`
+	Off   HexBytes `json:"off"`
+	Blink HexBytes `json:"blink"`
+}
+
+// HexBytes is a byte sequence written in JSON either as a string of hex
+// bytes ("A0 01 01" or "0xA0,0x01") or as an array of numbers
+type HexBytes []byte
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *HexBytes) UnmarshalJSON(data []byte) error {
+	var numbers []int
+	if err := json.Unmarshal(data, &numbers); err == nil {
+		out := make(HexBytes, 0, len(numbers))
+		for _, n := range numbers {
+			if n < 0 || n > 0xFF {
+				return fmt.Errorf("byte value out of range: %d", n)
+			}
+			out = append(out, byte(n))
+		}
+		*b = out
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("byte sequence must be a hex string or an array of numbers")
+	}
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == ',' || r == ':'
+	})
+	out := make(HexBytes, 0, len(fields))
+	for _, field := range fields {
+		field = strings.TrimPrefix(strings.TrimPrefix(field, "0x"), "0X")
+		n, err := strconv.ParseUint(field, 16, 8)
+		if err != nil {
+			return fmt.Errorf("invalid hex byte %q", field)
+		}
+		out = append(out, byte(n))
+	}
+	*b = out
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (b HexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b HexBytes) String() string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02X", v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// builtinProfiles are the profiles that can be selected by name
+var builtinProfiles = map[string]Profile{
+	// USB tower light with three lamps and a buzzer, one byte per command
+	"default": {
+		Name: "default",
+		Baud: 9600,
+		Lamps: map[string]LampCommands{
+			"red":    {On: HexBytes{cmdRedOn}, Off: HexBytes{cmdRedOff}, Blink: HexBytes{cmdRedBlink}},
+			"yellow": {On: HexBytes{cmdYellowOn}, Off: HexBytes{cmdYellowOff}, Blink: HexBytes{cmdYellowBlink}},
+			"green":  {On: HexBytes{cmdGreenOn}, Off: HexBytes{cmdGreenOff}, Blink: HexBytes{cmdGreenBlink}},
+			"buzzer": {On: HexBytes{cmdBuzzerOn}, Off: HexBytes{cmdBuzzerOff}, Blink: HexBytes{cmdBuzzerBlink}},
+		},
+	},
+	// LCUS-4 style USB relay board, relays 1-4 wired to red, yellow, green
+	// and the buzzer. Frames are A0 <relay> <state> <sum8>
+	"lcus4": {
+		Name:     "lcus4",
+		Baud:     9600,
+		Prefix:   HexBytes{0xA0},
+		Checksum: ChecksumSum8,
+		Lamps: map[string]LampCommands{
+			"red":    {On: HexBytes{0x01, 0x01}, Off: HexBytes{0x01, 0x00}},
+			"yellow": {On: HexBytes{0x02, 0x01}, Off: HexBytes{0x02, 0x00}},
+			"green":  {On: HexBytes{0x03, 0x01}, Off: HexBytes{0x03, 0x00}},
+			"buzzer": {On: HexBytes{0x04, 0x01}, Off: HexBytes{0x04, 0x00}},
+		},
+	},
+}
+
+// DefaultProfile returns the profile of the stock USB tower light
+func DefaultProfile() Profile {
+	return builtinProfiles["default"]
+}
+
+// LoadProfile returns the built-in profile with the given name, or reads a
+// JSON profile from the given file path
+func LoadProfile(nameOrPath string) (Profile, error) {
+	if profile, ok := builtinProfiles[nameOrPath]; ok {
+		return profile, nil
+	}
+
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return Profile{}, fmt.Errorf("unknown serial profile %q: %w", nameOrPath, err)
+	}
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return Profile{}, fmt.Errorf("failed to parse serial profile %s: %w", nameOrPath, err)
+	}
+	if profile.Name == "" {
+		profile.Name = nameOrPath
+	}
+	if err := profile.Validate(); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// Validate checks the profile for settings the serial port can't use
+func (p Profile) Validate() error {
+	if p.Baud <= 0 {
+		return fmt.Errorf("serial profile %s: baud must be set", p.Name)
+	}
+	switch p.DataBits {
+	case 0, 5, 6, 7, 8:
+	default:
+		return fmt.Errorf("serial profile %s: unsupported data bits %d", p.Name, p.DataBits)
+	}
+	switch strings.ToLower(p.Parity) {
+	case "", "none", "odd", "even":
+	default:
+		return fmt.Errorf("serial profile %s: unsupported parity %q", p.Name, p.Parity)
+	}
+	switch p.StopBits {
+	case 0, 1, 2:
+	default:
+		return fmt.Errorf("serial profile %s: unsupported stop bits %d", p.Name, p.StopBits)
+	}
+	switch p.Checksum {
+	case ChecksumNone, ChecksumSum8, ChecksumXOR8:
+	default:
+		return fmt.Errorf("serial profile %s: unsupported checksum %q", p.Name, p.Checksum)
+	}
+	for name := range p.Lamps {
+		if _, ok := lampByName(name); !ok {
+			return fmt.Errorf("serial profile %s: unknown lamp %q", p.Name, name)
+		}
+	}
+	return nil
+}
+
+// HasLamp reports whether the device has the given lamp
+func (p Profile) HasLamp(lamp Lamp) bool {
+	_, ok := p.Lamps[lamp.String()]
+	return ok
+}
+
+// CanBlink reports whether every lamp of the device can blink in hardware
+func (p Profile) CanBlink() bool {
+	for _, cmds := range p.Lamps {
+		if len(cmds.Blink) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Frame returns the complete bytes to send to set a lamp to the given mode
+func (p Profile) Frame(lamp Lamp, mode LampMode) ([]byte, error) {
+	cmds, ok := p.Lamps[lamp.String()]
+	if !ok {
+		return nil, fmt.Errorf("serial profile %s has no %s lamp", p.Name, lamp)
+	}
+
+	var cmd HexBytes
+	switch mode {
+	case ModeOff:
+		cmd = cmds.Off
+	case ModeOn:
+		cmd = cmds.On
+	case ModeBlink:
+		cmd = cmds.Blink
+	default:
+		return nil, fmt.Errorf("unsupported lamp mode: %s", mode)
+	}
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("serial profile %s has no %s command for the %s lamp", p.Name, mode, lamp)
+	}
+
+	frame := make([]byte, 0, len(p.Prefix)+len(cmd)+1+len(p.Suffix))
+	frame = append(frame, p.Prefix...)
+	frame = append(frame, cmd...)
+	switch p.Checksum {
+	case ChecksumSum8:
+		var sum byte
+		for _, b := range frame {
+			sum += b
+		}
+		frame = append(frame, sum)
+	case ChecksumXOR8:
+		var sum byte
+		for _, b := range frame {
+			sum ^= b
+		}
+		frame = append(frame, sum)
+	}
+	return append(frame, p.Suffix...), nil
+}
+
+func lampByName(name string) (Lamp, bool) {
+	for _, lamp := range Lamps {
+		if lamp.String() == name {
+			return lamp, true
+		}
+	}
+	return 0, false
+}
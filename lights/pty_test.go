@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package lights
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptyHarness is a pseudo-terminal pair standing in for a serial device.
+// The light under test opens Path, and every byte it writes can be read
+// back from the master side
+type ptyHarness struct {
+	t      *testing.T
+	master *os.File
+	Path   string
+}
+
+func newPTYHarness(t *testing.T) *ptyHarness {
+	t.Helper()
+	// A non-blocking descriptor makes the master pollable, so read
+	// deadlines work
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals not available: %v", err)
+	}
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+	t.Cleanup(func() { master.Close() })
+
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Fatalf("failed to unlock pty: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Fatalf("failed to get pty number: %v", err)
+	}
+
+	// Keep the slave side open for the lifetime of the test so closing and
+	// reopening the port doesn't hang up the master
+	path := "/dev/pts/" + strconv.Itoa(n)
+	slave, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatalf("failed to open pty slave: %v", err)
+	}
+	t.Cleanup(func() { slave.Close() })
+
+	return &ptyHarness{t: t, master: master, Path: path}
+}
+
+// expect reads exactly len(want) bytes from the device side and fails the
+// test if they differ
+func (h *ptyHarness) expect(want []byte) {
+	h.t.Helper()
+	got := make([]byte, 0, len(want))
+	deadline := time.Now().Add(2 * time.Second)
+	buf := make([]byte, 64)
+	for len(got) < len(want) && time.Now().Before(deadline) {
+		h.master.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _ := h.master.Read(buf)
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, want) {
+		h.t.Fatalf("device received % X, want % X", got, want)
+	}
+}
+
+// expectNothing fails the test if any byte arrives within a short window
+func (h *ptyHarness) expectNothing() {
+	h.t.Helper()
+	buf := make([]byte, 64)
+	h.master.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _ := h.master.Read(buf); n > 0 {
+		h.t.Fatalf("device received unexpected % X", buf[:n])
+	}
+}
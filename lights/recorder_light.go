@@ -0,0 +1,74 @@
+package lights
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RecorderLight writes every light update with a timestamp and the
+// resulting lamp state, for running the checker headless and for
+// replaying what a real light would have shown
+type RecorderLight struct {
+	mu    sync.Mutex
+	out   io.Writer
+	lamps TowerState
+	now   func() time.Time
+}
+
+// NewRecorderLight creates a RecorderLight writing to out
+func NewRecorderLight(out io.Writer) *RecorderLight {
+	return &RecorderLight{out: out, now: time.Now}
+}
+
+// On records a steady state, a StandardState or a whole TowerState
+func (l *RecorderLight) On(cmd interface{}) error {
+	switch state := cmd.(type) {
+	case StandardState:
+		return l.record(fmt.Sprintf("on %s", state), replaceLamps(TowerStateFor(state, ModeOn)))
+	case TowerState:
+		return l.record(fmt.Sprintf("on %s", state), replaceLamps(state))
+	}
+	return fmt.Errorf("invalid command type for RecorderLight")
+}
+
+// Blink records a blinking state
+func (l *RecorderLight) Blink(cmd interface{}) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for RecorderLight")
+	}
+	lamp, err := lampForState(state)
+	if err != nil {
+		return err
+	}
+	return l.record(fmt.Sprintf("blink %s", state), withLamp(lamp, ModeBlink))
+}
+
+// Clear records all lamps going off
+func (l *RecorderLight) Clear() error {
+	return l.record("clear", replaceLamps(TowerState{}))
+}
+
+// SetLamp records a single lamp change
+func (l *RecorderLight) SetLamp(lamp Lamp, mode LampMode) error {
+	return l.record(fmt.Sprintf("set %s=%s", lamp, mode), withLamp(lamp, mode))
+}
+
+// State returns the lamps as last recorded
+func (l *RecorderLight) State() TowerState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lamps
+}
+
+// record applies change to the lamps and writes the call with the result,
+// in one critical section so that concurrent changes aren't lost
+func (l *RecorderLight) record(call string, change func(TowerState) TowerState) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lamps = change(l.lamps)
+	_, err := fmt.Fprintf(l.out, "%s %s -> %s\n", l.now().UTC().Format(time.RFC3339Nano), call, l.lamps)
+	return err
+}
@@ -0,0 +1,183 @@
+package lights
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// AutoDriver selects the first driver whose probe finds a device
+const AutoDriver = "auto"
+
+// DriverOption describes an option accepted by a driver
+type DriverOption struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// Driver is a registered light driver
+type Driver struct {
+	Name        string
+	Description string
+	Options     []DriverOption
+	// AutoOrder is the position of the driver when probing for the auto
+	// driver, lowest first. Drivers with zero are never probed
+	AutoOrder int
+	// Probe looks for a device without taking it over and describes what
+	// it found. Nil when the device can't be detected
+	Probe func(options map[string]string) (string, error)
+	// Open opens the device. The returned close function may be nil
+	Open func(options map[string]string) (Light, func() error, error)
+}
+
+// Device is an opened light and the driver that opened it
+type Device struct {
+	Driver string
+	Light  Light
+	// Close releases the device, never nil
+	Close func() error
+}
+
+// Detection is the result of probing one driver
+type Detection struct {
+	Driver string
+	Found  string
+	Err    error
+}
+
+var (
+	driversMu sync.Mutex
+	drivers   = make(map[string]Driver)
+)
+
+// RegisterDriver makes a driver available by name. It panics when the name
+// is taken, since that is a programming error
+func RegisterDriver(driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver.Name == "" || driver.Name == AutoDriver || driver.Open == nil {
+		panic("lights: invalid driver " + driver.Name)
+	}
+	if _, exists := drivers[driver.Name]; exists {
+		panic("lights: driver registered twice: " + driver.Name)
+	}
+	drivers[driver.Name] = driver
+}
+
+// LookupDriver returns the driver registered under name
+func LookupDriver(name string) (Driver, bool) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	driver, ok := drivers[name]
+	return driver, ok
+}
+
+// Drivers returns every registered driver sorted by name
+func Drivers() []Driver {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	out := make([]Driver, 0, len(drivers))
+	for _, driver := range drivers {
+		out = append(out, driver)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// autoDrivers returns the drivers probed by the auto driver, in order
+func autoDrivers() []Driver {
+	var out []Driver
+	for _, driver := range Drivers() {
+		if driver.AutoOrder > 0 && driver.Probe != nil {
+			out = append(out, driver)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AutoOrder < out[j].AutoOrder })
+	return out
+}
+
+// Validate checks options against the schema of the driver
+func (d Driver) Validate(options map[string]string) error {
+	known := make(map[string]bool, len(d.Options))
+	for _, option := range d.Options {
+		known[option.Name] = true
+		if option.Required && options[option.Name] == "" {
+			return fmt.Errorf("driver %s requires option %q", d.Name, option.Name)
+		}
+	}
+	for name := range options {
+		if !known[name] {
+			return fmt.Errorf("driver %s has no option %q", d.Name, name)
+		}
+	}
+	return nil
+}
+
+// optionsFor keeps only the options the driver knows, so shared settings
+// can be passed to every probed driver
+func (d Driver) optionsFor(options map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, option := range d.Options {
+		if value, ok := options[option.Name]; ok {
+			out[option.Name] = value
+		}
+	}
+	return out
+}
+
+// OpenDevice opens a light with the named driver. The auto driver probes
+// the drivers in their AutoOrder and opens the first device found
+func OpenDevice(name string, options map[string]string) (*Device, error) {
+	if name == AutoDriver {
+		return openAuto(options)
+	}
+	driver, ok := LookupDriver(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown light driver %q", name)
+	}
+	if err := driver.Validate(options); err != nil {
+		return nil, err
+	}
+	return openWith(driver, options)
+}
+
+func openWith(driver Driver, options map[string]string) (*Device, error) {
+	light, closeLight, err := driver.Open(options)
+	if err != nil {
+		return nil, err
+	}
+	if closeLight == nil {
+		closeLight = func() error { return nil }
+	}
+	return &Device{Driver: driver.Name, Light: light, Close: closeLight}, nil
+}
+
+func openAuto(options map[string]string) (*Device, error) {
+	var failed []string
+	for _, driver := range autoDrivers() {
+		driverOptions := driver.optionsFor(options)
+		if _, err := driver.Probe(driverOptions); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", driver.Name, err.Error()))
+			continue
+		}
+		device, err := openWith(driver, driverOptions)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", driver.Name, err.Error()))
+			continue
+		}
+		return device, nil
+	}
+	return nil, fmt.Errorf("no light detected (%s)", strings.Join(failed, "; "))
+}
+
+// ProbeDrivers probes every driver that supports detection, in auto order
+func ProbeDrivers(options map[string]string) []Detection {
+	var out []Detection
+	for _, driver := range autoDrivers() {
+		found, err := driver.Probe(driver.optionsFor(options))
+		out = append(out, Detection{Driver: driver.Name, Found: found, Err: err})
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package lights
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDriverValidate(t *testing.T) {
+	driver, ok := LookupDriver("homeassistant")
+	if !ok {
+		t.Fatal("homeassistant driver not registered")
+	}
+	if err := driver.Validate(map[string]string{"url": "http://ha", "token": "t"}); err == nil || !strings.Contains(err.Error(), "entity_id") {
+		t.Errorf("Validate() error = %v, want missing entity_id", err)
+	}
+	if err := driver.Validate(map[string]string{"url": "http://ha", "token": "t", "entity_id": "light.x", "port": "x"}); err == nil {
+		t.Error("Validate() accepted an unknown option")
+	}
+	if _, err := OpenDevice("nonexistent", nil); err == nil {
+		t.Error("OpenDevice() accepted an unknown driver")
+	}
+}
+
+func TestOpenDeviceAuto(t *testing.T) {
+	var probed []string
+	RegisterDriver(Driver{
+		Name:      "test-missing",
+		AutoOrder: autoOrderGPIO,
+		Options:   []DriverOption{{Name: "test_option"}},
+		Probe: func(options map[string]string) (string, error) {
+			probed = append(probed, "test-missing:"+options["test_option"])
+			return "", errors.New("not connected")
+		},
+		Open: func(map[string]string) (Light, func() error, error) {
+			t.Error("opened a driver whose probe failed")
+			return nil, nil, errors.New("not connected")
+		},
+	})
+	RegisterDriver(Driver{
+		Name:      "test-present",
+		AutoOrder: autoOrderGPIO,
+		Probe: func(options map[string]string) (string, error) {
+			probed = append(probed, "test-present")
+			if len(options) != 0 {
+				t.Errorf("probe got options %v it doesn't declare", options)
+			}
+			return "fake device", nil
+		},
+		Open: func(map[string]string) (Light, func() error, error) {
+			return &recordingLight{}, nil, nil
+		},
+	})
+	defer func() {
+		driversMu.Lock()
+		delete(drivers, "test-missing")
+		delete(drivers, "test-present")
+		driversMu.Unlock()
+	}()
+
+	device, err := OpenDevice(AutoDriver, map[string]string{"test_option": "1"})
+	if err != nil {
+		t.Fatalf("OpenDevice(auto) error = %v", err)
+	}
+	if device.Driver != "test-present" || device.Close() != nil {
+		t.Errorf("OpenDevice(auto) = %+v, want test-present with a close function", device)
+	}
+	if strings.Join(probed, ",") != "test-missing:1,test-present" {
+		t.Errorf("probed %v, want drivers of the same order by name", probed)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterDriver() accepted a duplicate name")
+		}
+	}()
+	RegisterDriver(Driver{Name: "test-present", Open: func(map[string]string) (Light, func() error, error) { return nil, nil, nil }})
+}
@@ -0,0 +1,149 @@
+package lights
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Filesystem roots used for discovery, replaced in tests with a fake tree
+var (
+	devRoot = "/dev"
+	sysRoot = "/sys"
+)
+
+// SerialMatch selects serial ports by the USB IDs of the device behind
+// them. An empty field matches any ID, but an empty match selects no port,
+// so that discovery never opens an unrelated adapter
+type SerialMatch struct {
+	VendorID  string
+	ProductID string
+}
+
+// DefaultSerialMatch selects the CH340 adapter of the stock USB tower light
+var DefaultSerialMatch = SerialMatch{VendorID: "1a86", ProductID: "7523"}
+
+// Empty reports whether the match sets no ID
+func (m SerialMatch) Empty() bool {
+	return m.VendorID == "" && m.ProductID == ""
+}
+
+// Matches reports whether a discovered port satisfies the match
+func (m SerialMatch) Matches(port SerialPortInfo) bool {
+	if m.Empty() {
+		return false
+	}
+	if m.VendorID != "" && !strings.EqualFold(m.VendorID, port.VendorID) {
+		return false
+	}
+	if m.ProductID != "" && !strings.EqualFold(m.ProductID, port.ProductID) {
+		return false
+	}
+	return true
+}
+
+// SerialPortInfo describes a serial port found on the system
+type SerialPortInfo struct {
+	// Path is the stable /dev/serial/by-id link when one exists, so the
+	// same device is found again after a replug, else the tty node itself
+	Path string
+	// Device is the tty node, e.g. /dev/ttyUSB0
+	Device    string
+	VendorID  string
+	ProductID string
+}
+
+// DiscoverSerialPorts lists USB serial ports under /dev/ttyUSB*,
+// /dev/ttyACM* and /dev/serial/by-id along with their USB IDs
+func DiscoverSerialPorts() ([]SerialPortInfo, error) {
+	var devices []string
+	for _, pattern := range []string{"ttyUSB*", "ttyACM*"} {
+		matches, err := filepath.Glob(filepath.Join(devRoot, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("error searching for serial ports: %w", err)
+		}
+		devices = append(devices, matches...)
+	}
+
+	// Map tty nodes to their stable by-id links. These also cover devices
+	// whose tty name doesn't follow the patterns above
+	byID := make(map[string]string)
+	links, _ := filepath.Glob(filepath.Join(devRoot, "serial", "by-id", "*"))
+	for _, link := range links {
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		if _, seen := byID[target]; !seen {
+			byID[target] = link
+		}
+		if !containsString(devices, target) {
+			devices = append(devices, target)
+		}
+	}
+	sort.Strings(devices)
+
+	var ports []SerialPortInfo
+	for _, device := range devices {
+		port := SerialPortInfo{
+			Path:   device,
+			Device: device,
+		}
+		if link, ok := byID[device]; ok {
+			port.Path = link
+		}
+		port.VendorID, port.ProductID = usbIDsForTTY(filepath.Base(device))
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// FindSerialPort returns the path of the first serial port that matches
+func FindSerialPort(match SerialMatch) (string, error) {
+	if match.Empty() {
+		return "", fmt.Errorf("no USB IDs to discover a serial port by")
+	}
+	ports, err := DiscoverSerialPorts()
+	if err != nil {
+		return "", err
+	}
+	for _, port := range ports {
+		if match.Matches(port) {
+			return port.Path, nil
+		}
+	}
+	return "", fmt.Errorf("no serial port found for USB device %s:%s", match.VendorID, match.ProductID)
+}
+
+// usbIDsForTTY reads the vendor and product IDs of the USB device behind a
+// tty by walking up its sysfs device path
+func usbIDsForTTY(name string) (string, string) {
+	devicePath, err := filepath.EvalSymlinks(filepath.Join(sysRoot, "class", "tty", name, "device"))
+	if err != nil {
+		return "", ""
+	}
+
+	for dir := devicePath; strings.HasPrefix(dir, sysRoot) && dir != sysRoot; dir = filepath.Dir(dir) {
+		vendor, err := os.ReadFile(filepath.Join(dir, "idVendor"))
+		if err != nil {
+			continue
+		}
+		product, err := os.ReadFile(filepath.Join(dir, "idProduct"))
+		if err != nil {
+			continue
+		}
+		return strings.TrimSpace(string(vendor)), strings.TrimSpace(string(product))
+	}
+	return "", ""
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package lights
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeSerialTree builds a /dev and /sys tree with one FTDI adapter on
+// ttyUSB0 (with a by-id link) and one CDC-ACM device on ttyACM0
+func fakeSerialTree(t *testing.T) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mkdir := func(path string) {
+		if err := os.MkdirAll(filepath.Join(root, path), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(path, content string) {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink := func(target, path string) {
+		if err := os.Symlink(target, filepath.Join(root, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mkdir("dev/serial/by-id")
+	write("dev/ttyUSB0", "")
+	write("dev/ttyACM0", "")
+	write("dev/ttyS0", "")
+	symlink("../../ttyUSB0", "dev/serial/by-id/usb-FTDI_FT232R-if00-port0")
+
+	mkdir("sys/devices/usb1/1-1/1-1:1.0/ttyUSB0")
+	write("sys/devices/usb1/1-1/idVendor", "0403\n")
+	write("sys/devices/usb1/1-1/idProduct", "6001\n")
+	mkdir("sys/devices/usb1/1-2/1-2:1.0/tty")
+	write("sys/devices/usb1/1-2/idVendor", "2341\n")
+	write("sys/devices/usb1/1-2/idProduct", "0043\n")
+
+	mkdir("sys/class/tty/ttyUSB0")
+	mkdir("sys/class/tty/ttyACM0")
+	symlink(filepath.Join(root, "sys/devices/usb1/1-1/1-1:1.0/ttyUSB0"), "sys/class/tty/ttyUSB0/device")
+	symlink(filepath.Join(root, "sys/devices/usb1/1-2/1-2:1.0"), "sys/class/tty/ttyACM0/device")
+
+	oldDev, oldSys := devRoot, sysRoot
+	devRoot, sysRoot = filepath.Join(root, "dev"), filepath.Join(root, "sys")
+	t.Cleanup(func() { devRoot, sysRoot = oldDev, oldSys })
+}
+
+func TestDiscoverSerialPorts(t *testing.T) {
+	fakeSerialTree(t)
+
+	ports, err := DiscoverSerialPorts()
+	if err != nil {
+		t.Fatalf("DiscoverSerialPorts() error = %v", err)
+	}
+	want := []SerialPortInfo{
+		{
+			Path:      filepath.Join(devRoot, "ttyACM0"),
+			Device:    filepath.Join(devRoot, "ttyACM0"),
+			VendorID:  "2341",
+			ProductID: "0043",
+		},
+		{
+			Path:      filepath.Join(devRoot, "serial/by-id/usb-FTDI_FT232R-if00-port0"),
+			Device:    filepath.Join(devRoot, "ttyUSB0"),
+			VendorID:  "0403",
+			ProductID: "6001",
+		},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("DiscoverSerialPorts() = %+v, want %+v", ports, want)
+	}
+}
+
+func TestFindSerialPort(t *testing.T) {
+	fakeSerialTree(t)
+
+	port, err := FindSerialPort(SerialMatch{VendorID: "0403", ProductID: "6001"})
+	if err != nil {
+		t.Fatalf("FindSerialPort() error = %v", err)
+	}
+	if want := filepath.Join(devRoot, "serial/by-id/usb-FTDI_FT232R-if00-port0"); port != want {
+		t.Errorf("FindSerialPort() = %q, want %q", port, want)
+	}
+
+	if _, err := FindSerialPort(SerialMatch{VendorID: "1a86"}); err == nil {
+		t.Error("FindSerialPort() expected error for missing device")
+	}
+	if port, err := FindSerialPort(SerialMatch{}); err == nil {
+		t.Errorf("FindSerialPort() with an empty match = %q, want an error", port)
+	}
+}
+
+func TestSerialMatchDefault(t *testing.T) {
+	if got := serialMatch(map[string]string{}); got != DefaultSerialMatch {
+		t.Errorf("serialMatch() without IDs = %+v, want %+v", got, DefaultSerialMatch)
+	}
+	want := SerialMatch{VendorID: "0403"}
+	if got := serialMatch(map[string]string{"vendor_id": "0403"}); got != want {
+		t.Errorf("serialMatch() = %+v, want %+v", got, want)
+	}
+}
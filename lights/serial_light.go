@@ -2,22 +2,52 @@ package lights
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/tarm/serial"
 )
 
+const (
+	// Backoff bounds for reopening a serial port after a write error
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = time.Minute
+)
+
 // SerialLight implements Light interface for serial-based tower lights
 type SerialLight struct {
-	port     string
-	baudRate int
-	conn     *serial.Port
+	port    string
+	profile Profile
+	conn    *serial.Port
+
+	// match is used to find the device again after a replug, when it was
+	// discovered by USB IDs rather than configured by path
+	match *SerialMatch
+	// lamps is the state last requested, reapplied after a reconnect
+	lamps TowerState
+
+	retryDelay time.Duration
+	nextRetry  time.Time
 }
 
-// NewSerialLight creates a new SerialLight instance
+// NewSerialLight creates a new SerialLight instance for the stock tower
+// light protocol
 func NewSerialLight(port string, baudRate int) (*SerialLight, error) {
+	profile := DefaultProfile()
+	profile.Baud = baudRate
+	return NewSerialLightWithProfile(port, profile)
+}
+
+// NewSerialLightWithProfile creates a SerialLight that speaks the protocol
+// described by profile
+func NewSerialLightWithProfile(port string, profile Profile) (*SerialLight, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
 	light := &SerialLight{
-		port:     port,
-		baudRate: baudRate,
+		port:    port,
+		profile: profile,
 	}
 
 	// Test the connection immediately
@@ -30,46 +60,172 @@ func NewSerialLight(port string, baudRate int) (*SerialLight, error) {
 	return light, nil
 }
 
+// DiscoverSerialLight creates a SerialLight on the first serial port whose
+// USB device matches. The port is looked up again on every reconnect, so
+// the light survives the device coming back under a different tty name
+func DiscoverSerialLight(match SerialMatch, profile Profile) (*SerialLight, error) {
+	port, err := FindSerialPort(match)
+	if err != nil {
+		return nil, err
+	}
+
+	light, err := NewSerialLightWithProfile(port, profile)
+	if err != nil {
+		return nil, err
+	}
+	light.match = &match
+	return light, nil
+}
+
+// Port returns the path of the serial port currently in use
+func (l *SerialLight) Port() string {
+	return l.port
+}
+
 func (l *SerialLight) openPort() (*serial.Port, error) {
 	if l.conn != nil {
 		return l.conn, nil
 	}
 
 	c := &serial.Config{
-		Name: l.port,
-		Baud: l.baudRate,
+		Name:     l.port,
+		Baud:     l.profile.Baud,
+		Size:     byte(l.profile.DataBits),
+		Parity:   serial.ParityNone,
+		StopBits: serial.Stop1,
+	}
+	switch strings.ToLower(l.profile.Parity) {
+	case "odd":
+		c.Parity = serial.ParityOdd
+	case "even":
+		c.Parity = serial.ParityEven
 	}
+	if l.profile.StopBits == 2 {
+		c.StopBits = serial.Stop2
+	}
+
 	conn, err := serial.OpenPort(c)
 	if err != nil {
 		return nil, err
 	}
+
+	// Some devices need an init sequence before they accept commands
+	if len(l.profile.Init) > 0 {
+		if err := sendBytes(conn, l.profile.Init); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to send init sequence: %w", err)
+		}
+	}
+
 	l.conn = conn
 	return conn, nil
 }
 
+// write sends command frames, reconnecting first if an earlier write
+// failed. A failed write drops the connection so the next one reopens it
+func (l *SerialLight) write(frames ...[]byte) error {
+	if l.conn == nil {
+		if err := l.reconnect(); err != nil {
+			return err
+		}
+	}
+
+	for _, frame := range frames {
+		if err := sendBytes(l.conn, frame); err != nil {
+			l.disconnect()
+			return err
+		}
+	}
+	return nil
+}
+
+// lampFrames returns the frames that set every lamp the device has to its
+// mode in state, buzzer first
+func (l *SerialLight) lampFrames(state TowerState) ([][]byte, error) {
+	var frames [][]byte
+	for _, lamp := range []Lamp{LampBuzzer, LampRed, LampYellow, LampGreen} {
+		if !l.profile.HasLamp(lamp) {
+			continue
+		}
+		frame, err := l.profile.Frame(lamp, state.Mode(lamp))
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// reconnect reopens the serial port with exponential backoff and reapplies
+// the current lamp state, since a replugged device starts out dark
+func (l *SerialLight) reconnect() error {
+	if wait := time.Until(l.nextRetry); wait > 0 {
+		return fmt.Errorf("serial port %s disconnected, next reconnect attempt in %s", l.port, wait.Round(time.Second))
+	}
+
+	if l.match != nil {
+		if port, err := FindSerialPort(*l.match); err == nil {
+			l.port = port
+		}
+	}
+
+	if _, err := l.openPort(); err != nil {
+		l.backoff()
+		return fmt.Errorf("failed to reopen serial port %s: %w", l.port, err)
+	}
+	l.retryDelay = 0
+
+	frames, err := l.lampFrames(l.lamps)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if err := sendBytes(l.conn, frame); err != nil {
+			l.disconnect()
+			return fmt.Errorf("failed to restore light state: %w", err)
+		}
+	}
+	return nil
+}
+
+func (l *SerialLight) backoff() {
+	if l.retryDelay == 0 {
+		l.retryDelay = reconnectMinDelay
+	} else if l.retryDelay *= 2; l.retryDelay > reconnectMaxDelay {
+		l.retryDelay = reconnectMaxDelay
+	}
+	l.nextRetry = time.Now().Add(l.retryDelay)
+}
+
+func (l *SerialLight) disconnect() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+	l.backoff()
+}
+
 func (l *SerialLight) On(cmd interface{}) error {
 	state, ok := cmd.(StandardState)
 	if !ok {
 		return fmt.Errorf("invalid command type for SerialLight")
 	}
 
-	var cmdByte byte
-	switch state {
-	case StateRed:
-		cmdByte = cmdRedOn
-	case StateYellow:
-		cmdByte = cmdYellowOn
-	case StateGreen:
-		cmdByte = cmdGreenOn
-	default:
-		return fmt.Errorf("unsupported state: %s", state)
+	lamp, err := lampForState(state)
+	if err != nil {
+		return err
+	}
+	frame, err := l.profile.Frame(lamp, ModeOn)
+	if err != nil {
+		return err
 	}
 
 	if err := l.Clear(); err != nil {
 		return fmt.Errorf("failed to clear light state: %w", err)
 	}
 
-	return sendCommand(l.conn, cmdByte)
+	l.lamps = TowerStateFor(state, ModeOn)
+	return l.write(frame)
 }
 
 func (l *SerialLight) Blink(cmd interface{}) error {
@@ -78,35 +234,41 @@ func (l *SerialLight) Blink(cmd interface{}) error {
 		return fmt.Errorf("invalid command type for SerialLight")
 	}
 
-	var cmdByte byte
-	switch state {
-	case StateRed:
-		cmdByte = cmdRedBlink
-	case StateYellow:
-		cmdByte = cmdYellowBlink
-	case StateGreen:
-		cmdByte = cmdGreenBlink
-	default:
-		return fmt.Errorf("unsupported state: %s", state)
+	lamp, err := lampForState(state)
+	if err != nil {
+		return err
+	}
+	frame, err := l.profile.Frame(lamp, ModeBlink)
+	if err != nil {
+		return err
 	}
 
-	return sendCommand(l.conn, cmdByte)
+	l.lamps = l.lamps.With(lamp, ModeBlink)
+	return l.write(frame)
 }
 
 func (l *SerialLight) Clear() error {
-	initialCommands := []byte{
-		cmdBuzzerOff,
-		cmdRedOff,
-		cmdYellowOff,
-		cmdGreenOff,
+	frames, err := l.lampFrames(TowerState{})
+	if err != nil {
+		return err
 	}
+	l.lamps = TowerState{}
+	return l.write(frames...)
+}
 
-	for _, cmd := range initialCommands {
-		if err := sendCommand(l.conn, cmd); err != nil {
-			return err
-		}
+// SetLamp sets a single lamp or the buzzer without clearing the others,
+// so several lamps can be lit at once. Turning off a lamp the device
+// doesn't have is a no-op
+func (l *SerialLight) SetLamp(lamp Lamp, mode LampMode) error {
+	if mode == ModeOff && !l.profile.HasLamp(lamp) {
+		return nil
 	}
-	return nil
+	frame, err := l.profile.Frame(lamp, mode)
+	if err != nil {
+		return err
+	}
+	l.lamps = l.lamps.With(lamp, mode)
+	return l.write(frame)
 }
 
 // Close implements io.Closer interface
@@ -15,6 +15,20 @@ func NewSerialLight(port string, baudRate int) (*SerialLight, error) {
 	return nil, fmt.Errorf(errNoSerialSupport)
 }
 
+// NewSerialLightWithProfile creates a SerialLight for the given protocol
+func NewSerialLightWithProfile(port string, profile Profile) (*SerialLight, error) {
+	return nil, fmt.Errorf(errNoSerialSupport)
+}
+
+// DiscoverSerialLight creates a SerialLight on a discovered serial port
+func DiscoverSerialLight(match SerialMatch, profile Profile) (*SerialLight, error) {
+	return nil, fmt.Errorf(errNoSerialSupport)
+}
+
+func (l *SerialLight) Port() string {
+	return ""
+}
+
 func (l *SerialLight) On(cmd interface{}) error {
 	return fmt.Errorf(errNoSerialSupport)
 }
@@ -27,6 +41,10 @@ func (l *SerialLight) Blink(cmd interface{}) error {
 	return fmt.Errorf(errNoSerialSupport)
 }
 
+func (l *SerialLight) SetLamp(lamp Lamp, mode LampMode) error {
+	return fmt.Errorf(errNoSerialSupport)
+}
+
 func (l *SerialLight) Close() error {
 	return fmt.Errorf(errNoSerialSupport)
 }
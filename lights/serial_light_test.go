@@ -0,0 +1,169 @@
+//go:build linux && !noserial
+// +build linux,!noserial
+
+package lights
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSerialLightDefaultProfile(t *testing.T) {
+	pty := newPTYHarness(t)
+	light, err := NewSerialLight(pty.Path, 9600)
+	if err != nil {
+		t.Fatalf("NewSerialLight() error = %v", err)
+	}
+	defer light.Close()
+
+	if err := light.On(StateRed); err != nil {
+		t.Fatalf("On() error = %v", err)
+	}
+	pty.expect([]byte{0x28, 0x21, 0x22, 0x24, 0x11})
+
+	if err := light.Blink(StateYellow); err != nil {
+		t.Fatalf("Blink() error = %v", err)
+	}
+	pty.expect([]byte{0x42})
+
+	if err := (TowerState{Green: ModeOn, Buzzer: ModeBlink}).Apply(light); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	pty.expect([]byte{0x21, 0x22, 0x14, 0x48})
+}
+
+func TestSerialLightRelayProfile(t *testing.T) {
+	profile, err := LoadProfile("lcus4")
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+
+	pty := newPTYHarness(t)
+	light, err := NewSerialLightWithProfile(pty.Path, profile)
+	if err != nil {
+		t.Fatalf("NewSerialLightWithProfile() error = %v", err)
+	}
+	defer light.Close()
+
+	if err := light.SetLamp(LampYellow, ModeOn); err != nil {
+		t.Fatalf("SetLamp() error = %v", err)
+	}
+	pty.expect([]byte{0xA0, 0x02, 0x01, 0xA3})
+
+	// Relays can't blink in hardware
+	if err := light.SetLamp(LampRed, ModeBlink); err == nil {
+		t.Error("SetLamp(blink) expected error for relay profile")
+	}
+	pty.expectNothing()
+}
+
+func TestSerialDriverBlinksRelaysInSoftware(t *testing.T) {
+	pty := newPTYHarness(t)
+	light, closeLight, err := openSerialDriver(map[string]string{"port": pty.Path, "profile": "lcus4"})
+	if err != nil {
+		t.Fatalf("openSerialDriver() error = %v", err)
+	}
+	defer closeLight()
+	if _, ok := light.(*Animator); !ok {
+		t.Fatalf("openSerialDriver() = %T, want an Animator for a profile without blink commands", light)
+	}
+
+	// A blinking lamp starts out switched on
+	if err := light.(TowerLight).SetLamp(LampRed, ModeBlink); err != nil {
+		t.Fatalf("SetLamp(blink) error = %v", err)
+	}
+	pty.expect([]byte{0xA0, 0x01, 0x01, 0xA2})
+
+	if light, closeLight, err := openSerialDriver(map[string]string{"port": pty.Path}); err != nil {
+		t.Fatalf("openSerialDriver() error = %v", err)
+	} else {
+		defer closeLight()
+		if _, ok := light.(*SerialLight); !ok {
+			t.Errorf("openSerialDriver() = %T, want the SerialLight of a tower that blinks in hardware", light)
+		}
+	}
+}
+
+func TestSerialLightCustomProfile(t *testing.T) {
+	config := `{
+		"name": "framed",
+		"baud": 19200,
+		"parity": "even",
+		"init": "55 AA",
+		"prefix": [2],
+		"suffix": "0x03",
+		"checksum": "xor8",
+		"lamps": {
+			"red": {"on": "52 31", "off": "52 30", "blink": "52 32"},
+			"green": {"on": "47 31", "off": "47 30"}
+		}
+	}`
+	path := filepath.Join(t.TempDir(), "framed.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+
+	pty := newPTYHarness(t)
+	light, err := NewSerialLightWithProfile(pty.Path, profile)
+	if err != nil {
+		t.Fatalf("NewSerialLightWithProfile() error = %v", err)
+	}
+	defer light.Close()
+	pty.expect([]byte{0x55, 0xAA})
+
+	// Clear only touches the lamps the device has
+	if err := light.Clear(); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	pty.expect([]byte{
+		0x02, 0x52, 0x30, 0x02 ^ 0x52 ^ 0x30, 0x03,
+		0x02, 0x47, 0x30, 0x02 ^ 0x47 ^ 0x30, 0x03,
+	})
+
+	if err := light.Blink(StateRed); err != nil {
+		t.Fatalf("Blink() error = %v", err)
+	}
+	pty.expect([]byte{0x02, 0x52, 0x32, 0x02 ^ 0x52 ^ 0x32, 0x03})
+}
+
+func TestSerialLightReconnect(t *testing.T) {
+	pty := newPTYHarness(t)
+	light, err := NewSerialLight(pty.Path, 9600)
+	if err != nil {
+		t.Fatalf("NewSerialLight() error = %v", err)
+	}
+	defer light.Close()
+
+	if err := light.SetLamp(LampRed, ModeOn); err != nil {
+		t.Fatalf("SetLamp() error = %v", err)
+	}
+	pty.expect([]byte{0x11})
+
+	// Simulate a dropped connection; the next write reopens the port and
+	// restores the state before sending the new command
+	light.conn.Close()
+	light.conn = nil
+	if err := light.SetLamp(LampYellow, ModeBlink); err != nil {
+		t.Fatalf("SetLamp() after disconnect error = %v", err)
+	}
+	pty.expect([]byte{0x28, 0x11, 0x42, 0x24, 0x42})
+}
+
+func TestHexBytesUnmarshal(t *testing.T) {
+	var b HexBytes
+	if err := json.Unmarshal([]byte(`"0xA0, 01 ff"`), &b); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if b.String() != "A0 01 FF" {
+		t.Errorf("Unmarshal() = %s, want A0 01 FF", b)
+	}
+	if err := json.Unmarshal([]byte(`"zz"`), &b); err == nil {
+		t.Error("Unmarshal() expected error for invalid hex")
+	}
+}
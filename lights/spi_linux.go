@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package lights
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// spiWriteMaxSpeedIoctl is SPI_IOC_WR_MAX_SPEED_HZ, _IOW('k', 4, __u32)
+const spiWriteMaxSpeedIoctl = 0x40046B04
+
+// setSPISpeed sets the clock of a spidev device in Hz
+func setSPISpeed(device *os.File, hz int) error {
+	return unix.IoctlSetPointerInt(int(device.Fd()), spiWriteMaxSpeedIoctl, hz)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package lights
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// setSPISpeed fails since spidev devices only exist on Linux
+func setSPISpeed(device *os.File, hz int) error {
+	return fmt.Errorf("spidev not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,55 @@
+package lights
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SPIStrip drives a WS2812 strip wired to the MOSI pin of a spidev device,
+// e.g. /dev/spidev0.0 on a Raspberry Pi
+type SPIStrip struct {
+	mu     sync.Mutex
+	device *os.File
+	pixels int
+}
+
+// NewSPIStrip opens a spidev device for a strip of n pixels
+func NewSPIStrip(path string, n int) (*SPIStrip, error) {
+	if err := validatePixelCount(n); err != nil {
+		return nil, err
+	}
+	device, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SPI device: %w", err)
+	}
+	if err := setSPISpeed(device, ws2812SPISpeed); err != nil {
+		device.Close()
+		return nil, fmt.Errorf("failed to set SPI speed: %w", err)
+	}
+	return &SPIStrip{device: device, pixels: n}, nil
+}
+
+// Len returns the number of pixels
+func (s *SPIStrip) Len() int {
+	return s.pixels
+}
+
+// Show writes the colors to the strip
+func (s *SPIStrip) Show(colors []RGB) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.device.Write(encodeWS2812(padPixels(colors, s.pixels))); err != nil {
+		return fmt.Errorf("failed to write to SPI device: %w", err)
+	}
+	return nil
+}
+
+// Close switches the strip off and closes the device
+func (s *SPIStrip) Close() error {
+	showErr := s.Show(nil)
+	if err := s.device.Close(); err != nil {
+		return err
+	}
+	return showErr
+}
@@ -0,0 +1,151 @@
+package lights
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Strip is an addressable LED strip where every pixel gets its own color
+type Strip interface {
+	// Len returns the number of pixels
+	Len() int
+	// Show displays the given colors, pixel 0 first. Pixels past the end
+	// of colors are switched off
+	Show(colors []RGB) error
+	Close() error
+}
+
+// padPixels returns exactly n colors, switching off missing pixels and
+// dropping colors past the end of the strip
+func padPixels(colors []RGB, n int) []RGB {
+	out := make([]RGB, n)
+	copy(out, colors)
+	return out
+}
+
+// VirtualStrip is an in-memory strip for tests and dry runs
+type VirtualStrip struct {
+	mu     sync.Mutex
+	pixels []RGB
+	frames int
+}
+
+// NewVirtualStrip creates a VirtualStrip with n pixels, all off
+func NewVirtualStrip(n int) *VirtualStrip {
+	return &VirtualStrip{pixels: make([]RGB, n)}
+}
+
+// Len returns the number of pixels
+func (s *VirtualStrip) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pixels)
+}
+
+// Show records the colors
+func (s *VirtualStrip) Show(colors []RGB) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pixels = padPixels(colors, len(s.pixels))
+	s.frames++
+	return nil
+}
+
+// Close does nothing
+func (s *VirtualStrip) Close() error {
+	return nil
+}
+
+// Pixels returns the colors last shown
+func (s *VirtualStrip) Pixels() []RGB {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RGB(nil), s.pixels...)
+}
+
+// Frames returns how many times Show was called
+func (s *VirtualStrip) Frames() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frames
+}
+
+// String renders one character per pixel: R, Y and G for the state colors
+// at full brightness, lowercase when dimmed, '.' when off and '?' for any
+// other color
+func (s *VirtualStrip) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b strings.Builder
+	for _, pixel := range s.pixels {
+		b.WriteByte(pixelRune(pixel))
+	}
+	return b.String()
+}
+
+func pixelRune(pixel RGB) byte {
+	if pixel == (RGB{}) {
+		return '.'
+	}
+	for _, c := range []struct {
+		state StandardState
+		r     byte
+	}{{StateRed, 'R'}, {StateYellow, 'Y'}, {StateGreen, 'G'}} {
+		color := stateColors[c.state]
+		if pixel == color {
+			return c.r
+		}
+		if isDimmed(pixel, color) {
+			return c.r + 'a' - 'A'
+		}
+	}
+	return '?'
+}
+
+// isDimmed reports whether pixel is a darker shade of color
+func isDimmed(pixel, color RGB) bool {
+	channels := [][2]uint8{{pixel.R, color.R}, {pixel.G, color.G}, {pixel.B, color.B}}
+	for _, ch := range channels {
+		if (ch[1] == 0) != (ch[0] == 0) || ch[0] > ch[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// ws2812 encoding over SPI: at 2.4MHz every data bit takes three SPI bits,
+// 110 for a one and 100 for a zero, which matches the WS2812 timing
+const (
+	ws2812SPISpeed = 2400000
+	// ws2812ResetBytes keeps the line low for well over the 50µs latch time
+	ws2812ResetBytes = 40
+)
+
+// encodeWS2812 encodes colors as an SPI bit stream for WS2812 LEDs, which
+// expect green, red, blue order, followed by the reset gap
+func encodeWS2812(colors []RGB) []byte {
+	out := make([]byte, 0, len(colors)*9+ws2812ResetBytes)
+	for _, c := range colors {
+		for _, value := range []uint8{c.G, c.R, c.B} {
+			var bits uint32
+			for i := 7; i >= 0; i-- {
+				bits <<= 3
+				if value&(1<<uint(i)) != 0 {
+					bits |= 0x6 // 110
+				} else {
+					bits |= 0x4 // 100
+				}
+			}
+			out = append(out, byte(bits>>16), byte(bits>>8), byte(bits))
+		}
+	}
+	return append(out, make([]byte, ws2812ResetBytes)...)
+}
+
+func validatePixelCount(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid pixel count: %d", n)
+	}
+	return nil
+}
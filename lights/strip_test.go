@@ -0,0 +1,75 @@
+package lights
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeWS2812(t *testing.T) {
+	out := encodeWS2812([]RGB{{R: 0x80, G: 0xFF, B: 0x00}})
+	if len(out) != 9+ws2812ResetBytes {
+		t.Fatalf("len = %d, want %d", len(out), 9+ws2812ResetBytes)
+	}
+	want := []byte{
+		0xDB, 0x6D, 0xB6, // green 0xFF: 110 x8
+		0xD2, 0x49, 0x24, // red 0x80: 110 then 100 x7
+		0x92, 0x49, 0x24, // blue 0x00: 100 x8
+	}
+	if !reflect.DeepEqual(out[:9], want) {
+		t.Errorf("encoded = % X, want % X", out[:9], want)
+	}
+	for _, b := range out[9:] {
+		if b != 0 {
+			t.Fatalf("reset gap contains % X", out[9:])
+		}
+	}
+}
+
+func TestVirtualStrip(t *testing.T) {
+	strip := NewVirtualStrip(5)
+	red, _ := ColorFor(StateRed)
+	green, _ := ColorFor(StateGreen)
+	colors := []RGB{red, green.Scale(0.2), {R: 1, G: 2, B: 3}, {}, red, green}
+	if err := strip.Show(colors); err != nil {
+		t.Fatal(err)
+	}
+	if got := strip.String(); got != "Rg?.R" {
+		t.Errorf("String() = %q, want %q", got, "Rg?.R")
+	}
+	strip.Show(colors[:1])
+	if got := strip.String(); got != "R...." {
+		t.Errorf("String() = %q after shorter frame, want %q", got, "R....")
+	}
+	if strip.Frames() != 2 {
+		t.Errorf("Frames() = %d, want 2", strip.Frames())
+	}
+}
+
+func TestWLEDStrip(t *testing.T) {
+	server, requests := newStandIn(t, `{"success":true}`)
+	strip, err := NewWLEDStrip(WLEDConfig{URL: server.URL, Segment: 1, Client: server.Client()}, 3)
+	if err != nil {
+		t.Fatalf("NewWLEDStrip() error = %v", err)
+	}
+	red, _ := ColorFor(StateRed)
+	if err := strip.Show([]RGB{red}); err != nil {
+		t.Fatalf("Show() error = %v", err)
+	}
+
+	want := decode(t, map[string]interface{}{
+		"on":  true,
+		"bri": 255,
+		"seg": []map[string]interface{}{{
+			"id": 1,
+			"on": true,
+			"fx": 0,
+			"i":  []string{"FF0000", "000000", "000000"},
+		}},
+	})
+	if len(*requests) != 1 || (*requests)[0].Path != "/json/state" {
+		t.Fatalf("requests = %+v", *requests)
+	}
+	if got := (*requests)[0].Body; !reflect.DeepEqual(got, want) {
+		t.Errorf("body = %v, want %v", got, want)
+	}
+}
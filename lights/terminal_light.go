@@ -0,0 +1,166 @@
+package lights
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ANSI escape sequences used to draw the tower
+const (
+	ansiReset     = "\x1b[0m"
+	ansiDim       = "\x1b[2m"
+	ansiClearLine = "\r\x1b[2K"
+)
+
+var lampANSI = map[Lamp]string{
+	LampRed:    "\x1b[1;31m",
+	LampYellow: "\x1b[1;33m",
+	LampGreen:  "\x1b[1;32m",
+	LampBuzzer: "\x1b[1;35m",
+}
+
+// TerminalLight draws a tower light with ANSI colors, for running the
+// checker without hardware. Every state change starts a new line; blinking
+// lamps are redrawn in place
+type TerminalLight struct {
+	out    io.Writer
+	period time.Duration
+
+	mu    sync.Mutex
+	lamps TowerState
+	lit   bool // blinking lamps are lit in this phase
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewTerminalLight creates a TerminalLight writing to out. Blinking lamps
+// toggle every half period
+func NewTerminalLight(out io.Writer, blinkPeriod time.Duration) *TerminalLight {
+	l := &TerminalLight{
+		out:    out,
+		period: blinkPeriod,
+		lit:    true,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// On shows a steady state, a StandardState or a whole TowerState
+func (l *TerminalLight) On(cmd interface{}) error {
+	switch state := cmd.(type) {
+	case StandardState:
+		return l.SetState(TowerStateFor(state, ModeOn))
+	case TowerState:
+		return l.SetState(state)
+	}
+	return fmt.Errorf("invalid command type for TerminalLight")
+}
+
+// Blink makes the lamp of a state blink
+func (l *TerminalLight) Blink(cmd interface{}) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for TerminalLight")
+	}
+	lamp, err := lampForState(state)
+	if err != nil {
+		return err
+	}
+	return l.SetLamp(lamp, ModeBlink)
+}
+
+// Clear switches every lamp off
+func (l *TerminalLight) Clear() error {
+	return l.SetState(TowerState{})
+}
+
+// SetLamp sets a single lamp
+func (l *TerminalLight) SetLamp(lamp Lamp, mode LampMode) error {
+	return l.update(withLamp(lamp, mode))
+}
+
+// SetState implements StateLight, drawing a whole state as one line
+func (l *TerminalLight) SetState(lamps TowerState) error {
+	return l.update(replaceLamps(lamps))
+}
+
+// Close stops blinking and ends the current line
+func (l *TerminalLight) Close() error {
+	close(l.stop)
+	<-l.done
+	_, err := io.WriteString(l.out, "\n")
+	return err
+}
+
+// update changes the lamps and draws them when they changed, in one
+// critical section so that concurrent changes aren't lost
+func (l *TerminalLight) update(change func(TowerState) TowerState) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lamps := change(l.lamps)
+	if lamps == l.lamps {
+		return nil
+	}
+	l.lamps = lamps
+	l.lit = true
+	_, err := io.WriteString(l.out, "\n"+renderTower(lamps, true))
+	return err
+}
+
+func (l *TerminalLight) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.period / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.lamps.blinking() {
+				l.lit = !l.lit
+				io.WriteString(l.out, ansiClearLine+renderTower(l.lamps, l.lit))
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// blinking reports whether any lamp blinks
+func (s TowerState) blinking() bool {
+	for _, lamp := range Lamps {
+		if s.Mode(lamp) == ModeBlink {
+			return true
+		}
+	}
+	return false
+}
+
+// renderTower draws the lamps on one line. Blinking lamps are drawn off
+// unless lit is set
+func renderTower(lamps TowerState, lit bool) string {
+	var b strings.Builder
+	b.WriteString("tower:")
+	for _, lamp := range Lamps {
+		mode := lamps.Mode(lamp)
+		on := mode == ModeOn || (mode == ModeBlink && lit)
+		glyph := "●"
+		if lamp == LampBuzzer {
+			glyph = "♪"
+		}
+		if on {
+			fmt.Fprintf(&b, " %s%s %s%s", lampANSI[lamp], glyph, lamp, ansiReset)
+		} else {
+			fmt.Fprintf(&b, " %s○ %s%s", ansiDim, lamp, ansiReset)
+		}
+		if mode == ModeBlink {
+			b.WriteString("*")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package lights
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the blinking goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRenderTower(t *testing.T) {
+	lamps := TowerState{Red: ModeOn, Green: ModeBlink, Buzzer: ModeOn}
+	lit := renderTower(lamps, true)
+	for _, want := range []string{"\x1b[1;31m● red", "\x1b[2m○ yellow", "\x1b[1;32m● green\x1b[0m*", "\x1b[1;35m♪ buzzer"} {
+		if !strings.Contains(lit, want) {
+			t.Errorf("renderTower() = %q, missing %q", lit, want)
+		}
+	}
+	if dark := renderTower(lamps, false); !strings.Contains(dark, "\x1b[2m○ green\x1b[0m*") {
+		t.Errorf("renderTower() in dark phase = %q, want green drawn off", dark)
+	}
+}
+
+func TestTerminalLight(t *testing.T) {
+	var out syncBuffer
+	l := NewTerminalLight(&out, 20*time.Millisecond)
+	l.On(StateRed)
+	l.On(StateRed)
+	if got := strings.Count(out.String(), "\n"); got != 1 {
+		t.Errorf("repeated state drew %d lines, want 1", got)
+	}
+
+	l.Blink(StateYellow)
+	time.Sleep(50 * time.Millisecond)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), ansiClearLine+"tower:") {
+		t.Errorf("blinking lamp wasn't redrawn in place: %q", out.String())
+	}
+}
+
+func TestRecorderLight(t *testing.T) {
+	var out bytes.Buffer
+	l := NewRecorderLight(&out)
+	now := time.Date(2025, 1, 9, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	l.On(StateGreen)
+	l.Blink(StateRed)
+	l.SetLamp(LampBuzzer, ModeOn)
+	l.Clear()
+
+	want := "2025-01-09T12:00:01Z on green -> green=on\n" +
+		"2025-01-09T12:00:02Z blink red -> red=blink,green=on\n" +
+		"2025-01-09T12:00:03Z set buzzer=on -> red=blink,green=on,buzzer=on\n" +
+		"2025-01-09T12:00:04Z clear -> off\n"
+	if out.String() != want {
+		t.Errorf("log =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestTerminalLightDrawsStateOnce(t *testing.T) {
+	var out syncBuffer
+	l := NewTerminalLight(&out, time.Hour)
+	c := newController(l, testCoalesce, testResync, newTimerClock(testCoalesce, testResync, retryInterval).after)
+	(TowerState{Red: ModeOn, Yellow: ModeOn, Buzzer: ModeOn}).Apply(l)
+	c.Set(TowerState{Green: ModeOn, Buzzer: ModeOn})
+	c.Close()
+	l.Close()
+
+	// One line per state, not per lamp, plus the final newline
+	if got := strings.Count(out.String(), "\n"); got != 3 {
+		t.Errorf("drew %d lines, want 3: %q", got, out.String())
+	}
+}
@@ -0,0 +1,252 @@
+package lights
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lamp identifies a single lamp (or the buzzer) on a tower light
+type Lamp int
+
+const (
+	LampRed Lamp = iota
+	LampYellow
+	LampGreen
+	LampBuzzer
+)
+
+// Lamps lists every lamp of a tower light in severity order, buzzer last
+var Lamps = []Lamp{LampRed, LampYellow, LampGreen, LampBuzzer}
+
+func (l Lamp) String() string {
+	switch l {
+	case LampRed:
+		return "red"
+	case LampYellow:
+		return "yellow"
+	case LampGreen:
+		return "green"
+	case LampBuzzer:
+		return "buzzer"
+	}
+	return fmt.Sprintf("lamp(%d)", int(l))
+}
+
+// LampMode is the output mode of a single lamp
+type LampMode int
+
+const (
+	ModeOff LampMode = iota
+	ModeOn
+	ModeBlink
+)
+
+func (m LampMode) String() string {
+	switch m {
+	case ModeOff:
+		return "off"
+	case ModeOn:
+		return "on"
+	case ModeBlink:
+		return "blink"
+	}
+	return fmt.Sprintf("mode(%d)", int(m))
+}
+
+// TowerLight is implemented by lights whose lamps can be driven independently
+type TowerLight interface {
+	Light
+	// SetLamp sets a single lamp without touching the others
+	SetLamp(lamp Lamp, mode LampMode) error
+}
+
+// StateLight is implemented by tower lights that show a whole TowerState
+// in one update, e.g. drawing it once rather than once per lamp
+type StateLight interface {
+	TowerLight
+	SetState(state TowerState) error
+}
+
+// TowerState implements State by setting every lamp and the buzzer of a
+// tower light in one update, e.g. steady red plus blinking yellow
+type TowerState struct {
+	Red    LampMode
+	Yellow LampMode
+	Green  LampMode
+	Buzzer LampMode
+}
+
+// TowerStateFor returns the tower state that shows a single standard color
+func TowerStateFor(state StandardState, mode LampMode) TowerState {
+	var s TowerState
+	switch state {
+	case StateRed:
+		s.Red = mode
+	case StateYellow:
+		s.Yellow = mode
+	case StateGreen:
+		s.Green = mode
+	}
+	return s
+}
+
+// lampForState returns the lamp that shows a standard color
+func lampForState(state StandardState) (Lamp, error) {
+	switch state {
+	case StateRed:
+		return LampRed, nil
+	case StateYellow:
+		return LampYellow, nil
+	case StateGreen:
+		return LampGreen, nil
+	}
+	return 0, fmt.Errorf("unsupported state: %s", state)
+}
+
+// Mode returns the mode of the given lamp
+func (s TowerState) Mode(lamp Lamp) LampMode {
+	switch lamp {
+	case LampRed:
+		return s.Red
+	case LampYellow:
+		return s.Yellow
+	case LampGreen:
+		return s.Green
+	case LampBuzzer:
+		return s.Buzzer
+	}
+	return ModeOff
+}
+
+// With returns a copy of the state with the given lamp set to mode
+func (s TowerState) With(lamp Lamp, mode LampMode) TowerState {
+	switch lamp {
+	case LampRed:
+		s.Red = mode
+	case LampYellow:
+		s.Yellow = mode
+	case LampGreen:
+		s.Green = mode
+	case LampBuzzer:
+		s.Buzzer = mode
+	}
+	return s
+}
+
+// replaceLamps returns a change of a whole state, for lights that update
+// their lamps under a lock
+func replaceLamps(lamps TowerState) func(TowerState) TowerState {
+	return func(TowerState) TowerState { return lamps }
+}
+
+// withLamp returns a change of a single lamp
+func withLamp(lamp Lamp, mode LampMode) func(TowerState) TowerState {
+	return func(lamps TowerState) TowerState { return lamps.With(lamp, mode) }
+}
+
+// IsOff reports whether every lamp and the buzzer are off
+func (s TowerState) IsOff() bool {
+	return s == TowerState{}
+}
+
+// Primary returns the most severe lit color and its mode. Lights that can
+// only show one color at a time display this
+func (s TowerState) Primary() (StandardState, LampMode) {
+	switch {
+	case s.Red != ModeOff:
+		return StateRed, s.Red
+	case s.Yellow != ModeOff:
+		return StateYellow, s.Yellow
+	case s.Green != ModeOff:
+		return StateGreen, s.Green
+	}
+	return StateOff, ModeOff
+}
+
+// String renders the state as e.g. "red=on,yellow=blink"
+func (s TowerState) String() string {
+	var parts []string
+	for _, lamp := range Lamps {
+		if mode := s.Mode(lamp); mode != ModeOff {
+			parts = append(parts, lamp.String()+"="+mode.String())
+		}
+	}
+	if len(parts) == 0 {
+		return string(StateOff)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Apply sets the whole state at once on a StateLight and each lamp
+// independently on other tower lights. Other lights show only the most
+// severe lit color
+func (s TowerState) Apply(light Light) error {
+	if stateLight, ok := light.(StateLight); ok {
+		return stateLight.SetState(s)
+	}
+	if tower, ok := light.(TowerLight); ok {
+		for _, lamp := range Lamps {
+			if err := tower.SetLamp(lamp, s.Mode(lamp)); err != nil {
+				return fmt.Errorf("failed to set %s lamp: %w", lamp, err)
+			}
+		}
+		return nil
+	}
+
+	color, mode := s.Primary()
+	switch mode {
+	case ModeOn:
+		return light.On(color)
+	case ModeBlink:
+		return light.Blink(color)
+	}
+	return light.Clear()
+}
+
+// ParseTowerState parses a state written as a color ("red"), a blinking
+// color ("blink-red") or lamp modes as printed by String
+// ("red=on,yellow=blink,buzzer=on"). "off" switches everything off
+func ParseTowerState(s string) (TowerState, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == string(StateOff) {
+		return TowerState{}, nil
+	}
+	if color := strings.TrimPrefix(s, "blink-"); color != s {
+		lamp, err := lampForState(StandardState(color))
+		if err != nil {
+			return TowerState{}, err
+		}
+		return TowerState{}.With(lamp, ModeBlink), nil
+	}
+	if !strings.Contains(s, "=") {
+		lamp, err := lampForState(StandardState(s))
+		if err != nil {
+			return TowerState{}, err
+		}
+		return TowerState{}.With(lamp, ModeOn), nil
+	}
+
+	var state TowerState
+	for _, part := range strings.Split(s, ",") {
+		name, modeName, _ := strings.Cut(strings.TrimSpace(part), "=")
+		lamp, ok := lampByName(name)
+		if !ok {
+			return TowerState{}, fmt.Errorf("unknown lamp %q", name)
+		}
+		mode, err := parseLampMode(modeName)
+		if err != nil {
+			return TowerState{}, err
+		}
+		state = state.With(lamp, mode)
+	}
+	return state, nil
+}
+
+func parseLampMode(s string) (LampMode, error) {
+	for _, mode := range []LampMode{ModeOff, ModeOn, ModeBlink} {
+		if mode.String() == s {
+			return mode, nil
+		}
+	}
+	return ModeOff, fmt.Errorf("unknown lamp mode %q", s)
+}
@@ -0,0 +1,101 @@
+package lights
+
+import (
+	"reflect"
+	"testing"
+)
+
+// recordingLight records every call made through the Light interface
+type recordingLight struct {
+	calls []string
+}
+
+func (l *recordingLight) On(cmd interface{}) error {
+	l.calls = append(l.calls, "on:"+string(cmd.(StandardState)))
+	return nil
+}
+
+func (l *recordingLight) Blink(cmd interface{}) error {
+	l.calls = append(l.calls, "blink:"+string(cmd.(StandardState)))
+	return nil
+}
+
+func (l *recordingLight) Clear() error {
+	l.calls = append(l.calls, "clear")
+	return nil
+}
+
+// recordingTower additionally records per-lamp updates
+type recordingTower struct {
+	recordingLight
+}
+
+func (l *recordingTower) SetLamp(lamp Lamp, mode LampMode) error {
+	l.calls = append(l.calls, lamp.String()+"="+mode.String())
+	return nil
+}
+
+func TestTowerStateApply(t *testing.T) {
+	state := TowerState{Red: ModeOn, Yellow: ModeBlink}
+
+	tower := &recordingTower{}
+	if err := state.Apply(tower); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"red=on", "yellow=blink", "green=off", "buzzer=off"}
+	if !reflect.DeepEqual(tower.calls, want) {
+		t.Errorf("tower calls = %v, want %v", tower.calls, want)
+	}
+
+	single := &recordingLight{}
+	if err := state.Apply(single); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"on:red"}; !reflect.DeepEqual(single.calls, want) {
+		t.Errorf("single-color calls = %v, want %v", single.calls, want)
+	}
+
+	single = &recordingLight{}
+	if err := (TowerState{}).Apply(single); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if want := []string{"clear"}; !reflect.DeepEqual(single.calls, want) {
+		t.Errorf("off calls = %v, want %v", single.calls, want)
+	}
+}
+
+func TestTowerStateString(t *testing.T) {
+	tests := []struct {
+		state TowerState
+		want  string
+	}{
+		{TowerState{}, "off"},
+		{TowerStateFor(StateGreen, ModeOn), "green=on"},
+		{TowerState{Red: ModeOn, Yellow: ModeBlink, Buzzer: ModeOn}, "red=on,yellow=blink,buzzer=on"},
+	}
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParseTowerState(t *testing.T) {
+	tests := map[string]TowerState{
+		"red":                           {Red: ModeOn},
+		" Blink-Yellow ":                {Yellow: ModeBlink},
+		"off":                           {},
+		"red=on,yellow=blink,buzzer=on": {Red: ModeOn, Yellow: ModeBlink, Buzzer: ModeOn},
+	}
+	for input, want := range tests {
+		got, err := ParseTowerState(input)
+		if err != nil || got != want {
+			t.Errorf("ParseTowerState(%q) = %v, %v, want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"purple", "blink-off", "red=dim", "siren=on"} {
+		if _, err := ParseTowerState(input); err == nil {
+			t.Errorf("ParseTowerState(%q) accepted an invalid state", input)
+		}
+	}
+}
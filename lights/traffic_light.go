@@ -126,3 +126,24 @@ func (l *TrafficLight) Clear() error {
 	}
 	return nil
 }
+
+// SetLamp sets a single lamp or the buzzer without clearing the others,
+// so several lamps can be lit at once
+func (l *TrafficLight) SetLamp(lamp Lamp, mode LampMode) error {
+	frame, err := DefaultProfile().Frame(lamp, mode)
+	if err != nil {
+		return err
+	}
+
+	s, err := l.openPort()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.Close(); err != nil {
+			log.Printf("Error closing serial port: %s", err.Error())
+		}
+	}()
+
+	return sendBytes(s, frame)
+}
@@ -14,3 +14,11 @@ func sendCommand(port *serial.Port, cmd byte) error {
 	}
 	return nil
 }
+
+// sendBytes writes a complete command frame to the serial port
+func sendBytes(port *serial.Port, frame []byte) error {
+	if _, err := port.Write(frame); err != nil {
+		return fmt.Errorf("failed to send command: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package lights
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// WLED effect IDs, see https://kno.wled.ge/features/effects/
+	wledEffectSolid = 0
+	wledEffectBlink = 1
+
+	wledBlinkSpeed = 128
+)
+
+// WLEDConfig describes a WLED controller
+type WLEDConfig struct {
+	// URL is the base URL of the controller, e.g. http://wled.local
+	URL string
+	// Segment is the segment to drive; negative drives the whole strip
+	Segment int
+	// Start and Stop, when Stop is set, resize the segment to the pixels
+	// Start up to but not including Stop, so one strip can show several
+	// zones
+	Start, Stop int
+	// Client is used for requests; a client with a timeout when nil
+	Client *http.Client
+}
+
+// WLEDLight implements Light interface over the WLED JSON API
+type WLEDLight struct {
+	url     string
+	segment int
+	start   int
+	stop    int
+	client  *http.Client
+}
+
+// NewWLEDLight creates a WLEDLight. No request is made until the first
+// state change
+func NewWLEDLight(config WLEDConfig) (*WLEDLight, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("WLED URL is required")
+	}
+	if config.Stop > 0 && (config.Segment < 0 || config.Start < 0 || config.Start >= config.Stop) {
+		return nil, fmt.Errorf("WLED pixel range %d-%d needs a segment and start before stop", config.Start, config.Stop)
+	}
+	return &WLEDLight{
+		url:     strings.TrimRight(config.URL, "/") + "/json/state",
+		segment: config.Segment,
+		start:   config.Start,
+		stop:    config.Stop,
+		client:  defaultHTTPClient(config.Client),
+	}, nil
+}
+
+// On shows a steady color
+func (l *WLEDLight) On(cmd interface{}) error {
+	return l.show(cmd, wledEffectSolid)
+}
+
+// Blink uses the WLED blink effect
+func (l *WLEDLight) Blink(cmd interface{}) error {
+	return l.show(cmd, wledEffectBlink)
+}
+
+// Clear switches the segment, or the whole strip, off
+func (l *WLEDLight) Clear() error {
+	if l.segment < 0 {
+		return l.send(map[string]interface{}{"on": false})
+	}
+	return l.send(map[string]interface{}{
+		"seg": []map[string]interface{}{{"id": l.segment, "on": false}},
+	})
+}
+
+func (l *WLEDLight) show(cmd interface{}, effect int) error {
+	state, ok := cmd.(StandardState)
+	if !ok {
+		return fmt.Errorf("invalid command type for WLEDLight")
+	}
+	color, err := ColorFor(state)
+	if err != nil {
+		return err
+	}
+
+	segment := map[string]interface{}{
+		"on":  true,
+		"bri": 255,
+		"col": [][]int{{int(color.R), int(color.G), int(color.B)}},
+		"fx":  effect,
+		"sx":  wledBlinkSpeed,
+	}
+	if l.segment >= 0 {
+		segment["id"] = l.segment
+	}
+	if l.stop > 0 {
+		segment["start"] = l.start
+		segment["stop"] = l.stop
+	}
+	return l.send(map[string]interface{}{
+		"on":  true,
+		"bri": 255,
+		"seg": []map[string]interface{}{segment},
+	})
+}
+
+func (l *WLEDLight) send(state map[string]interface{}) error {
+	if _, err := sendJSON(l.client, http.MethodPost, l.url, nil, state); err != nil {
+		return fmt.Errorf("WLED request failed: %w", err)
+	}
+	return nil
+}
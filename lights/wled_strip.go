@@ -0,0 +1,70 @@
+package lights
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WLEDStrip sets the pixels of a WLED controller individually
+type WLEDStrip struct {
+	url     string
+	segment int
+	pixels  int
+	client  *http.Client
+}
+
+// NewWLEDStrip creates a WLEDStrip of n pixels. Pixels are addressed from
+// the start of config.Segment, or of the strip when it's negative; the
+// pixel range of config is ignored
+func NewWLEDStrip(config WLEDConfig, n int) (*WLEDStrip, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("WLED URL is required")
+	}
+	if err := validatePixelCount(n); err != nil {
+		return nil, err
+	}
+	return &WLEDStrip{
+		url:     strings.TrimRight(config.URL, "/") + "/json/state",
+		segment: config.Segment,
+		pixels:  n,
+		client:  defaultHTTPClient(config.Client),
+	}, nil
+}
+
+// Len returns the number of pixels
+func (s *WLEDStrip) Len() int {
+	return s.pixels
+}
+
+// Show sets every pixel with the individual LED control of the JSON API
+func (s *WLEDStrip) Show(colors []RGB) error {
+	pixels := padPixels(colors, s.pixels)
+	individual := make([]string, len(pixels))
+	for i, c := range pixels {
+		individual[i] = c.Hex()
+	}
+
+	segment := map[string]interface{}{
+		"on": true,
+		"fx": wledEffectSolid,
+		"i":  individual,
+	}
+	if s.segment >= 0 {
+		segment["id"] = s.segment
+	}
+	state := map[string]interface{}{
+		"on":  true,
+		"bri": 255,
+		"seg": []map[string]interface{}{segment},
+	}
+	if _, err := sendJSON(s.client, http.MethodPost, s.url, nil, state); err != nil {
+		return fmt.Errorf("WLED request failed: %w", err)
+	}
+	return nil
+}
+
+// Close switches the pixels off
+func (s *WLEDStrip) Close() error {
+	return s.Show(nil)
+}
@@ -4,16 +4,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
+	"my-incident-checker/config"
+	"my-incident-checker/control"
+	"my-incident-checker/display"
 	"my-incident-checker/heartbeat"
 	"my-incident-checker/lights"
 	"my-incident-checker/network"
 	"my-incident-checker/node"
 	"my-incident-checker/notify"
 	"my-incident-checker/poll"
+	"my-incident-checker/systemd"
 	"my-incident-checker/types"
+	"my-incident-checker/wall"
 )
 
 func NewLogger() (*types.Logger, error) {
@@ -43,6 +50,10 @@ func NewLogger() (*types.Logger, error) {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout))
+	}
+
 	logger, err := NewLogger()
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %s", err.Error())
@@ -53,6 +64,16 @@ func main() {
 
 	logger.InfoLog.Printf("Starting Incident Checker")
 
+	cfg, err := config.Load(config.Path())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Every outbound request goes through the shared HTTP client
+	if err := configureHTTP(cfg.HTTP); err != nil {
+		log.Fatal(err)
+	}
+
 	// Check initial connectivity
 	if err := network.CheckConnectivity(); err != nil {
 		logger.WarnLog.Printf("Initial connectivity check failed: %s", err.Error())
@@ -69,74 +90,228 @@ func main() {
 	}
 	fmt.Println("Startup notification sent successfully")
 
-	// Initialize the light with automatic detection
-	light, cleanup, err := initializeLight(logger)
+	// Initialize the configured lights, or detect one automatically
+	light, cleanup, err := initializeLight(logger, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer cleanup()
 
-	fmt.Println("Yellow light on for 2 seconds")
-	light.Blink(lights.StateYellow)
-	time.Sleep(2 * time.Second)
+	zones, closeZones := openZones(cfg, logger)
+	defer closeZones()
+
+	var serviceWall *wall.Wall
+	if cfg.Wall != nil {
+		var closeWall func() error
+		serviceWall, closeWall, err = openWall(cfg.Wall)
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to open service wall: %s", err.Error())
+		} else {
+			logger.InfoLog.Printf("Using %s service wall with %d pixels", cfg.Wall.Driver, cfg.Wall.Pixels)
+			defer func() {
+				if err := closeWall(); err != nil {
+					logger.ErrorLog.Printf("Error closing service wall: %s", err.Error())
+				}
+			}()
+		}
+	}
+
+	// Every subsystem claims the light through the arbiter, which shows
+	// the highest priority claim
+	arbiter := lights.NewArbiter(light)
+	defer arbiter.Close()
+	if err := arbiter.Claim(poll.ClaimSource, lights.PriorityIncident, lights.GreenState{}, 0); err != nil {
+		logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+	}
 
-	fmt.Println("Red light on for 2 seconds")
-	light.Blink(lights.StateRed)
-	time.Sleep(2 * time.Second)
+	selfTest(arbiter)
 
-	fmt.Println("Green light on for 2 seconds")
-	light.Blink(lights.StateGreen)
-	time.Sleep(2 * time.Second)
+	// Status and manual overrides are served to the status and override
+	// commands
+	controlServer := control.NewServer(control.Addr(), arbiter, logger)
+	if err := controlServer.Start(); err != nil {
+		logger.ErrorLog.Printf("%s", err.Error())
+	} else {
+		logger.InfoLog.Printf("Control server listening on %s", control.Addr())
+		defer controlServer.Close()
+	}
 
-	light.Clear()
-	fmt.Println("Lights cleared")
-	light.On(lights.StateGreen)
+	var presenter *display.Presenter
+	if cfg.Display != nil {
+		charDisplay, closeDisplay, err := openDisplay(cfg.Display)
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to open display: %s", err.Error())
+		} else {
+			logger.InfoLog.Printf("Using %s display", cfg.Display.Driver)
+			presenter = display.NewPresenter(charDisplay)
+			presenter.Update(nil)
+			stopDisplay := make(chan struct{})
+			go presenter.Run(display.DefaultScrollInterval, stopDisplay)
+			defer func() {
+				close(stopDisplay)
+				presenter.Close()
+				if err := closeDisplay(); err != nil {
+					logger.ErrorLog.Printf("Error closing display: %s", err.Error())
+				}
+			}()
+		}
+	}
+
+	// Buttons acknowledge and snooze alerts
+	acks := poll.NewAcknowledger(arbiter, logger)
+	closeButtons := startButtons(cfg.Buttons, acks, logger)
+	defer closeButtons()
+
+	fmt.Println("Polling for incidents")
+	startTime := time.Now()
+	poller := &poll.Poller{
+		StartTime: startTime,
+		Light:     light,
+		Logger:    logger,
+		Zones:     zones,
+		Wall:      serviceWall,
+		Arbiter:   arbiter,
+		Acks:      acks,
+		Display:   presenter,
+	}
+	if poller.Auth, err = incidentAuth(cfg.IncidentAPI); err != nil {
+		log.Fatal(err)
+	}
+	if poller.Auth != nil {
+		logger.InfoLog.Printf("Authenticating to the incident API with %s", poller.Auth.Name())
+	}
 
 	// Start heartbeat in a goroutine
 	fmt.Println("Starting heartbeat")
 	logger.InfoLog.Printf("Starting heartbeat...")
-	go heartbeat.Run()
+	interval, err := heartbeatInterval(cfg.Heartbeat)
+	if err != nil {
+		logger.ErrorLog.Printf("Invalid heartbeat interval: %s", err.Error())
+		interval = heartbeat.DefaultInterval
+	}
+	model := healthModel(cfg.Heartbeat, startTime, poller, light, logger)
+	beat := heartbeat.New(heartbeatTargets(cfg.Heartbeat, logger), interval,
+		heartbeatReport(startTime, poller.LastPoll, arbiter), model.Err, logger)
+	threshold := 0
+	if cfg.Heartbeat != nil {
+		threshold = cfg.Heartbeat.FailureThreshold
+	}
+	beat.OnChange(threshold, heartbeatAlerts(cfg.Heartbeat, arbiter, logger))
+	stopHeartbeat := make(chan struct{})
+	defer close(stopHeartbeat)
+	go beat.Run(stopHeartbeat)
 
-	fmt.Println("Polling for incidents")
-	startTime := time.Now()
-	poll.PollIncidents(startTime, light, logger)
+	// The connectivity monitor tells our network being down apart from the
+	// incident API being down
+	stopConnectivity := startConnectivity(cfg, poller, arbiter, logger)
+	defer stopConnectivity()
+
+	// The watchdog shows stale data on the light when the poll loop hangs
+	stopWatchdog := startWatchdog(cfg.Watchdog, poller, arbiter, logger)
+	defer stopWatchdog()
+
+	notifier, err := systemd.NewNotifier()
+	if err != nil {
+		logger.ErrorLog.Printf("%s", err.Error())
+	}
+	defer notifier.Close()
+	stopSystemd := startSystemd(notifier, poller, arbiter, logger)
+	defer stopSystemd()
+
+	// Stop polling on SIGINT and SIGTERM so that everything shuts down
+	// cleanly
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.InfoLog.Printf("Received %s, shutting down", sig)
+		if err := notifier.Stopping(); err != nil {
+			logger.ErrorLog.Printf("%s", err.Error())
+		}
+		poller.Stop()
+	}()
+
+	poller.Run()
 	fmt.Println("Stopped polling for incidents")
 }
 
-func initializeLight(logger *types.Logger) (lights.Light, func(), error) {
+// selfTest blinks every lamp in turn so a broken lamp is noticed at startup
+func selfTest(arbiter *lights.Arbiter) {
+	steps := []struct {
+		name  string
+		state lights.State
+	}{
+		{"Yellow", lights.BlinkingYellowState{}},
+		{"Red", lights.BlinkingRedState{}},
+		{"Green", lights.BlinkingGreenState{}},
+	}
+	for _, step := range steps {
+		fmt.Printf("%s light on for 2 seconds\n", step.name)
+		arbiter.Claim("self-test", lights.PrioritySelfTest, step.state, 0)
+		time.Sleep(2 * time.Second)
+	}
+	arbiter.Release("self-test")
+	fmt.Println("Self-test done")
+}
+
+func initializeLight(logger *types.Logger, cfg *config.Config) (*lights.Controller, func(), error) {
 	var light lights.Light
 	var cleanup func()
 
-	// Try to initialize BLINK1MK3 first
-	if blink1Light, err := lights.NewBlink1Light(); err == nil {
-		fmt.Println("Using BLINK1MK3 light.")
-		logger.InfoLog.Printf("Using BLINK1MK3 light")
-		light = blink1Light
+	if len(cfg.Lights) > 0 {
+		composite, err := openConfiguredLights(unzonedLights(cfg), logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		light = composite
 		cleanup = func() {
-			blink1Light.Close()
+			if err := composite.Close(); err != nil {
+				logger.ErrorLog.Printf("Error closing lights: %s", err.Error())
+			}
 		}
 	} else {
-		// Fall back to SerialLight
-		fmt.Println("BLINK1MK3 not found, using SerialLight")
-		logger.InfoLog.Printf("BLINK1MK3 not found, using SerialLight")
-		serialLight, err := lights.NewSerialLight("/dev/ttyUSB0", 9600)
+		// Without configured lights, LIGHT_DRIVER picks the driver. The
+		// auto driver probes GPIO (when GPIO_PINS is set), then blink(1),
+		// then USB serial
+		driver := lightDriver()
+		device, err := lights.OpenDevice(driver, envLightOptions(driver))
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to initialize SerialLight: %w", err)
+			return nil, nil, fmt.Errorf("failed to initialize light: %w", err)
 		}
-		light = serialLight
+		fmt.Printf("Using %s light.\n", device.Driver)
+		logger.InfoLog.Printf("Using %s light", device.Driver)
+		light = device.Light
 		cleanup = func() {
-			if err := serialLight.Close(); err != nil {
-				logger.ErrorLog.Printf("Error closing SerialLight: %s", err.Error())
+			if err := device.Close(); err != nil {
+				logger.ErrorLog.Printf("Error closing %s light: %s", device.Driver, err.Error())
 			}
 		}
 	}
 
-	// Initialize to green state
+	// Initialize to green state. The controller writes asynchronously, so
+	// the device is set directly to catch a light that doesn't respond
 	initialState := lights.GreenState{}
 	if err := initialState.Apply(light); err != nil {
+		cleanup()
 		return nil, nil, fmt.Errorf("failed to set initial light state: %w", err)
 	}
 	logger.InfoLog.Printf("Light initialized to green")
 
-	return light, cleanup, nil
+	// Only write state changes to the device so re-applying the same state
+	// every poll neither flickers the lamps nor floods the serial line
+	controller := lights.NewController(light, lights.DefaultCoalesceWindow, lights.DefaultResyncInterval)
+	if err := initialState.Apply(controller); err != nil {
+		controller.Close()
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to set initial light state: %w", err)
+	}
+	deviceCleanup := cleanup
+	cleanup = func() {
+		if err := controller.Close(); err != nil {
+			logger.ErrorLog.Printf("Error flushing light state: %s", err.Error())
+		}
+		deviceCleanup()
+	}
+
+	return controller, cleanup, nil
 }
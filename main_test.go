@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"my-incident-checker/httpclient"
 	"my-incident-checker/lights"
+	"my-incident-checker/network"
 	"my-incident-checker/poll"
 	"my-incident-checker/types"
 	"io"
@@ -326,3 +335,320 @@ func TestAlertLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestZoneLogic(t *testing.T) {
+	storage := poll.Zone{Name: "storage", Services: []string{"database"}, Components: []string{"storage"}}
+
+	tests := []struct {
+		name      string
+		incidents []types.Incident
+		zone      poll.Zone
+		wantState lights.State
+	}{
+		{
+			name: "outage of another service",
+			incidents: []types.Incident{
+				{ID: 1, Service: "api", CurrentState: "outage", CreatedAt: "2025-01-09T03:18:00"},
+			},
+			zone:      storage,
+			wantState: lights.GreenState{},
+		},
+		{
+			name: "degraded service in zone",
+			incidents: []types.Incident{
+				{ID: 1, Service: "api", CurrentState: "outage", CreatedAt: "2025-01-09T03:18:00"},
+				{ID: 2, Service: "Database", CurrentState: "degraded", CreatedAt: "2025-01-09T03:19:00"},
+			},
+			zone:      storage,
+			wantState: lights.YellowState{},
+		},
+		{
+			name: "affected component in zone",
+			incidents: []types.Incident{
+				{
+					ID:           1,
+					Service:      "backup",
+					CurrentState: "MAJOR",
+					CreatedAt:    "2025-01-09T03:18:00",
+					Incident:     types.IncidentDetails{Components: []string{"storage"}},
+				},
+			},
+			zone:      storage,
+			wantState: lights.RedState{},
+		},
+		{
+			name: "latest incident of service resolved",
+			incidents: []types.Incident{
+				{ID: 1, Service: "database", CurrentState: "outage", CreatedAt: "2025-01-09T03:18:00"},
+				{ID: 2, Service: "database", CurrentState: "operational", CreatedAt: "2025-01-09T03:40:00"},
+			},
+			zone:      storage,
+			wantState: lights.GreenState{},
+		},
+		{
+			name: "zone without filters shows worst state",
+			incidents: []types.Incident{
+				{ID: 1, Service: "api", CurrentState: "degraded", CreatedAt: "2025-01-09T03:18:00"},
+				{ID: 2, Service: "web", CurrentState: "critical", CreatedAt: "2025-01-09T03:19:00"},
+			},
+			zone:      poll.Zone{Name: "all"},
+			wantState: lights.RedState{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotState := poll.ZoneLogic(tt.incidents, tt.zone)
+			if reflect.TypeOf(gotState) != reflect.TypeOf(tt.wantState) {
+				t.Errorf("ZoneLogic() got state type = %T, want state type = %T", gotState, tt.wantState)
+			}
+		})
+	}
+}
+
+// incidentAPI stands in for the incident API, serving the incidents set
+// last
+type incidentAPI struct {
+	mu        sync.Mutex
+	incidents []types.Incident
+}
+
+func (a *incidentAPI) set(incidents ...types.Incident) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.incidents = incidents
+}
+
+func (a *incidentAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	body, err := json.Marshal(a.incidents)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestAcknowledger(t *testing.T) {
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+		WarnLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	api := &incidentAPI{}
+	defer httpclient.SetTransport(api)()
+
+	light := lights.NewRecorderLight(io.Discard)
+	arbiter := lights.NewArbiter(light)
+	defer arbiter.Close()
+	acks := poll.NewAcknowledger(arbiter, logger)
+	start := time.Now().UTC().Add(-time.Hour)
+	poller := &poll.Poller{StartTime: start, Light: light, Logger: logger, Arbiter: arbiter, Acks: acks}
+
+	created := start.Add(time.Minute).Format(types.TimeFormat)
+	outage := types.Incident{ID: 1, Service: "api", CurrentState: "outage", CreatedAt: created}
+	degraded := types.Incident{ID: 2, Service: "db", CurrentState: "degraded", CreatedAt: created}
+	silent := lights.TowerState{Red: lights.ModeOn}
+
+	// A new incident sounds the alarm
+	api.set(outage)
+	poller.Poll()
+	if light.State() != poll.AlarmLamps {
+		t.Fatalf("light = %s for a new incident, want %s", light.State(), poll.AlarmLamps)
+	}
+
+	// Acknowledging silences it, also across polls
+	if err := acks.Acknowledge(); err != nil {
+		t.Fatal(err)
+	}
+	if light.State() != silent {
+		t.Errorf("light = %s after acknowledging, want %s", light.State(), silent)
+	}
+	poller.Poll()
+	if light.State() != silent {
+		t.Errorf("light = %s on the next poll, want %s", light.State(), silent)
+	}
+
+	// A new incident resumes the alarm
+	api.set(outage, degraded)
+	poller.Poll()
+	if acks.Acknowledged() || light.State() != poll.AlarmLamps {
+		t.Errorf("light = %s after a new incident, want %s", light.State(), poll.AlarmLamps)
+	}
+
+	// A snooze holds even when incidents change
+	if err := acks.Snooze(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	api.set(outage, degraded, types.Incident{ID: 3, Service: "web", CurrentState: "critical", CreatedAt: created})
+	poller.Poll()
+	if !acks.Acknowledged() || light.State() != silent {
+		t.Errorf("light = %s while snoozed, want %s", light.State(), silent)
+	}
+}
+
+type switchProbe struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (p *switchProbe) Name() string { return "switch" }
+
+func (p *switchProbe) Check(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func TestZonesThroughPoller(t *testing.T) {
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+		WarnLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	api := &incidentAPI{}
+	defer httpclient.SetTransport(api)()
+
+	light := lights.NewRecorderLight(io.Discard)
+	arbiter := lights.NewArbiter(light)
+	defer arbiter.Close()
+	zoneLight := lights.NewRecorderLight(io.Discard)
+	zoneArbiter := lights.NewArbiter(zoneLight)
+	defer zoneArbiter.Close()
+	start := time.Now().UTC()
+	poller := &poll.Poller{
+		StartTime: start,
+		Light:     light,
+		Logger:    logger,
+		Arbiter:   arbiter,
+		Zones:     []poll.Zone{{Name: "storage", Services: []string{"database"}, Light: zoneLight, Arbiter: zoneArbiter}},
+	}
+
+	// An outage from before the start raises no alert, but the main light
+	// still shows the red of the zone
+	before := start.Add(-time.Hour).Format(types.TimeFormat)
+	api.set(types.Incident{ID: 1, Service: "database", CurrentState: "outage", CreatedAt: before})
+	poller.Poll()
+	red := lights.TowerState{Red: lights.ModeOn}
+	if zoneLight.State() != red || light.State() != red {
+		t.Errorf("zone light = %s, main light = %s, want both %s", zoneLight.State(), light.State(), red)
+	}
+
+	// Overrides on the zone arbiter win over the incidents
+	if err := zoneArbiter.Claim("override", lights.PriorityOverride, lights.GreenState{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	poller.Poll()
+	if green := (lights.TowerState{Green: lights.ModeOn}); zoneLight.State() != green {
+		t.Errorf("zone light = %s with an override, want %s", zoneLight.State(), green)
+	}
+
+	// Once the service recovers, both turn green
+	api.set(types.Incident{ID: 1, Service: "database", CurrentState: "operational", CreatedAt: before})
+	zoneArbiter.Release("override")
+	poller.Poll()
+	if green := (lights.TowerState{Green: lights.ModeOn}); light.State() != green || zoneLight.State() != green {
+		t.Errorf("zone light = %s, main light = %s after recovery, want both %s", zoneLight.State(), light.State(), green)
+	}
+}
+
+func TestConnectivity(t *testing.T) {
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(io.Discard, "", 0),
+		WarnLog:  log.New(io.Discard, "", 0),
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	light := lights.NewRecorderLight(io.Discard)
+	arbiter := lights.NewArbiter(light)
+	defer arbiter.Close()
+	arbiter.Claim(poll.ClaimSource, lights.PriorityIncident, lights.GreenState{}, 0)
+
+	probe := &switchProbe{}
+	notifications := make(chan string, 10)
+	c := &connectivity{
+		monitor:      network.NewMonitor([]network.Probe{probe}, time.Hour, time.Second, logger),
+		arbiter:      arbiter,
+		logger:       logger,
+		nodeName:     "pi-1",
+		networkLamps: defaultNetworkLamps,
+		apiLamps:     defaultAPILamps,
+		apiDownAfter: 2,
+		notify:       func(message string) { notifications <- message },
+	}
+	c.monitor.DownAfter = 1
+	c.monitor.UpAfter = 1
+	c.monitor.OnChange = c.networkChanged
+	source := func() string {
+		claim, _ := arbiter.Active()
+		return claim.Source
+	}
+	next := func() string {
+		select {
+		case message := <-notifications:
+			return message
+		case <-time.After(time.Second):
+			return ""
+		}
+	}
+
+	fetchErr := errors.New("unexpected status code from incidents API: 502")
+	c.fetched(fetchErr)
+	if source() != poll.ClaimSource {
+		t.Errorf("shown %s after one failed fetch, want incidents", source())
+	}
+	c.fetched(fetchErr)
+	if source() != apiSource || light.State() != defaultAPILamps {
+		t.Errorf("shown %s (%s) after failed fetches with the network up, want %s", source(), light.State(), apiSource)
+	}
+	if message := next(); message != "pi-1: incident API is down (2 failed fetches, network up): "+fetchErr.Error() {
+		t.Errorf("notification = %q", message)
+	}
+
+	// The network going down takes the blame from the API
+	probe.mu.Lock()
+	probe.err = errors.New("no route to host")
+	probe.mu.Unlock()
+	c.monitor.Check()
+	c.fetched(fetchErr)
+	c.fetched(fetchErr)
+	if source() != networkSource || light.State() != defaultNetworkLamps {
+		t.Errorf("shown %s (%s) with the network down, want %s", source(), light.State(), networkSource)
+	}
+	if message := next(); message != "pi-1: network is down: switch: no route to host" {
+		t.Errorf("notification = %q", message)
+	}
+
+	probe.mu.Lock()
+	probe.err = nil
+	probe.mu.Unlock()
+	c.monitor.Check()
+	c.fetched(nil)
+	if source() != poll.ClaimSource {
+		t.Errorf("shown %s after recovery, want incidents", source())
+	}
+	if message := next(); !strings.HasPrefix(message, "pi-1: network is back up after ") {
+		t.Errorf("notification = %q", message)
+	}
+	if message := next(); message != "" {
+		t.Errorf("unexpected notification %q", message)
+	}
+}
+
+func TestDurationOption(t *testing.T) {
+	if got, err := durationOption("", time.Minute); err != nil || got != time.Minute {
+		t.Errorf("durationOption(\"\") = %s, %v, want the fallback", got, err)
+	}
+	if got, err := durationOption("1500ms", time.Minute); err != nil || got != 1500*time.Millisecond {
+		t.Errorf("durationOption(1500ms) = %s, %v", got, err)
+	}
+	if _, err := durationOption("5 minutes", time.Minute); err == nil {
+		t.Error("durationOption accepted an invalid duration")
+	}
+}
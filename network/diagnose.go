@@ -0,0 +1,463 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-incident-checker/httpclient"
+)
+
+// resolvConf lists the DNS resolvers of the system
+const resolvConf = "/etc/resolv.conf"
+
+// Interface is a network interface and its addresses
+type Interface struct {
+	Name  string
+	Flags string
+	Addrs []string
+}
+
+// Lookup is the result of resolving a host name
+type Lookup struct {
+	Host     string
+	Addrs    []string
+	Duration time.Duration
+	Err      error
+}
+
+// Handshake is the result of connecting to an endpoint the way the checker
+// does: the TCP connect and, for TLS endpoints, the TLS handshake with the
+// configured certificate authorities and client certificate. When requests
+// to the endpoint go through a proxy, the TCP connect is to the proxy and
+// the TLS handshake runs in a tunnel opened with CONNECT
+type Handshake struct {
+	Endpoint string
+	Address  string
+	// Proxy is the host of the proxy used for the endpoint, empty when it
+	// is reached directly
+	Proxy   string
+	TLS     bool
+	Connect time.Duration
+	// Tunnel is the duration of the CONNECT request to the proxy
+	Tunnel time.Duration
+	// Handshake is the duration of the TLS handshake
+	Handshake  time.Duration
+	TLSVersion string
+	// Skipped tells why the TLS handshake wasn't checked
+	Skipped string
+	Err     error
+}
+
+// Report is a diagnostic bundle describing the network as seen by the
+// checker
+type Report struct {
+	Time             time.Time
+	Interfaces       []Interface
+	InterfacesErr    error
+	Gateway          string
+	GatewayInterface string
+	GatewayErr       error
+	Resolvers        []string
+	ResolversErr     error
+	Lookups          []Lookup
+	Handshakes       []Handshake
+}
+
+// OK reports whether every lookup and handshake succeeded
+func (r Report) OK() bool {
+	if r.GatewayErr != nil {
+		return false
+	}
+	for _, lookup := range r.Lookups {
+		if lookup.Err != nil {
+			return false
+		}
+	}
+	for _, handshake := range r.Handshakes {
+		if handshake.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Endpoint is a host to diagnose, parsed from a URL or a host:port
+type Endpoint struct {
+	Name string
+	Host string
+	Port string
+	TLS  bool
+	// HTTP is set for URLs, which are requested through the configured
+	// proxy; host:port addresses are always dialed directly
+	HTTP bool
+}
+
+// ParseEndpoint accepts http and https URLs and host:port addresses
+func ParseEndpoint(s string) (Endpoint, error) {
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		endpoint := Endpoint{Name: u.Scheme + "://" + u.Host, Host: u.Hostname(), Port: u.Port(), HTTP: true}
+		switch u.Scheme {
+		case "https":
+			endpoint.TLS = true
+			if endpoint.Port == "" {
+				endpoint.Port = "443"
+			}
+		case "http":
+			if endpoint.Port == "" {
+				endpoint.Port = "80"
+			}
+		default:
+			return Endpoint{}, fmt.Errorf("unsupported scheme %q", u.Scheme)
+		}
+		return endpoint, nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	return Endpoint{Name: s, Host: host, Port: port}, nil
+}
+
+// Diagnose collects the interfaces, default route and resolvers, and
+// resolves and connects to each endpoint, each step bounded by timeout.
+// URLs are connected to with the proxy and TLS settings of httpclient.
+// Duplicate endpoints are diagnosed once
+func Diagnose(endpoints []Endpoint, timeout time.Duration) Report {
+	report := Report{Time: time.Now()}
+	report.Interfaces, report.InterfacesErr = interfaces()
+	gateway, iface, err := DefaultGateway()
+	if err == nil {
+		report.Gateway, report.GatewayInterface = gateway.String(), iface
+	}
+	report.GatewayErr = err
+	report.Resolvers, report.ResolversErr = resolvers()
+
+	var hosts []string
+	seenHosts := make(map[string]bool)
+	var unique []Endpoint
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		// An endpoint requested through a proxy is connected to differently
+		// than the same address dialed directly
+		key := endpoint.Host + ":" + endpoint.Port
+		if proxy, err := endpointProxy(endpoint); err == nil && proxy != nil {
+			key += " via " + proxy.String()
+		}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, endpoint)
+		}
+		if net.ParseIP(endpoint.Host) == nil && !seenHosts[endpoint.Host] {
+			seenHosts[endpoint.Host] = true
+			hosts = append(hosts, endpoint.Host)
+		}
+	}
+
+	report.Lookups = make([]Lookup, len(hosts))
+	report.Handshakes = make([]Handshake, len(unique))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			report.Lookups[i] = lookup(host, timeout)
+		}(i, host)
+	}
+	for i, endpoint := range unique {
+		wg.Add(1)
+		go func(i int, endpoint Endpoint) {
+			defer wg.Done()
+			report.Handshakes[i] = handshake(endpoint, timeout)
+		}(i, endpoint)
+	}
+	wg.Wait()
+	return report
+}
+
+func interfaces() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		entry := Interface{Name: iface.Name, Flags: iface.Flags.String()}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			entry.Addrs = []string{"error: " + err.Error()}
+		}
+		for _, addr := range addrs {
+			entry.Addrs = append(entry.Addrs, addr.String())
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func resolvers() ([]string, error) {
+	file, err := os.Open(resolvConf)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseResolvers(file)
+}
+
+// parseResolvers returns the nameservers of a resolv.conf
+func parseResolvers(r io.Reader) ([]string, error) {
+	var servers []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers, scanner.Err()
+}
+
+func lookup(host string, timeout time.Duration) Lookup {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	sort.Strings(addrs)
+	return Lookup{Host: host, Addrs: addrs, Duration: time.Since(start), Err: err}
+}
+
+func handshake(endpoint Endpoint, timeout time.Duration) Handshake {
+	result := Handshake{
+		Endpoint: endpoint.Name,
+		Address:  net.JoinHostPort(endpoint.Host, endpoint.Port),
+		TLS:      endpoint.TLS,
+	}
+	address := result.Address
+	proxy, err := endpointProxy(endpoint)
+	if err != nil {
+		result.Err = fmt.Errorf("proxy: %w", err)
+		return result
+	}
+	if proxy != nil {
+		result.Proxy = proxy.Host
+		address = proxyAddress(proxy)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	result.Connect = time.Since(start)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Close()
+	if !endpoint.TLS {
+		return result
+	}
+
+	tunneled := conn
+	if proxy != nil {
+		if proxy.Scheme != "http" && proxy.Scheme != "https" {
+			result.Skipped = "tls through a " + proxy.Scheme + " proxy is not checked"
+			return result
+		}
+		start = time.Now()
+		tunneled, err = tunnel(ctx, conn, proxy, result.Address)
+		result.Tunnel = time.Since(start)
+		if err != nil {
+			result.Err = fmt.Errorf("proxy: %w", err)
+			return result
+		}
+	}
+
+	config := httpclient.TLSConfig()
+	config.ServerName = endpoint.Host
+	tlsConn := tls.Client(tunneled, config)
+	start = time.Now()
+	err = tlsConn.HandshakeContext(ctx)
+	result.Handshake = time.Since(start)
+	if err != nil {
+		result.Err = fmt.Errorf("tls handshake: %w", err)
+		return result
+	}
+	result.TLSVersion = tlsVersion(tlsConn.ConnectionState().Version)
+	return result
+}
+
+// endpointProxy returns the proxy httpclient uses for a URL endpoint, nil
+// when the endpoint is reached directly
+func endpointProxy(endpoint Endpoint) (*url.URL, error) {
+	if !endpoint.HTTP {
+		return nil, nil
+	}
+	scheme := "http"
+	if endpoint.TLS {
+		scheme = "https"
+	}
+	return httpclient.ProxyFor(&url.URL{Scheme: scheme, Host: net.JoinHostPort(endpoint.Host, endpoint.Port)})
+}
+
+// proxyAddress returns the host:port of a proxy, with the default port of
+// its scheme when the URL has none
+func proxyAddress(proxy *url.URL) string {
+	port := proxy.Port()
+	if port == "" {
+		switch proxy.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// tunnel opens a tunnel to address through an http or https proxy, as the
+// transport does for HTTPS requests
+func tunnel(ctx context.Context, conn net.Conn, proxy *url.URL, address string) (net.Conn, error) {
+	if proxy.Scheme == "https" {
+		config := httpclient.TLSConfig()
+		config.ServerName = proxy.Hostname()
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", address, resp.Status)
+	}
+	return conn, nil
+}
+
+func tlsVersion(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("TLS 0x%04x", version)
+}
+
+// Lines renders the report as lines of text, one fact per line
+func (r Report) Lines() []string {
+	lines := []string{"Network diagnostics at " + r.Time.UTC().Format(time.RFC3339)}
+	if r.InterfacesErr != nil {
+		lines = append(lines, "interfaces: error: "+r.InterfacesErr.Error())
+	}
+	for _, iface := range r.Interfaces {
+		addrs := "no addresses"
+		if len(iface.Addrs) > 0 {
+			addrs = strings.Join(iface.Addrs, ", ")
+		}
+		lines = append(lines, fmt.Sprintf("interface %s (%s): %s", iface.Name, iface.Flags, addrs))
+	}
+	if r.GatewayErr != nil {
+		lines = append(lines, "default route: error: "+r.GatewayErr.Error())
+	} else {
+		lines = append(lines, fmt.Sprintf("default route: via %s dev %s", r.Gateway, r.GatewayInterface))
+	}
+	if r.ResolversErr != nil {
+		lines = append(lines, "resolvers: error: "+r.ResolversErr.Error())
+	} else {
+		lines = append(lines, "resolvers: "+strings.Join(r.Resolvers, ", "))
+	}
+	for _, lookup := range r.Lookups {
+		if lookup.Err != nil {
+			lines = append(lines, fmt.Sprintf("dns %s: error after %s: %s", lookup.Host, roundDuration(lookup.Duration), lookup.Err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("dns %s: %s in %s", lookup.Host, strings.Join(lookup.Addrs, ", "), roundDuration(lookup.Duration)))
+	}
+	for _, h := range r.Handshakes {
+		name := h.Endpoint
+		if name != h.Address {
+			name += " (" + h.Address + ")"
+		}
+		if h.Proxy != "" {
+			name += " via proxy " + h.Proxy
+		}
+		line := fmt.Sprintf("connect %s: tcp %s", name, roundDuration(h.Connect))
+		if h.Tunnel > 0 {
+			line += fmt.Sprintf(", tunnel %s", roundDuration(h.Tunnel))
+		}
+		if h.TLS && h.Handshake > 0 {
+			line += fmt.Sprintf(", tls %s", roundDuration(h.Handshake))
+			if h.TLSVersion != "" {
+				line += " (" + h.TLSVersion + ")"
+			}
+		}
+		if h.Skipped != "" {
+			line += ", " + h.Skipped
+		}
+		if h.Err != nil {
+			line += ", error: " + h.Err.Error()
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// WriteTo writes the report as text
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, line := range r.Lines() {
+		n, err := fmt.Fprintln(w, line)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond / 10)
+}
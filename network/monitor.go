@@ -0,0 +1,236 @@
+package network
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+const (
+	// DefaultMonitorInterval is the time between probe rounds
+	DefaultMonitorInterval = 30 * time.Second
+	// DefaultProbeTimeout bounds each probe
+	DefaultProbeTimeout = 5 * time.Second
+	// DefaultDownAfter is the number of consecutive failed rounds after
+	// which the network counts as down
+	DefaultDownAfter = 3
+	// DefaultUpAfter is the number of consecutive successful rounds after
+	// which the network counts as up again
+	DefaultUpAfter = 2
+)
+
+// Result is the outcome of one probe
+type Result struct {
+	Probe    string
+	Err      error
+	Duration time.Duration
+	// Advisory results are reported but don't decide whether the round
+	// fails
+	Advisory bool
+}
+
+// Advisory is implemented by probes whose failure alone doesn't show the
+// network is down, e.g. a gateway that ignores TCP connections
+type Advisory interface {
+	Advisory() bool
+}
+
+// Round is the outcome of running every probe once
+type Round struct {
+	Time    time.Time
+	Results []Result
+}
+
+// Down reports whether at least quorum of the probes failed, counting
+// advisory probes only when every probe is advisory. A quorum of zero or
+// more than the probes counted requires all of them to fail
+func (r Round) Down(quorum int) bool {
+	counted, failed := 0, 0
+	for _, advisory := range []bool{false, true} {
+		for _, result := range r.Results {
+			if result.Advisory != advisory {
+				continue
+			}
+			counted++
+			if result.Err != nil {
+				failed++
+			}
+		}
+		if counted > 0 {
+			break
+		}
+	}
+	if counted == 0 {
+		return false
+	}
+	if quorum <= 0 || quorum > counted {
+		quorum = counted
+	}
+	return failed >= quorum
+}
+
+// Failed returns the failed probes
+func (r Round) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Reason describes the failed probes, e.g. "dns www.google.com: no such
+// host; gateway: no default route"
+func (r Round) Reason() string {
+	parts := make([]string, 0, len(r.Results))
+	for _, result := range r.Failed() {
+		parts = append(parts, result.Probe+": "+result.Err.Error())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Monitor probes connectivity in the background. A round fails when
+// Quorum of its probes fail, all of them by default, so that one blocked
+// host doesn't take the network down. The network changes state only
+// after DownAfter failed or UpAfter successful rounds in a row, so a
+// single lost packet doesn't flap the light
+type Monitor struct {
+	probes   []Probe
+	interval time.Duration
+	timeout  time.Duration
+	logger   *types.Logger
+
+	// Quorum is the number of failed probes that fail a round, zero for
+	// all of them
+	Quorum int
+	// DownAfter and UpAfter are the hysteresis of state changes
+	DownAfter int
+	UpAfter   int
+	// OnChange is called when the network goes down or comes back up,
+	// with the round that decided it
+	OnChange func(up bool, round Round)
+
+	mu     sync.Mutex
+	up     bool
+	streak int // consecutive rounds disagreeing with up
+	last   Round
+}
+
+// NewMonitor creates a Monitor. The network counts as up until proven
+// otherwise
+func NewMonitor(probes []Probe, interval, timeout time.Duration, logger *types.Logger) *Monitor {
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	return &Monitor{
+		probes:    probes,
+		interval:  interval,
+		timeout:   timeout,
+		logger:    logger,
+		DownAfter: DefaultDownAfter,
+		UpAfter:   DefaultUpAfter,
+		up:        true,
+	}
+}
+
+// Run probes at the monitor interval until stop is closed
+func (m *Monitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.Check()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Up reports whether the network counts as up
+func (m *Monitor) Up() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.up
+}
+
+// Last returns the latest round
+func (m *Monitor) Last() Round {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Check runs one round of probes and updates the state
+func (m *Monitor) Check() Round {
+	round := RunProbes(m.probes, m.timeout)
+	failed := round.Failed()
+	for _, result := range failed {
+		m.logger.DebugLog.Printf("Connectivity probe %s failed: %s", result.Probe, result.Err.Error())
+	}
+
+	m.mu.Lock()
+	m.last = round
+	roundUp := !round.Down(m.Quorum)
+	changed := false
+	if roundUp == m.up {
+		m.streak = 0
+	} else {
+		m.streak++
+		threshold := m.DownAfter
+		if roundUp {
+			threshold = m.UpAfter
+		}
+		if m.streak >= threshold {
+			m.up = roundUp
+			m.streak = 0
+			changed = true
+		}
+	}
+	onChange := m.OnChange
+	m.mu.Unlock()
+
+	if changed {
+		if roundUp {
+			m.logger.InfoLog.Printf("Network is up again")
+		} else {
+			m.logger.WarnLog.Printf("Network is down: %s", round.Reason())
+		}
+		if onChange != nil {
+			onChange(roundUp, round)
+		}
+	}
+	return round
+}
+
+// RunProbes runs every probe concurrently, each bounded by timeout
+func RunProbes(probes []Probe, timeout time.Duration) Round {
+	round := Round{Time: time.Now(), Results: make([]Result, len(probes))}
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, probe Probe) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			start := time.Now()
+			err := probe.Check(ctx)
+			advisory, _ := probe.(Advisory)
+			round.Results[i] = Result{
+				Probe:    probe.Name(),
+				Err:      err,
+				Duration: time.Since(start),
+				Advisory: advisory != nil && advisory.Advisory(),
+			}
+		}(i, probe)
+	}
+	wg.Wait()
+	return round
+}
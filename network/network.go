@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"my-incident-checker/httpclient"
 )
 
 const (
@@ -11,12 +13,11 @@ const (
 	connectTimeout    = 10 * time.Second
 )
 
+// client makes the HTTP connectivity checks
+var client = httpclient.New(connectTimeout)
+
 // CheckConnectivity verifies internet connectivity by making a request to a known endpoint
 func CheckConnectivity() error {
-	client := &http.Client{
-		Timeout: connectTimeout,
-	}
-
 	resp, err := client.Get(connectivityCheck)
 	if err != nil {
 		return fmt.Errorf("connectivity check failed: %w", err)
@@ -0,0 +1,320 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-incident-checker/httpclient"
+	"my-incident-checker/types"
+)
+
+func testLogger(w io.Writer) *types.Logger {
+	return &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(w, "INFO: ", 0),
+		WarnLog:  log.New(w, "WARN: ", 0),
+		ErrorLog: log.New(w, "ERROR: ", 0),
+	}
+}
+
+func TestParseDefaultRoute(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	0000A8C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+`
+	ip, iface, err := parseDefaultRoute(strings.NewReader(table))
+	if err != nil || ip.String() != "192.168.1.1" || iface != "eth0" {
+		t.Errorf("parseDefaultRoute() = %s, %s, %v, want 192.168.1.1 on eth0", ip, iface, err)
+	}
+
+	_, _, err = parseDefaultRoute(strings.NewReader(strings.SplitAfter(table, "\n")[0]))
+	if err == nil {
+		t.Error("parseDefaultRoute() without a default route succeeded")
+	}
+}
+
+func TestNewProbe(t *testing.T) {
+	valid := []struct{ kind, target, name string }{
+		{ProbeDNS, "example.com", "dns example.com"},
+		{ProbeTCP, "1.1.1.1:443", "tcp 1.1.1.1:443"},
+		{ProbeHTTP, "https://example.com", "http https://example.com"},
+		{ProbeGateway, "", "gateway"},
+		{ProbeGateway, "80", "gateway"},
+	}
+	for _, tt := range valid {
+		probe, err := NewProbe(tt.kind, tt.target)
+		if err != nil || probe.Name() != tt.name {
+			t.Errorf("NewProbe(%s, %q) = %v, %v, want %s", tt.kind, tt.target, probe, err, tt.name)
+		}
+	}
+	invalid := [][2]string{{ProbeDNS, ""}, {ProbeTCP, "1.1.1.1"}, {ProbeHTTP, "example.com"}, {ProbeGateway, "x"}, {"icmp", "1.1.1.1"}}
+	for _, tt := range invalid {
+		if _, err := NewProbe(tt[0], tt[1]); err == nil {
+			t.Errorf("NewProbe(%s, %q) succeeded", tt[0], tt[1])
+		}
+	}
+}
+
+func TestProbes(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	defer listener.Close()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	round := RunProbes([]Probe{
+		TCPProbe{Address: listener.Addr().String()},
+		TCPProbe{Address: closedAddr},
+		HTTPProbe{URL: ok.URL},
+		HTTPProbe{URL: broken.URL},
+		DNSProbe{Host: "localhost"},
+	}, time.Second)
+
+	var failed []string
+	for _, result := range round.Failed() {
+		failed = append(failed, result.Probe)
+	}
+	want := "tcp " + closedAddr + ",http " + broken.URL
+	if strings.Join(failed, ",") != want {
+		t.Errorf("failed probes = %v, want %s", failed, want)
+	}
+	if !strings.Contains(round.Reason(), "unexpected status code: 503") {
+		t.Errorf("Reason() = %q", round.Reason())
+	}
+}
+
+type fakeProbe struct {
+	err error
+}
+
+func (p *fakeProbe) Name() string                    { return "fake" }
+func (p *fakeProbe) Check(ctx context.Context) error { return p.err }
+
+func TestMonitorHysteresis(t *testing.T) {
+	var logs bytes.Buffer
+	probe := &fakeProbe{}
+	monitor := NewMonitor([]Probe{probe}, time.Hour, time.Second, testLogger(&logs))
+	var changes []bool
+	monitor.OnChange = func(up bool, round Round) { changes = append(changes, up) }
+
+	probe.err = errors.New("no route to host")
+	monitor.Check()
+	monitor.Check()
+	probe.err = nil
+	monitor.Check()
+	probe.err = errors.New("no route to host")
+	monitor.Check()
+	monitor.Check()
+	if !monitor.Up() || len(changes) != 0 {
+		t.Fatalf("Up() = %v, changes %v: down after interrupted failures", monitor.Up(), changes)
+	}
+	monitor.Check()
+	if monitor.Up() || len(changes) != 1 || changes[0] {
+		t.Fatalf("Up() = %v, changes %v, want down after 3 failed rounds", monitor.Up(), changes)
+	}
+
+	probe.err = nil
+	monitor.Check()
+	if monitor.Up() {
+		t.Fatal("Up() after one successful round, want still down")
+	}
+	monitor.Check()
+	if !monitor.Up() || len(changes) != 2 || !changes[1] {
+		t.Fatalf("Up() = %v, changes %v, want up after 2 successful rounds", monitor.Up(), changes)
+	}
+	if !strings.Contains(logs.String(), "WARN: Network is down: fake: no route to host") {
+		t.Errorf("logs = %q", logs.String())
+	}
+}
+
+func TestRoundDown(t *testing.T) {
+	failure := errors.New("timeout")
+	round := func(results ...Result) Round { return Round{Results: results} }
+	tests := []struct {
+		name   string
+		round  Round
+		quorum int
+		want   bool
+	}{
+		{"one of two failed", round(Result{Err: failure}, Result{}), 0, false},
+		{"all failed", round(Result{Err: failure}, Result{Err: failure}), 0, true},
+		{"quorum reached", round(Result{Err: failure}, Result{Err: failure}, Result{}), 2, true},
+		{"quorum above probes", round(Result{Err: failure}, Result{}), 5, false},
+		{"advisory failure", round(Result{Err: failure, Advisory: true}, Result{}), 1, false},
+		{"advisory ignored", round(Result{Advisory: true}, Result{Err: failure}), 0, true},
+		{"only advisory", round(Result{Err: failure, Advisory: true}), 0, true},
+		{"no probes", round(), 0, false},
+	}
+	for _, test := range tests {
+		if got := test.round.Down(test.quorum); got != test.want {
+			t.Errorf("%s: Down(%d) = %v, want %v", test.name, test.quorum, got, test.want)
+		}
+	}
+
+	results := RunProbes([]Probe{GatewayProbe{Port: 1}, &fakeProbe{}}, time.Second).Results
+	if !results[0].Advisory || results[1].Advisory {
+		t.Errorf("advisory = %v, %v, want only the gateway probe advisory", results[0].Advisory, results[1].Advisory)
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Endpoint
+	}{
+		{"https://ntfy.sh/topic", Endpoint{Name: "https://ntfy.sh", Host: "ntfy.sh", Port: "443", TLS: true, HTTP: true}},
+		{"http://192.168.1.10:8080/x", Endpoint{Name: "http://192.168.1.10:8080", Host: "192.168.1.10", Port: "8080", HTTP: true}},
+		{"1.1.1.1:443", Endpoint{Name: "1.1.1.1:443", Host: "1.1.1.1", Port: "443"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseEndpoint(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseEndpoint(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"ftp://example.com", "example.com"} {
+		if _, err := ParseEndpoint(in); err == nil {
+			t.Errorf("ParseEndpoint(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseResolvers(t *testing.T) {
+	conf := "# generated\nsearch lan\nnameserver 192.168.1.1\nnameserver fd00::1\noptions edns0\n"
+	servers, err := parseResolvers(strings.NewReader(conf))
+	if err != nil || strings.Join(servers, ",") != "192.168.1.1,fd00::1" {
+		t.Errorf("parseResolvers() = %v, %v", servers, err)
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	tlsEndpoint, _ := ParseEndpoint(server.URL)
+	tcpEndpoint, _ := ParseEndpoint(server.Listener.Addr().String())
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedEndpoint, _ := ParseEndpoint(closed.Addr().String())
+	closed.Close()
+
+	report := Diagnose([]Endpoint{tlsEndpoint, tcpEndpoint, closedEndpoint, {Name: "localhost", Host: "localhost", Port: "1"}}, time.Second)
+	if report.OK() {
+		t.Error("OK() with an unreachable endpoint")
+	}
+	if len(report.Lookups) != 1 || report.Lookups[0].Host != "localhost" {
+		t.Errorf("Lookups = %+v, want only the host name resolved", report.Lookups)
+	}
+	if len(report.Handshakes) != 3 {
+		t.Fatalf("Handshakes = %+v, want the duplicate address diagnosed once", report.Handshakes)
+	}
+	// The test server's certificate is self-signed, so the handshake runs
+	// and fails verification
+	tlsResult := report.Handshakes[0]
+	if !tlsResult.TLS || tlsResult.Handshake == 0 || tlsResult.Err == nil || !strings.Contains(tlsResult.Err.Error(), "tls handshake") {
+		t.Errorf("TLS handshake = %+v", tlsResult)
+	}
+	if report.Handshakes[1].Err == nil {
+		t.Errorf("closed port handshake = %+v, want an error", report.Handshakes[1])
+	}
+
+	var out bytes.Buffer
+	report.WriteTo(&out)
+	for _, want := range []string{"interface lo", "resolvers: ", "dns localhost: ", "connect " + server.URL + " (" + tcpEndpoint.Name + "): tcp "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestDiagnoseThroughProxy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	var connected string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		connected = r.Host
+		upstream, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+		rw.Flush()
+		go io.Copy(upstream, rw)
+		io.Copy(conn, upstream)
+	}))
+	defer proxy.Close()
+
+	// The test server's certificate is valid for *.example.com and is
+	// trusted with the CA bundle of the checker
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := httpclient.Configure(httpclient.Config{Proxy: proxy.URL, CAFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	defer httpclient.Configure(httpclient.Config{})
+
+	// The URL goes through the proxy without being resolved locally, while
+	// a host:port is dialed directly
+	proxied, _ := ParseEndpoint("https://status.example.com/incidents")
+	direct, _ := ParseEndpoint(proxy.Listener.Addr().String())
+	report := Diagnose([]Endpoint{proxied, direct}, time.Second)
+	h := report.Handshakes[0]
+	if h.Err != nil || h.Proxy != proxy.Listener.Addr().String() || h.Tunnel == 0 || h.TLSVersion == "" {
+		t.Errorf("proxied handshake = %+v", h)
+	}
+	if connected != "status.example.com:443" {
+		t.Errorf("proxy tunneled to %q, want status.example.com:443", connected)
+	}
+	if h := report.Handshakes[1]; h.Err != nil || h.Proxy != "" {
+		t.Errorf("direct handshake = %+v", h)
+	}
+
+	var out bytes.Buffer
+	report.WriteTo(&out)
+	if want := "connect https://status.example.com (status.example.com:443) via proxy " + h.Proxy + ": tcp "; !strings.Contains(out.String(), want) {
+		t.Errorf("report = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,222 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Probe types accepted by NewProbe
+const (
+	ProbeDNS     = "dns"
+	ProbeTCP     = "tcp"
+	ProbeHTTP    = "http"
+	ProbeGateway = "gateway"
+)
+
+// routeTable is the kernel IPv4 routing table
+const routeTable = "/proc/net/route"
+
+// Probe checks one aspect of connectivity
+type Probe interface {
+	// Name identifies the probe in logs, e.g. "dns www.google.com"
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// NewProbe creates a probe of the given type. The target is a host name
+// for dns, a host:port for tcp, a URL for http and an optional port for
+// gateway
+func NewProbe(kind, target string) (Probe, error) {
+	switch kind {
+	case ProbeDNS:
+		if target == "" {
+			return nil, fmt.Errorf("dns probe needs a host name")
+		}
+		return DNSProbe{Host: target}, nil
+	case ProbeTCP:
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return nil, fmt.Errorf("tcp probe needs a host:port: %w", err)
+		}
+		return TCPProbe{Address: target}, nil
+	case ProbeHTTP:
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return nil, fmt.Errorf("http probe needs an http or https URL, got %q", target)
+		}
+		return HTTPProbe{URL: target}, nil
+	case ProbeGateway:
+		port := defaultGatewayPort
+		if target != "" {
+			p, err := strconv.Atoi(target)
+			if err != nil || p <= 0 || p > 65535 {
+				return nil, fmt.Errorf("gateway probe needs a port, got %q", target)
+			}
+			port = p
+		}
+		return GatewayProbe{Port: port}, nil
+	}
+	return nil, fmt.Errorf("unknown probe type %q (want dns, tcp, http or gateway)", kind)
+}
+
+// DefaultProbes check the local gateway and general internet access, not
+// the incident API, so that an outage of the API is told apart from one
+// of our network
+func DefaultProbes() []Probe {
+	return []Probe{
+		GatewayProbe{Port: defaultGatewayPort},
+		DNSProbe{Host: "www.google.com"},
+		TCPProbe{Address: "1.1.1.1:443"},
+		HTTPProbe{URL: connectivityCheck},
+	}
+}
+
+// DNSProbe resolves a host name
+type DNSProbe struct {
+	Host string
+}
+
+// Name implements Probe
+func (p DNSProbe) Name() string { return "dns " + p.Host }
+
+// Check implements Probe
+func (p DNSProbe) Check(ctx context.Context) error {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, p.Host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no addresses for %s", p.Host)
+	}
+	return nil
+}
+
+// TCPProbe opens a TCP connection
+type TCPProbe struct {
+	Address string
+}
+
+// Name implements Probe
+func (p TCPProbe) Name() string { return "tcp " + p.Address }
+
+// Check implements Probe
+func (p TCPProbe) Check(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HTTPProbe gets a URL and expects a response below 400
+type HTTPProbe struct {
+	URL string
+}
+
+// Name implements Probe
+func (p HTTPProbe) Name() string { return "http " + p.URL }
+
+// Check implements Probe
+func (p HTTPProbe) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// defaultGatewayPort is the port the gateway probe connects to; most
+// routers serve DNS
+const defaultGatewayPort = 53
+
+// GatewayProbe checks that the default gateway answers. A TCP connection
+// to Port that is accepted or refused both show the gateway is reachable;
+// unlike ICMP ping this needs no privileges
+type GatewayProbe struct {
+	Port int
+}
+
+// Name implements Probe
+func (p GatewayProbe) Name() string { return "gateway" }
+
+// Advisory implements Advisory: many routers drop connections to the
+// probed port while forwarding traffic just fine
+func (p GatewayProbe) Advisory() bool { return true }
+
+// Check implements Probe
+func (p GatewayProbe) Check(ctx context.Context) error {
+	gateway, _, err := DefaultGateway()
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(gateway.String(), strconv.Itoa(p.Port)))
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("gateway %s unreachable: %w", gateway, err)
+	}
+	return conn.Close()
+}
+
+// DefaultGateway returns the IPv4 default gateway and its interface
+func DefaultGateway() (net.IP, string, error) {
+	file, err := os.Open(routeTable)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	defer file.Close()
+	return parseDefaultRoute(file)
+}
+
+// parseDefaultRoute finds the default route in the format of
+// /proc/net/route, where addresses are little endian hex
+func parseDefaultRoute(r io.Reader) (net.IP, string, error) {
+	const (
+		flagUp      = 0x1
+		flagGateway = 0x2
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&(flagUp|flagGateway) != flagUp|flagGateway {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		return ip, fields[0], nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	return nil, "", fmt.Errorf("no default route")
+}
@@ -1,22 +1,31 @@
 package notify
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"my-incident-checker/httpclient"
 )
 
 const (
-	notificationEndpoint = "https://ntfy.sh/dapidi_alerts"
+	// Endpoint is the ntfy topic notifications are posted to
+	Endpoint       = "https://ntfy.sh/dapidi_alerts"
+	requestTimeout = 10 * time.Second
 )
 
+var client = httpclient.New(requestTimeout)
+
 // Send sends a notification message to the configured endpoint
 func Send(message string) error {
 	if message == "" {
 		return fmt.Errorf("message cannot be empty")
 	}
 	payload := strings.NewReader(message)
-	resp, err := http.Post(notificationEndpoint, "text/plain", payload)
+	resp, err := client.Post(Endpoint, "text/plain", payload)
 	if err != nil {
 		return fmt.Errorf("failed to send notification: %w", err)
 	}
@@ -28,3 +37,38 @@ func Send(message string) error {
 
 	return nil
 }
+
+// Check verifies that the notification server is reachable and healthy,
+// without sending a notification
+func Check() error {
+	return check(Endpoint)
+}
+
+func check(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid notification endpoint: %w", err)
+	}
+	u.Path = "/v1/health"
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("notification server unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var health struct {
+		Healthy bool `json:"healthy"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return fmt.Errorf("failed to parse notification server health: %w", err)
+	}
+	if !health.Healthy {
+		return fmt.Errorf("notification server reports unhealthy")
+	}
+	return nil
+}
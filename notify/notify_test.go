@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"my-incident-checker/httpclient"
+)
+
+// toServer sends every request to a test server
+type toServer struct {
+	url *url.URL
+}
+
+func (s toServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = s.url.Scheme, s.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSendAndCheck(t *testing.T) {
+	var paths, bodies, agents []string
+	healthy := `{"healthy":true}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(body))
+		agents = append(agents, r.UserAgent())
+		if r.URL.Path == "/v1/health" {
+			io.WriteString(w, healthy)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	defer httpclient.SetTransport(toServer{serverURL})()
+
+	if err := Send("pi-1 is online"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(); err != nil {
+		t.Fatal(err)
+	}
+	healthy = `{"healthy":false}`
+	if err := Check(); err == nil {
+		t.Error("Check succeeded with an unhealthy server")
+	}
+	if err := Send(""); err == nil {
+		t.Error("Send succeeded with an empty message")
+	}
+
+	if len(paths) != 3 || paths[0] != "/dapidi_alerts" || paths[1] != "/v1/health" {
+		t.Fatalf("paths = %q", paths)
+	}
+	if bodies[0] != "pi-1 is online" {
+		t.Errorf("body = %q", bodies[0])
+	}
+	if agents[0] != httpclient.DefaultUserAgent {
+		t.Errorf("User-Agent = %q, want %q", agents[0], httpclient.DefaultUserAgent)
+	}
+}
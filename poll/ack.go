@@ -0,0 +1,160 @@
+package poll
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/types"
+)
+
+// AckSource is the arbiter claim posted while incidents are acknowledged
+const AckSource = "acknowledged"
+
+// Acknowledger lets staff acknowledge the current alert. Acknowledging
+// silences the buzzer and stops blinking until an incident starts or gets
+// worse; snoozing holds that for a fixed time whatever happens
+type Acknowledger struct {
+	arbiter *lights.Arbiter
+	logger  *types.Logger
+	now     func() time.Time
+
+	mu sync.Mutex
+	// active maps the relevant incidents last seen to their state
+	active map[int]string
+	// acked is what was acknowledged, nil when nothing is
+	acked       map[int]string
+	snoozeUntil time.Time
+}
+
+// NewAcknowledger creates an Acknowledger posting claims on arbiter
+func NewAcknowledger(arbiter *lights.Arbiter, logger *types.Logger) *Acknowledger {
+	return &Acknowledger{
+		arbiter: arbiter,
+		logger:  logger,
+		now:     time.Now,
+		active:  make(map[int]string),
+	}
+}
+
+// Observe records the incidents of a poll cycle, after the incident claim
+// was updated. While acknowledged the light keeps following the incident
+// state, silenced. An acknowledgement ends when an incident appears or
+// changes state that wasn't acknowledged or when all incidents are
+// resolved; a snooze ends when its time is up
+func (a *Acknowledger) Observe(incidents []types.Incident) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active = relevantIncidents(incidents)
+	if a.acked == nil {
+		return
+	}
+
+	var ttl time.Duration
+	if !a.snoozeUntil.IsZero() {
+		ttl = a.snoozeUntil.Sub(a.now())
+		if ttl <= 0 {
+			a.logger.InfoLog.Printf("Snooze ended, alerts resume")
+			a.releaseLocked()
+			return
+		}
+	} else {
+		if len(a.active) == 0 {
+			a.logger.InfoLog.Printf("Acknowledged incidents resolved")
+			a.releaseLocked()
+			return
+		}
+		for id, state := range a.active {
+			if a.acked[id] != state {
+				a.logger.InfoLog.Printf("Incident %d is %s since the acknowledgement, alerts resume", id, state)
+				a.releaseLocked()
+				return
+			}
+		}
+		// Forget resolved incidents so they alert again if they return
+		for id := range a.acked {
+			if _, ok := a.active[id]; !ok {
+				delete(a.acked, id)
+			}
+		}
+	}
+
+	if err := a.arbiter.Claim(AckSource, lights.PriorityAcknowledged, silenced(a.incidentLamps()), ttl); err != nil {
+		a.logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+	}
+}
+
+// Acknowledge holds the current incident state steady and silent until an
+// incident starts or changes
+func (a *Acknowledger) Acknowledge() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.snoozeUntil = time.Time{}
+	a.logger.InfoLog.Printf("Alert acknowledged (%d active incidents)", len(a.active))
+	return a.claimLocked(0)
+}
+
+// Snooze holds the current incident state steady and silent for d, even
+// if incidents start or change meanwhile
+func (a *Acknowledger) Snooze(d time.Duration) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.snoozeUntil = a.now().Add(d)
+	a.logger.InfoLog.Printf("Alerts snoozed for %s (%d active incidents)", d, len(a.active))
+	return a.claimLocked(d)
+}
+
+// Acknowledged reports whether an acknowledgement or snooze is active
+func (a *Acknowledger) Acknowledged() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.acked != nil
+}
+
+func (a *Acknowledger) claimLocked(ttl time.Duration) error {
+	a.acked = make(map[int]string, len(a.active))
+	for id, state := range a.active {
+		a.acked[id] = state
+	}
+	return a.arbiter.Claim(AckSource, lights.PriorityAcknowledged, silenced(a.incidentLamps()), ttl)
+}
+
+func (a *Acknowledger) releaseLocked() {
+	a.acked = nil
+	a.snoozeUntil = time.Time{}
+	if err := a.arbiter.Release(AckSource); err != nil {
+		a.logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+	}
+}
+
+// incidentLamps returns what the incident claim shows
+func (a *Acknowledger) incidentLamps() lights.TowerState {
+	for _, claim := range a.arbiter.Claims() {
+		if claim.Source == ClaimSource {
+			return claim.Lamps()
+		}
+	}
+	return lights.TowerState{}
+}
+
+// silenced turns blinking lamps steady and the buzzer off
+func silenced(lamps lights.TowerState) lights.TowerState {
+	for _, lamp := range lights.Lamps {
+		if lamps.Mode(lamp) == lights.ModeBlink {
+			lamps = lamps.With(lamp, lights.ModeOn)
+		}
+	}
+	return lamps.With(lights.LampBuzzer, lights.ModeOff)
+}
+
+// relevantIncidents maps the incidents in a relevant state to that state
+func relevantIncidents(incidents []types.Incident) map[int]string {
+	active := make(map[int]string)
+	for _, incident := range incidents {
+		if isRelevantState(incident.CurrentState) {
+			active[incident.ID] = strings.ToLower(incident.CurrentState)
+		}
+	}
+	return active
+}
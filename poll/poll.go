@@ -7,79 +7,309 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"my-incident-checker/auth"
+	"my-incident-checker/display"
+	"my-incident-checker/httpclient"
 	"my-incident-checker/lights"
 	"my-incident-checker/types"
+	"my-incident-checker/wall"
 )
 
 const (
-	incidentsEndpoint = "https://status-api.joseserver.com/incidents/recent?count=10"
+	// IncidentsEndpoint is the URL the incidents are fetched from
+	IncidentsEndpoint = "https://status-api.joseserver.com/incidents/recent?count=10"
 	pollInterval      = 5 * time.Second
+	// fetchTimeout bounds a fetch of the incidents, well below the
+	// watchdog timeout
+	fetchTimeout = 15 * time.Second
 )
 
+// client fetches the incidents
+var client = httpclient.New(fetchTimeout)
+
+// Poller runs the incident polling loop and drives the lights from it
+type Poller struct {
+	StartTime time.Time
+	Light     lights.Light
+	Logger    *types.Logger
+	// Zones are lights that only reflect a subset of services, evaluated
+	// separately from the main light
+	Zones []Zone
+	// Wall, when set, shows every service of the feed on an LED strip
+	Wall *wall.Wall
+	// Arbiter, when set, receives the incident state as a claim instead of
+	// it being applied to Light directly
+	Arbiter *lights.Arbiter
+	// Acks, when set, tracks acknowledgements of the incidents
+	Acks *Acknowledger
+	// Display, when set, shows the details of the active incidents
+	Display *display.Presenter
+	// OnCycle, when set, is called after every completed poll cycle
+	OnCycle func()
+	// OnFetch, when set, is called with the result of every fetch of the
+	// incidents
+	OnFetch func(err error)
+	// Auth, when set, authenticates the fetches of the incidents
+	Auth auth.Authenticator
+
+	mu        sync.Mutex
+	lastPoll  time.Time
+	lastCycle time.Time
+	stop      chan struct{}
+
+	// The state of the poll cycles, owned by Poll
+	notifiedIncidents map[int]bool
+	seenIncidents     map[int]bool
+	zoneStates        map[string]string
+	cachedIncidents   []types.Incident
+	currentLightState string
+	// alertState is the last state decided by AlertLogic, kept to combine
+	// it with the zones
+	alertState lights.State
+}
+
+// ClaimSource is the arbiter claim posted for incidents
+const ClaimSource = "incidents"
+
+// AlarmLamps are claimed for a new incident until it is acknowledged
+var AlarmLamps = lights.TowerState{Red: lights.ModeBlink, Buzzer: lights.ModeOn}
+
 // PollIncidents continuously monitors for incidents and updates the light status
 func PollIncidents(startTime time.Time, light lights.Light, logger *types.Logger) {
-	logger.InfoLog.Printf("Starting incident polling at %s", startTime.Format(time.RFC3339))
-	fmt.Printf("*** Starting incident polling at %s\n", startTime.Format(time.RFC3339))
+	poller := &Poller{
+		StartTime: startTime,
+		Light:     light,
+		Logger:    logger,
+	}
+	poller.Run()
+}
 
-	notifiedIncidents := make(map[int]bool)
-	seenIncidents := make(map[int]bool)
-	var cachedIncidents []types.Incident
-	currentLightState := "green" // Track current light state
+// Run polls for incidents until Stop is called
+func (p *Poller) Run() {
+	p.Logger.InfoLog.Printf("Starting incident polling at %s", p.StartTime.Format(time.RFC3339))
+	fmt.Printf("*** Starting incident polling at %s\n", p.StartTime.Format(time.RFC3339))
 
 	for {
-		// Connectivity check disabled
-
-		incidents, err := fetchIncidents()
-		if err != nil {
-			logger.ErrorLog.Printf("Failed to fetch incidents: %s", err.Error())
-			time.Sleep(pollInterval)
-			continue
+		p.Poll()
+		if !p.wait() {
+			return
 		}
+	}
+}
 
-		// Log state changes first
-		state, err := AlertLogic(incidents, light, notifiedIncidents, startTime, logger, currentLightState)
-		if err != nil {
-			logger.ErrorLog.Printf("Alert logic error: %s", err.Error())
-		} else if state != nil {
-			stateColor := "unknown"
-			switch state.(type) {
-			case lights.RedState:
-				stateColor = "red"
-			case lights.YellowState:
-				stateColor = "yellow"
-			case lights.GreenState:
-				stateColor = "green"
-			}
-			if stateColor != currentLightState {
-				logger.InfoLog.Printf("⚠️ Light color changed to: %s", strings.ToUpper(stateColor))
-				currentLightState = stateColor
-			}
-			if err := state.Apply(light); err != nil {
-				logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+// Poll runs one poll cycle: it fetches the incidents and updates the
+// lights, wall and display from them
+func (p *Poller) Poll() {
+	logger := p.Logger
+	if p.notifiedIncidents == nil {
+		p.notifiedIncidents = make(map[int]bool)
+		p.seenIncidents = make(map[int]bool)
+		p.zoneStates = make(map[string]string)
+		p.currentLightState = "green"
+	}
+	defer p.completeCycle()
+
+	incidents, err := fetchIncidents(p.Auth)
+	if p.OnFetch != nil {
+		p.OnFetch(err)
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("Failed to fetch incidents: %s", err.Error())
+		if p.Wall != nil {
+			if err := p.Wall.MarkStale(time.Now()); err != nil {
+				logger.ErrorLog.Printf("Failed to update service wall: %s", err.Error())
 			}
 		}
+		return
+	}
+	p.mu.Lock()
+	p.lastPoll = time.Now()
+	p.mu.Unlock()
+
+	// Log state changes first
+	state, err := AlertLogic(incidents, p.Light, p.notifiedIncidents, p.StartTime, logger, p.currentLightState)
+	if err == nil && len(p.Zones) > 0 {
+		state = p.summarizeZones(state, incidents)
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("Alert logic error: %s", err.Error())
+	} else if state != nil {
+		stateColor := stateName(state)
+		if stateColor != p.currentLightState {
+			logger.InfoLog.Printf("⚠️ Light color changed to: %s", strings.ToUpper(stateColor))
+			p.currentLightState = stateColor
+		}
+		if err := p.show(p.alerting(state)); err != nil {
+			logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+		}
+	}
 
-		// Then log if incidents have changed
-		if !incidentsEqual(incidents, cachedIncidents) {
-			logIncidentChanges(logger, cachedIncidents, incidents)
-			cachedIncidents = make([]types.Incident, len(incidents))
-			copy(cachedIncidents, incidents)
+	if p.Acks != nil {
+		p.Acks.Observe(incidents)
+	}
+
+	for _, zone := range p.Zones {
+		p.applyZone(zone, incidents, p.zoneStates)
+	}
+
+	if p.Wall != nil {
+		if err := p.Wall.Update(incidents, time.Now()); err != nil {
+			logger.ErrorLog.Printf("Failed to update service wall: %s", err.Error())
 		}
+	}
 
-		for _, incident := range incidents {
-			if !seenIncidents[incident.ID] {
-				logger.InfoLog.Printf("New incident detected: [%s] %s - Current State: %s",
-					incident.Service,
-					incident.Incident.Title,
-					incident.CurrentState)
-				seenIncidents[incident.ID] = true
-			}
+	if p.Display != nil {
+		if err := p.Display.Update(incidents); err != nil {
+			logger.ErrorLog.Printf("Failed to update display: %s", err.Error())
 		}
+	}
+
+	// Then log if incidents have changed
+	if !incidentsEqual(incidents, p.cachedIncidents) {
+		logIncidentChanges(logger, p.cachedIncidents, incidents)
+		p.cachedIncidents = make([]types.Incident, len(incidents))
+		copy(p.cachedIncidents, incidents)
+	}
+
+	for _, incident := range incidents {
+		if !p.seenIncidents[incident.ID] {
+			logger.InfoLog.Printf("New incident detected: [%s] %s - Current State: %s",
+				incident.Service,
+				incident.Incident.Title,
+				incident.CurrentState)
+			p.seenIncidents[incident.ID] = true
+		}
+	}
+}
+
+// summarizeZones makes the main light show at least the worst state of
+// any service, so that it is never greener than one of the zone lights.
+// New incidents still alert as decided by AlertLogic
+func (p *Poller) summarizeZones(alert lights.State, incidents []types.Incident) lights.State {
+	if alert != nil {
+		p.alertState = alert
+	}
+	worst := ZoneLogic(incidents, Zone{})
+	if p.alertState != nil && severity(p.alertState) >= severity(worst) {
+		return p.alertState
+	}
+	return worst
+}
+
+// alerting turns the red of a new incident into AlarmLamps when it can be
+// acknowledged, so that acknowledging visibly silences it
+func (p *Poller) alerting(state lights.State) lights.State {
+	if _, ok := state.(lights.RedState); ok && p.Acks != nil {
+		return AlarmLamps
+	}
+	return state
+}
+
+// Stop ends Run after the current poll cycle
+func (p *Poller) Stop() {
+	stop := p.stopped()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-stop:
+	default:
+		close(stop)
+	}
+}
+
+// stopped returns the channel closed by Stop
+func (p *Poller) stopped() chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+	}
+	return p.stop
+}
+
+// wait sleeps until the next poll, returning false when stopped meanwhile
+func (p *Poller) wait() bool {
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-p.stopped():
+		return false
+	}
+}
+
+// LastPoll returns the time of the last successful fetch of the
+// incidents, zero before the first
+func (p *Poller) LastPoll() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastPoll
+}
+
+// LastCycle returns when the last poll cycle completed, successful or
+// not, zero before the first
+func (p *Poller) LastCycle() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastCycle
+}
+
+func (p *Poller) completeCycle() {
+	p.mu.Lock()
+	p.lastCycle = time.Now()
+	p.mu.Unlock()
+	if p.OnCycle != nil {
+		p.OnCycle()
+	}
+}
+
+// show displays the incident state
+func (p *Poller) show(state lights.State) error {
+	if p.Arbiter != nil {
+		return p.Arbiter.Claim(ClaimSource, lights.PriorityIncident, state, 0)
+	}
+	return state.Apply(p.Light)
+}
+
+// applyZone shows the zone's state on its light, logging changes
+func (p *Poller) applyZone(zone Zone, incidents []types.Incident, zoneStates map[string]string) {
+	state := ZoneLogic(incidents, zone)
+	stateColor := stateName(state)
+	if stateColor != zoneStates[zone.Name] {
+		p.Logger.InfoLog.Printf("Zone %s light changed to: %s", zone.Name, strings.ToUpper(stateColor))
+		zoneStates[zone.Name] = stateColor
+	}
+	if err := zone.show(state); err != nil {
+		p.Logger.ErrorLog.Printf("Failed to apply light state for zone %s: %s", zone.Name, err.Error())
+	}
+}
 
-		time.Sleep(pollInterval)
+// severity orders states from green to red
+func severity(state lights.State) int {
+	switch state.(type) {
+	case lights.RedState:
+		return 2
+	case lights.YellowState:
+		return 1
 	}
+	return 0
+}
+
+// stateName returns the color name of a light state for logging
+func stateName(state lights.State) string {
+	switch state.(type) {
+	case lights.RedState:
+		return "red"
+	case lights.YellowState:
+		return "yellow"
+	case lights.GreenState:
+		return "green"
+	}
+	return "unknown"
 }
 
 // incidentsEqual compares two slices of incidents for equality, regardless of order
@@ -147,11 +377,21 @@ func logIncidentChanges(logger *types.Logger, oldIncidents, newIncidents []types
 	}
 }
 
-// fetchIncidents retrieves the list of incidents from the API
-func fetchIncidents() ([]types.Incident, error) {
-	resp, err := http.Get(incidentsEndpoint)
+// fetchIncidents retrieves the list of incidents from the API,
+// authenticated by authenticator when it is set
+func fetchIncidents(authenticator auth.Authenticator) ([]types.Incident, error) {
+	resp, err := getIncidents(authenticator)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch incidents: %w", err)
+		return nil, err
+	}
+	// A rejected token may have been revoked before it expired; retry once
+	// with a new one
+	if invalidator, ok := authenticator.(auth.Invalidator); ok && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		invalidator.Invalidate()
+		if resp, err = getIncidents(authenticator); err != nil {
+			return nil, err
+		}
 	}
 	defer resp.Body.Close()
 
@@ -173,6 +413,23 @@ func fetchIncidents() ([]types.Incident, error) {
 	return incidents, nil
 }
 
+func getIncidents(authenticator auth.Authenticator) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, IncidentsEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch incidents: %w", err)
+	}
+	if authenticator != nil {
+		if err := authenticator.Authorize(req); err != nil {
+			return nil, fmt.Errorf("failed to authenticate to incidents API: %w", err)
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch incidents: %w", err)
+	}
+	return resp, nil
+}
+
 // sortIncidentsByTime sorts incidents by creation time, most recent first
 func sortIncidentsByTime(incidents []types.Incident) []types.Incident {
 	sorted := make([]types.Incident, len(incidents))
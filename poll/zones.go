@@ -0,0 +1,77 @@
+package poll
+
+import (
+	"strings"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/types"
+)
+
+// Zone binds a light to a subset of services or components, so it only
+// reflects incidents that concern them
+type Zone struct {
+	Name       string
+	Services   []string
+	Components []string
+	Light      lights.Light
+	// Arbiter, when set, receives the zone state as a claim instead of it
+	// being applied to Light directly
+	Arbiter *lights.Arbiter
+}
+
+// show displays the state of the zone
+func (z Zone) show(state lights.State) error {
+	if z.Arbiter != nil {
+		return z.Arbiter.Claim(ClaimSource, lights.PriorityIncident, state, 0)
+	}
+	return state.Apply(z.Light)
+}
+
+// Matches reports whether an incident concerns the zone. A zone without
+// services or components matches every incident
+func (z Zone) Matches(incident types.Incident) bool {
+	if len(z.Services) == 0 && len(z.Components) == 0 {
+		return true
+	}
+	for _, service := range z.Services {
+		if strings.EqualFold(service, incident.Service) {
+			return true
+		}
+	}
+	for _, component := range z.Components {
+		for _, affected := range incident.Incident.Components {
+			if strings.EqualFold(component, affected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ZoneLogic determines the state of a zone from the latest incident of
+// each matching service: red while any is in outage, critical or major
+// state, yellow while any is degraded, green otherwise. Unlike AlertLogic
+// it reflects the current state of the services rather than new alerts
+func ZoneLogic(incidents []types.Incident, zone Zone) lights.State {
+	latest := make(map[string]types.Incident)
+	for _, incident := range sortIncidentsByTime(incidents) {
+		if !zone.Matches(incident) {
+			continue
+		}
+		service := strings.ToLower(incident.Service)
+		if _, seen := latest[service]; !seen {
+			latest[service] = incident
+		}
+	}
+
+	var state lights.State = lights.GreenState{}
+	for _, incident := range latest {
+		switch strings.ToLower(incident.CurrentState) {
+		case types.StateOutage, types.StateCritical, types.StateMajor:
+			return lights.RedState{}
+		case types.StateDegraded:
+			state = lights.YellowState{}
+		}
+	}
+	return state
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/poll"
+	"my-incident-checker/systemd"
+	"my-incident-checker/types"
+)
+
+// startSystemd reports to systemd when run with Type=notify: READY=1 after
+// the first poll cycle, the light state as STATUS= and, with WatchdogSec=,
+// WATCHDOG=1 pings for as long as the poll loop advances. It must be
+// called before the poller runs
+func startSystemd(notifier *systemd.Notifier, poller *poll.Poller, arbiter *lights.Arbiter, logger *types.Logger) func() {
+	if notifier == nil {
+		return func() {}
+	}
+
+	ready := false
+	lastStatus := ""
+	poller.OnCycle = func() {
+		if !ready {
+			if err := notifier.Ready(); err != nil {
+				logger.ErrorLog.Printf("%s", err.Error())
+			}
+			logger.InfoLog.Printf("Notified systemd of readiness")
+			ready = true
+		}
+		if status := systemdStatus(arbiter); status != lastStatus {
+			if err := notifier.Status(status); err != nil {
+				logger.ErrorLog.Printf("%s", err.Error())
+			}
+			lastStatus = status
+		}
+	}
+
+	stop := make(chan struct{})
+	if interval, ok := systemd.WatchdogInterval(); ok {
+		logger.InfoLog.Printf("Pinging the systemd watchdog, timeout %s", interval)
+		go systemdWatchdog(notifier, interval, poller, logger, stop)
+	}
+	return func() { close(stop) }
+}
+
+// systemdWatchdog pings the systemd watchdog twice per interval while the
+// poll loop completed a cycle within the interval. When the loop is stuck
+// the pings stop and systemd restarts the checker
+func systemdWatchdog(notifier *systemd.Notifier, interval time.Duration, poller *poll.Poller, logger *types.Logger, stop <-chan struct{}) {
+	started := time.Now()
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	withheld := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		last := poller.LastCycle()
+		if last.IsZero() {
+			last = started
+		}
+		if age := time.Since(last); age > interval {
+			if !withheld {
+				logger.WarnLog.Printf("No poll cycle for %s, withholding systemd watchdog pings", age.Round(time.Second))
+				withheld = true
+			}
+			continue
+		}
+		withheld = false
+		if err := notifier.Watchdog(); err != nil {
+			logger.ErrorLog.Printf("%s", err.Error())
+		}
+	}
+}
+
+// systemdStatus describes the state shown on the light
+func systemdStatus(arbiter *lights.Arbiter) string {
+	claim, ok := arbiter.Active()
+	if !ok {
+		return "Light off"
+	}
+	return fmt.Sprintf("Light %s (%s)", arbiter.Shown(), claim.Source)
+}
@@ -0,0 +1,104 @@
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier sends service state notifications to systemd over the
+// NOTIFY_SOCKET datagram socket, as sd_notify(3) does. A nil Notifier, as
+// returned when not run by systemd, ignores every notification
+type Notifier struct {
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// NewNotifier connects to the socket in NOTIFY_SOCKET. Without it, the
+// checker is not run by systemd with Type=notify and the returned Notifier
+// is nil
+func NewNotifier() (*Notifier, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil, nil
+	}
+	// A leading @ is an abstract socket
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to systemd notify socket: %w", err)
+	}
+	return &Notifier{conn: conn}, nil
+}
+
+// Notify sends state, one or more newline separated assignments such as
+// "READY=1"
+func (n *Notifier) Notify(state string) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return fmt.Errorf("systemd notifier is closed")
+	}
+	if _, err := n.conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
+
+// Ready tells systemd that startup finished
+func (n *Notifier) Ready() error {
+	return n.Notify("READY=1")
+}
+
+// Status sets the status shown by systemctl status
+func (n *Notifier) Status(status string) error {
+	return n.Notify("STATUS=" + strings.ReplaceAll(status, "\n", " "))
+}
+
+// Watchdog keeps the systemd watchdog from restarting the service
+func (n *Notifier) Watchdog() error {
+	return n.Notify("WATCHDOG=1")
+}
+
+// Stopping tells systemd that the service is shutting down
+func (n *Notifier) Stopping() error {
+	return n.Notify("STOPPING=1")
+}
+
+// Close closes the socket
+func (n *Notifier) Close() error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+// WatchdogInterval returns the watchdog timeout systemd expects pings
+// within, from WATCHDOG_USEC. It is false when the watchdog is disabled or
+// meant for another process
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
@@ -0,0 +1,90 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotifier(t *testing.T) {
+	conn := listen(t)
+	notifier, err := NewNotifier()
+	if err != nil || notifier == nil {
+		t.Fatalf("NewNotifier() = %v, %v", notifier, err)
+	}
+	defer notifier.Close()
+
+	notifier.Ready()
+	notifier.Status("Light red=on\n(incidents)")
+	notifier.Watchdog()
+	notifier.Stopping()
+
+	for _, want := range []string{"READY=1", "STATUS=Light red=on (incidents)", "WATCHDOG=1", "STOPPING=1"} {
+		if got := receive(t, conn); got != want {
+			t.Errorf("notification = %q, want %q", got, want)
+		}
+	}
+
+	notifier.Close()
+	if err := notifier.Ready(); err == nil {
+		t.Error("Ready() after Close succeeded")
+	}
+}
+
+func TestNotifierWithoutSystemd(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	notifier, err := NewNotifier()
+	if notifier != nil || err != nil {
+		t.Fatalf("NewNotifier() = %v, %v, want nil without NOTIFY_SOCKET", notifier, err)
+	}
+	if err := notifier.Ready(); err != nil {
+		t.Errorf("Ready() on nil notifier = %v", err)
+	}
+	if err := notifier.Close(); err != nil {
+		t.Errorf("Close() on nil notifier = %v", err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if interval, ok := WatchdogInterval(); !ok || interval != 30*time.Second {
+		t.Errorf("WatchdogInterval() = %s, %v, want 30s", interval, ok)
+	}
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if _, ok := WatchdogInterval(); ok {
+		t.Error("WatchdogInterval() enabled for another process")
+	}
+
+	t.Setenv("WATCHDOG_PID", "")
+	t.Setenv("WATCHDOG_USEC", "")
+	if _, ok := WatchdogInterval(); ok {
+		t.Error("WatchdogInterval() enabled without WATCHDOG_USEC")
+	}
+}
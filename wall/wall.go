@@ -0,0 +1,179 @@
+// Package wall shows the state of every service in the incident feed on an
+// addressable LED strip, one pixel per service
+package wall
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/types"
+)
+
+// DefaultStaleAfter is how long a service may be missing from the feed
+// before its pixel is dimmed
+const DefaultStaleAfter = 24 * time.Hour
+
+// dimmed is the brightness of stale and unknown services
+const dimmed = 0.15
+
+var (
+	maintenanceColor = lights.RGB{R: 0, G: 0, B: 255}
+	unknownColor     = lights.RGB{R: 255, G: 255, B: 255}
+)
+
+// ServiceStatus is what the wall shows for one service
+type ServiceStatus struct {
+	Name  string
+	State string
+	// Updated is the time of the latest change seen for the service
+	Updated time.Time
+	// Seen is the time of the last successful poll that reported the
+	// service
+	Seen time.Time
+	// Pixel is the position on the strip, -1 when the strip is too short
+	Pixel int
+	Stale bool
+	Color lights.RGB
+}
+
+// Wall assigns a pixel to every service seen in the incident feed and
+// colors it by the current state of the service. Pixels keep their
+// service for as long as the wall runs, so the layout doesn't shift when
+// new services appear
+type Wall struct {
+	strip      lights.Strip
+	staleAfter time.Duration
+
+	mu       sync.Mutex
+	services []*ServiceStatus
+	byName   map[string]*ServiceStatus
+	// feedStale is set while the incident feed can't be fetched
+	feedStale bool
+}
+
+// New creates a Wall on strip. Services missing from the feed for
+// staleAfter are dimmed; zero disables that. A service whose last incident
+// is old but still in the feed is current, as nothing changed since
+func New(strip lights.Strip, staleAfter time.Duration) *Wall {
+	return &Wall{
+		strip:      strip,
+		staleAfter: staleAfter,
+		byName:     make(map[string]*ServiceStatus),
+	}
+}
+
+// Update shows the state of the services in incidents
+func (w *Wall) Update(incidents []types.Incident, now time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.feedStale = false
+	var added []*ServiceStatus
+	for _, incident := range incidents {
+		key := strings.ToLower(incident.Service)
+		if key == "" {
+			continue
+		}
+		updated := latestUpdate(incident)
+		status, ok := w.byName[key]
+		if !ok {
+			status = &ServiceStatus{Name: incident.Service}
+			w.byName[key] = status
+			added = append(added, status)
+		}
+		status.Seen = now
+		if ok && !updated.After(status.Updated) && status.State != "" {
+			continue
+		}
+		status.State = strings.ToLower(incident.CurrentState)
+		status.Updated = updated
+	}
+
+	// New services seen in the same update are placed alphabetically so
+	// the layout doesn't depend on the order of the feed
+	sort.Slice(added, func(i, j int) bool { return added[i].Name < added[j].Name })
+	w.services = append(w.services, added...)
+
+	return w.showLocked(now)
+}
+
+// MarkStale dims every service, for when the incident feed can't be
+// fetched. The next Update brightens them again
+func (w *Wall) MarkStale(now time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.feedStale = true
+	return w.showLocked(now)
+}
+
+// Services returns what the wall shows, in pixel order
+func (w *Wall) Services() []ServiceStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]ServiceStatus, len(w.services))
+	for i, status := range w.services {
+		out[i] = *status
+	}
+	return out
+}
+
+func (w *Wall) showLocked(now time.Time) error {
+	pixels := w.strip.Len()
+	colors := make([]lights.RGB, 0, pixels)
+	for i, status := range w.services {
+		status.Stale = w.feedStale || (w.staleAfter > 0 && now.Sub(status.Seen) > w.staleAfter)
+		status.Color = colorFor(status.State, status.Stale)
+		status.Pixel = -1
+		if i < pixels {
+			status.Pixel = i
+			colors = append(colors, status.Color)
+		}
+	}
+	return w.strip.Show(colors)
+}
+
+// colorFor returns the pixel color of a service state
+func colorFor(state string, stale bool) lights.RGB {
+	var color lights.RGB
+	known := true
+	switch state {
+	case types.StateOutage, types.StateCritical, types.StateMajor:
+		color, _ = lights.ColorFor(lights.StateRed)
+	case types.StateDegraded:
+		color, _ = lights.ColorFor(lights.StateYellow)
+	case types.StateOperational:
+		color, _ = lights.ColorFor(lights.StateGreen)
+	case types.StateMaintenance:
+		color = maintenanceColor
+	default:
+		color = unknownColor
+		known = false
+	}
+	if stale || !known {
+		return color.Scale(dimmed)
+	}
+	return color
+}
+
+// latestUpdate returns the time of the latest change of an incident, the
+// zero time when none can be parsed
+func latestUpdate(incident types.Incident) time.Time {
+	latest := parseTime(incident.CreatedAt)
+	for _, entry := range incident.History {
+		if recorded := parseTime(entry.RecordedAt); recorded.After(latest) {
+			latest = recorded
+		}
+	}
+	return latest
+}
+
+func parseTime(value string) time.Time {
+	t, err := time.Parse(types.TimeFormat, strings.Split(value, ".")[0])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
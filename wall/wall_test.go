@@ -0,0 +1,60 @@
+package wall
+
+import (
+	"testing"
+	"time"
+
+	"my-incident-checker/lights"
+	"my-incident-checker/types"
+)
+
+func TestWall(t *testing.T) {
+	now := time.Date(2025, 1, 9, 12, 0, 0, 0, time.UTC)
+	strip := lights.NewVirtualStrip(4)
+	w := New(strip, time.Hour)
+
+	incidents := []types.Incident{
+		{ID: 1, Service: "web", CurrentState: "degraded", CreatedAt: "2025-01-09T11:30:00.000000"},
+		{ID: 2, Service: "api", CurrentState: "outage", CreatedAt: "2025-01-09T11:00:00"},
+		{ID: 3, Service: "api", CurrentState: "operational", CreatedAt: "2025-01-09T10:00:00",
+			History: []types.IncidentHistory{{RecordedAt: "2025-01-09T11:45:00"}}},
+		{ID: 4, Service: "dns", CurrentState: "operational", CreatedAt: "2025-01-09T08:00:00"},
+	}
+	if err := w.Update(incidents, now); err != nil {
+		t.Fatal(err)
+	}
+	// api resolved after the outage; dns is still reported, so its old
+	// incident doesn't make it stale
+	if got := strip.String(); got != "GGY." {
+		t.Errorf("strip = %q, want %q", got, "GGY.")
+	}
+
+	// A new service is added at the end without moving the others
+	incidents = append(incidents, types.Incident{ID: 5, Service: "cdn", CurrentState: "major", CreatedAt: "2025-01-09T11:50:00"})
+	w.Update(incidents, now)
+	if got := strip.String(); got != "GGYR" {
+		t.Errorf("strip = %q, want %q", got, "GGYR")
+	}
+
+	// web dropped out of the feed and dims once it has been missing for
+	// too long
+	w.Update(incidents[1:], now.Add(30*time.Minute))
+	w.Update(incidents[1:], now.Add(2*time.Hour))
+	if got := strip.String(); got != "GGyR" {
+		t.Errorf("strip = %q, want %q", got, "GGyR")
+	}
+	w.Update(incidents, now)
+
+	// More services than pixels: the rest are tracked but not shown
+	incidents = append(incidents, types.Incident{ID: 6, Service: "mail", CurrentState: "unknown", CreatedAt: "2025-01-09T11:55:00"})
+	w.Update(incidents, now)
+	services := w.Services()
+	if last := services[len(services)-1]; last.Name != "mail" || last.Pixel != -1 {
+		t.Errorf("last service = %+v, want mail without a pixel", last)
+	}
+
+	w.MarkStale(now)
+	if got := strip.String(); got != "ggyr" {
+		t.Errorf("strip = %q while the feed is unreachable, want %q", got, "ggyr")
+	}
+}
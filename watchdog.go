@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"my-incident-checker/config"
+	"my-incident-checker/lights"
+	"my-incident-checker/node"
+	"my-incident-checker/notify"
+	"my-incident-checker/poll"
+	"my-incident-checker/types"
+	"my-incident-checker/watchdog"
+)
+
+// staleSource is the arbiter claim shown while the poll loop is stuck
+const staleSource = "stale-data"
+
+// notifyTimeout bounds notifications sent when something may hang
+const notifyTimeout = 10 * time.Second
+
+// defaultStaleLamps are shown while the poll loop is stuck: every lamp
+// blinking, which no incident state looks like
+var defaultStaleLamps = lights.TowerState{Red: lights.ModeBlink, Yellow: lights.ModeBlink, Green: lights.ModeBlink}
+
+// startWatchdog watches the poll loop, showing the stale lamps and
+// notifying while it is stuck
+func startWatchdog(cfg *config.WatchdogConfig, poller *poll.Poller, arbiter *lights.Arbiter, logger *types.Logger) func() {
+	if cfg == nil {
+		cfg = &config.WatchdogConfig{}
+	}
+	timeout, err := durationOption(cfg.Timeout, watchdog.DefaultTimeout)
+	if err != nil {
+		logger.ErrorLog.Printf("Invalid watchdog timeout: %s", err.Error())
+		timeout = watchdog.DefaultTimeout
+	}
+	lamps := lampsOption(cfg.StaleLamps, defaultStaleLamps, "watchdog stale_lamps", logger)
+
+	nodeName := node.GetNodeName()
+	dog := watchdog.New(poller.LastCycle, timeout, logger)
+	dog.Exit = cfg.Exit
+	dog.OnStale = func(age time.Duration) {
+		if err := arbiter.Claim(staleSource, lights.PriorityStale, lamps, 0); err != nil {
+			logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+		}
+		notifyWithin(fmt.Sprintf("%s: incident polling stuck for %s, light shows stale data", nodeName, age.Round(time.Second)), logger)
+	}
+	dog.OnRecover = func() {
+		if err := arbiter.Release(staleSource); err != nil {
+			logger.ErrorLog.Printf("Failed to apply light state: %s", err.Error())
+		}
+		notifyWithin(fmt.Sprintf("%s: incident polling recovered", nodeName), logger)
+	}
+
+	logger.InfoLog.Printf("Watching the poll loop with a timeout of %s", timeout)
+	stop := make(chan struct{})
+	go dog.Run(stop)
+	return func() { close(stop) }
+}
+
+// notifyWithin sends a notification without waiting longer than
+// notifyTimeout, so a hanging network can't block the caller
+func notifyWithin(message string, logger *types.Logger) {
+	done := make(chan error, 1)
+	go func() { done <- notify.Send(message) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			logger.ErrorLog.Printf("Failed to send notification: %s", err.Error())
+		}
+	case <-time.After(notifyTimeout):
+		logger.ErrorLog.Printf("Notification not sent within %s", notifyTimeout)
+	}
+}
@@ -0,0 +1,120 @@
+package watchdog
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+// DefaultTimeout is how long the poll loop may go without completing a
+// cycle before it counts as stuck
+const DefaultTimeout = 2 * time.Minute
+
+// ExitCode is the exit status when the watchdog exits the process
+const ExitCode = 3
+
+// Watchdog watches the progress of a loop and reacts when it stops
+// advancing
+type Watchdog struct {
+	progress func() time.Time
+	timeout  time.Duration
+	logger   *types.Logger
+
+	// OnStale is called once the loop got stuck, with the time since its
+	// last progress
+	OnStale func(age time.Duration)
+	// OnRecover is called once the loop advances again after being stuck
+	OnRecover func()
+	// Exit makes the watchdog exit the process after OnStale, for the
+	// supervisor to restart it
+	Exit bool
+
+	mu      sync.Mutex
+	started time.Time
+	stale   bool
+	now     func() time.Time
+	exit    func(code int)
+}
+
+// New creates a Watchdog. progress returns the time of the last progress
+// of the loop, zero before the first; until then the time since New is
+// counted
+func New(progress func() time.Time, timeout time.Duration, logger *types.Logger) *Watchdog {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Watchdog{
+		progress: progress,
+		timeout:  timeout,
+		logger:   logger,
+		started:  time.Now(),
+		now:      time.Now,
+		exit:     os.Exit,
+	}
+}
+
+// Run checks the loop a few times per timeout until stop is closed
+func (w *Watchdog) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(w.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.Check()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Check compares the last progress of the loop with the timeout and
+// reports whether the loop is stuck
+func (w *Watchdog) Check() bool {
+	w.mu.Lock()
+	last := w.progress()
+	if last.IsZero() {
+		last = w.started
+	}
+	age := w.now().Sub(last)
+	stuck := age > w.timeout
+	changed := stuck != w.stale
+	w.stale = stuck
+	w.mu.Unlock()
+
+	if !changed {
+		return stuck
+	}
+	if !stuck {
+		w.logger.InfoLog.Printf("Poll loop advancing again")
+		if w.OnRecover != nil {
+			w.OnRecover()
+		}
+		return false
+	}
+
+	w.logger.ErrorLog.Printf("Poll loop stuck: no completed cycle for %s", age.Round(time.Second))
+	w.logger.ErrorLog.Printf("Goroutine dump:\n%s", Stacks())
+	if w.OnStale != nil {
+		w.OnStale(age)
+	}
+	if w.Exit {
+		w.logger.ErrorLog.Printf("Exiting for the supervisor to restart the checker")
+		w.exit(ExitCode)
+	}
+	return true
+}
+
+// Stacks returns the stack traces of all goroutines
+func Stacks() string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 8<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
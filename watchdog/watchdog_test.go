@@ -0,0 +1,72 @@
+package watchdog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"my-incident-checker/types"
+)
+
+func TestWatchdog(t *testing.T) {
+	var logs bytes.Buffer
+	logger := &types.Logger{
+		DebugLog: log.New(io.Discard, "", 0),
+		InfoLog:  log.New(&logs, "INFO: ", 0),
+		WarnLog:  log.New(&logs, "WARN: ", 0),
+		ErrorLog: log.New(&logs, "ERROR: ", 0),
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	var progress time.Time
+	dog := New(func() time.Time { return progress }, time.Minute, logger)
+	dog.started = start
+	dog.now = func() time.Time { return now }
+
+	var staleAges []time.Duration
+	recovered := 0
+	exitCode := -1
+	dog.OnStale = func(age time.Duration) { staleAges = append(staleAges, age) }
+	dog.OnRecover = func() { recovered++ }
+	dog.exit = func(code int) { exitCode = code }
+
+	now = start.Add(30 * time.Second)
+	if dog.Check() {
+		t.Error("Check() = stuck before the timeout since start")
+	}
+	progress = start.Add(40 * time.Second)
+	now = start.Add(90 * time.Second)
+	if dog.Check() {
+		t.Error("Check() = stuck within the timeout of the last cycle")
+	}
+
+	now = start.Add(130 * time.Second)
+	if !dog.Check() || !dog.Check() {
+		t.Error("Check() = advancing after the timeout")
+	}
+	if len(staleAges) != 1 || staleAges[0] != 90*time.Second {
+		t.Errorf("OnStale calls = %v, want one with the age", staleAges)
+	}
+	if exitCode != -1 {
+		t.Errorf("exited with %d without Exit", exitCode)
+	}
+	if !strings.Contains(logs.String(), "ERROR: Poll loop stuck: no completed cycle for 1m30s") ||
+		!strings.Contains(logs.String(), "goroutine ") {
+		t.Errorf("logs = %q, want the stuck loop and a stack dump", logs.String())
+	}
+
+	progress = now
+	if dog.Check() || recovered != 1 {
+		t.Errorf("Check() after progress: recovered %d times, want 1", recovered)
+	}
+
+	dog.Exit = true
+	now = progress.Add(2 * time.Minute)
+	dog.Check()
+	if exitCode != ExitCode {
+		t.Errorf("exit code = %d, want %d", exitCode, ExitCode)
+	}
+}